@@ -24,6 +24,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []token.Token // every `//` comment of the source, in order
 }
 
 func (p *Program) String() string {
@@ -44,9 +45,11 @@ func (p *Program) TokenLiteral() string {
 // Let Statement
 // -----------------------------
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Pattern         // `[a, b]` or `{name}` when destructuring, Name being nil then
+	Type    *TypeAnnotation // nil when the binding is not annotated
+	Value   Expression
 }
 
 func (ls *LetStatement) TokenLiteral() string {
@@ -58,7 +61,13 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.Token.Literal)
 	out.WriteString(" ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.Target().String())
+
+	if ls.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(ls.Type.String())
+	}
+
 	out.WriteString(" = ")
 	out.WriteString(ls.Value.String())
 
@@ -67,6 +76,21 @@ func (ls *LetStatement) String() string {
 
 func (ls *LetStatement) Statement() {}
 
+// Target is what the statement binds, its name or its pattern
+func (ls *LetStatement) Target() Pattern {
+	if ls.Pattern != nil {
+		return ls.Pattern
+	}
+
+	return ls.Name
+}
+
+// Constant reports whether the binding is declared by `const` and cannot be
+// assigned
+func (ls *LetStatement) Constant() bool {
+	return ls.Token.Type == token.CONST
+}
+
 // -----------------------------
 // Return Statement
 // -----------------------------
@@ -121,6 +145,12 @@ func (es *ExpressionStatement) Statement() {}
 type Identifier struct {
 	Token token.Token
 	Value string
+
+	// filled by the resolve package: the declaration of a Resolved
+	// identifier lives Depth scopes up, at index Slot of its scope
+	Resolved bool `ast:"-"`
+	Depth    int  `ast:"-"`
+	Slot     int  `ast:"-"`
 }
 
 func (i *Identifier) TokenLiteral() string {
@@ -278,12 +308,182 @@ func (ife *IfExpression) String() string {
 
 func (ife *IfExpression) Expression() {}
 
+// -----------------------------
+// Match Expression
+// -----------------------------
+
+// MatchExpression is `match value { pattern => result, ... }`, evaluating
+// to the result of the first arm whose pattern matches the value
+type MatchExpression struct {
+	Token  token.Token // the `match` token
+	Value  Expression
+	Arms   []*MatchArm
+	Rbrace token.Token // the `}`
+}
+
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) String() string {
+	arms := []string{}
+
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	return "match " + me.Value.String() + " {" + strings.Join(arms, ", ") + "}"
+}
+
+func (me *MatchExpression) Expression() {}
+
+// MatchArm is `pattern if guard => body`, the guard being optional. The
+// names bound by the pattern are visible to the guard and the body only
+type MatchArm struct {
+	Token   token.Token // the `=>` token
+	Pattern Pattern
+	Guard   Expression  // nil without `if`
+	Body    Expression  // a *BlockStatement when the body is in braces
+	End     token.Token // the last token of the body
+}
+
+func (ma *MatchArm) TokenLiteral() string {
+	return ma.Token.Literal
+}
+
+func (ma *MatchArm) String() string {
+	out := ma.Pattern.String()
+
+	if ma.Guard != nil {
+		out += " if " + ma.Guard.String()
+	}
+
+	return out + " => " + ma.Body.String()
+}
+
+// -----------------------------
+// Patterns
+// -----------------------------
+
+// Pattern is matched against a value by `match`. An *Identifier binds any
+// value to its name, `_` matching anything without binding it
+type Pattern interface {
+	Node
+	Pattern()
+}
+
+func (i *Identifier) Pattern() {}
+
+// LiteralPattern matches the values equal to a number, possibly negated, a
+// string or a boolean
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) TokenLiteral() string {
+	return lp.Value.TokenLiteral()
+}
+
+func (lp *LiteralPattern) String() string {
+	return lp.Value.String()
+}
+
+func (lp *LiteralPattern) Pattern() {}
+
+// ArrayPattern is `[first, second, ...rest]`, matching arrays element by
+// element. Without a rest the array must have exactly as many elements
+type ArrayPattern struct {
+	Token    token.Token // the `[`
+	Elements []Pattern
+	Rest     *Identifier // nil without `...rest`
+}
+
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+func (ap *ArrayPattern) String() string {
+	elems := []string{}
+
+	for _, elem := range ap.Elements {
+		elems = append(elems, elem.String())
+	}
+
+	if ap.Rest != nil {
+		elems = append(elems, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+func (ap *ArrayPattern) Pattern() {}
+
+// HashPattern is `{"key": pattern, name}`, matching hashmaps holding every
+// key. A lone name is short for `"name": name`
+type HashPattern struct {
+	Token  token.Token  // the `{`
+	Keys   []Expression // literals, in source order
+	Values []Pattern    // pattern of each key
+}
+
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+
+func (hp *HashPattern) String() string {
+	entries := []string{}
+
+	for i, key := range hp.Keys {
+		if hp.Shorthand(i) {
+			entries = append(entries, hp.Values[i].String())
+		} else {
+			entries = append(entries, key.String()+": "+hp.Values[i].String())
+		}
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (hp *HashPattern) Pattern() {}
+
+// Shorthand reports whether the i-th entry is a lone name
+func (hp *HashPattern) Shorthand(i int) bool {
+	key, ok := hp.Keys[i].(*StringLiteralExpression)
+	ident, isIdent := hp.Values[i].(*Identifier)
+
+	// the key of a lone name is made from the token of the name
+	return ok && isIdent && key.Token == ident.Token
+}
+
+// AlternativePattern is `first | second`, matching what any alternative
+// matches. Alternatives do not bind names
+type AlternativePattern struct {
+	Alternatives []Pattern
+}
+
+func (ap *AlternativePattern) TokenLiteral() string {
+	return ap.Alternatives[0].TokenLiteral()
+}
+
+func (ap *AlternativePattern) String() string {
+	alts := []string{}
+
+	for _, alt := range ap.Alternatives {
+		alts = append(alts, alt.String())
+	}
+
+	return strings.Join(alts, " | ")
+}
+
+func (ap *AlternativePattern) Pattern() {}
+
 // -----------------------------
 // Block Statement
 // -----------------------------
 type BlockStatement struct {
 	Token      token.Token // the `{`
 	Statements []Statement
+	Rbrace     token.Token // the `}`
 }
 
 func (bs *BlockStatement) TokenLiteral() string {
@@ -332,12 +532,136 @@ func (ws *WhileStatement) String() string {
 
 func (ws *WhileStatement) Statement() {}
 
+// -----------------------------
+// Function Statement
+// -----------------------------
+
+// FunctionStatement is `fn name(params) { body }`. It is hoisted: the name
+// is bound before the other statements of the program or function body
+// declaring it run
+type FunctionStatement struct {
+	Token    token.Token // the `fn` token
+	Name     *Identifier
+	Function *FunctionLiteralExpression
+}
+
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *FunctionStatement) String() string {
+	return fs.Token.Literal + " " + fs.Name.String() + strings.TrimPrefix(fs.Function.String(), fs.Function.Token.Literal)
+}
+
+func (fs *FunctionStatement) Statement() {}
+
+// -----------------------------
+// Struct Statement
+// -----------------------------
+
+// StructStatement is `struct Name { field, ... }`, declaring a type whose
+// values hold exactly those fields. Name is bound to the constructor taking
+// the fields in order
+type StructStatement struct {
+	Token  token.Token // the `struct` token
+	Name   *Identifier
+	Fields []*Identifier
+	Rbrace token.Token // the `}`
+}
+
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+
+func (ss *StructStatement) String() string {
+	fields := []string{}
+
+	for _, field := range ss.Fields {
+		fields = append(fields, field.String())
+	}
+
+	if len(fields) == 0 {
+		return ss.Token.Literal + " " + ss.Name.String() + " {}"
+	}
+
+	return ss.Token.Literal + " " + ss.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+func (ss *StructStatement) Statement() {}
+
+// -----------------------------
+// Impl Statement
+// -----------------------------
+
+// ImplStatement is `impl Type { methods }`, adding methods to a struct, or
+// `impl Trait for Type { methods }`, implementing a trait for it. A method
+// is called on a value of the struct, which it takes as its first
+// parameter, `self` by convention
+type ImplStatement struct {
+	Token   token.Token // the `impl` token
+	Trait   *Identifier // nil when no trait is implemented
+	Type    *Identifier
+	Methods []*FunctionStatement
+	Rbrace  token.Token // the `}`
+}
+
+func (is *ImplStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImplStatement) String() string {
+	header := is.Token.Literal + " " + is.Type.String()
+
+	if is.Trait != nil {
+		header = is.Token.Literal + " " + is.Trait.String() + " for " + is.Type.String()
+	}
+
+	return header + " " + methodsString(is.Methods)
+}
+
+func (is *ImplStatement) Statement() {}
+
+// -----------------------------
+// Trait Statement
+// -----------------------------
+
+// TraitStatement is `trait Name { methods }`. A method without a body,
+// `fn name(self);`, must be provided by every implementation, the others
+// are defaults used when an implementation leaves them out
+type TraitStatement struct {
+	Token   token.Token // the `trait` token
+	Name    *Identifier
+	Methods []*FunctionStatement
+	Rbrace  token.Token // the `}`
+}
+
+func (ts *TraitStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *TraitStatement) String() string {
+	return ts.Token.Literal + " " + ts.Name.String() + " " + methodsString(ts.Methods)
+}
+
+func (ts *TraitStatement) Statement() {}
+
+func methodsString(methods []*FunctionStatement) string {
+	out := []string{}
+
+	for _, method := range methods {
+		out = append(out, method.String())
+	}
+
+	return "{ " + strings.Join(out, " ") + " }"
+}
+
 // -----------------------------
 // Function Literal Expression
 // -----------------------------
 type FunctionLiteralExpression struct {
 	Token      token.Token // the `fn` token
-	Parameters []*Identifier
+	Parameters []*Parameter
+	ReturnType *TypeAnnotation // nil when the result is not annotated
 	Body       *BlockStatement
 }
 
@@ -360,19 +684,129 @@ func (fle *FunctionLiteralExpression) String() string {
 	out.WriteString(strings.Join(params, ", "))
 
 	out.WriteString(")")
-	out.WriteString(fle.Body.String())
+
+	if fle.ReturnType != nil {
+		out.WriteString(" -> ")
+		out.WriteString(fle.ReturnType.String())
+	}
+
+	// the methods a trait requires have no body
+	if fle.Body != nil {
+		out.WriteString(fle.Body.String())
+	}
 
 	return out.String()
 }
 
 func (fe *FunctionLiteralExpression) Expression() {}
 
+// -----------------------------
+// Function Parameter
+// -----------------------------
+
+// Parameter is `name`, `name: type` or `name = default`. A rest parameter
+// `...name` collects the remaining arguments into an array, its type being
+// the one of each element. A parameter can also destructure its argument
+// with an array or hashmap pattern
+type Parameter struct {
+	Name    *Identifier
+	Pattern Pattern         // `[a, b]` or `{name}` when destructuring, Name being nil then
+	Type    *TypeAnnotation // nil when the parameter is not annotated
+	Default Expression      // evaluated when the argument is missing, nil when required
+	Rest    bool
+}
+
+func (p *Parameter) TokenLiteral() string {
+	return p.Target().TokenLiteral()
+}
+
+// Target is what the parameter binds, its name or its pattern
+func (p *Parameter) Target() Pattern {
+	if p.Pattern != nil {
+		return p.Pattern
+	}
+
+	return p.Name
+}
+
+func (p *Parameter) String() string {
+	out := p.Target().String()
+
+	if p.Rest {
+		out = "..." + out
+	}
+
+	if p.Type != nil {
+		out += ": " + p.Type.String()
+	}
+
+	if p.Default != nil {
+		out += " = " + p.Default.String()
+	}
+
+	return out
+}
+
+// -----------------------------
+// Type Annotation
+// -----------------------------
+
+// TypeAnnotation is the type written after a binding, a parameter or `->`:
+// a name such as `int`, an array `[T]`, a hashmap `{K: V}` or a function
+// `fn(T, U) -> R`
+type TypeAnnotation struct {
+	Token    token.Token       // the name, `[`, `{` or `fn`
+	Name     string            // set for named types only
+	Key      *TypeAnnotation   // key of a hashmap
+	Elem     *TypeAnnotation   // element of an array, value of a hashmap
+	Params   []*TypeAnnotation // parameters of a function
+	Variadic bool              // the last parameter of a function is `...T`
+	Return   *TypeAnnotation   // result of a function, nil when not annotated
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string {
+	return ta.Token.Literal
+}
+
+func (ta *TypeAnnotation) String() string {
+	switch ta.Token.Type {
+	case token.LBRACKET:
+		return "[" + ta.Elem.String() + "]"
+
+	case token.LBRACE:
+		return "{" + ta.Key.String() + ": " + ta.Elem.String() + "}"
+
+	case token.FUNCTION:
+		params := []string{}
+
+		for i, param := range ta.Params {
+			if ta.Variadic && i == len(ta.Params)-1 {
+				params = append(params, "..."+param.String())
+			} else {
+				params = append(params, param.String())
+			}
+		}
+
+		out := "fn(" + strings.Join(params, ", ") + ")"
+
+		if ta.Return != nil {
+			out += " -> " + ta.Return.String()
+		}
+
+		return out
+
+	default:
+		return ta.Name
+	}
+}
+
 // -----------------------------
 // Expression List
 // -----------------------------
 type ExpressionList struct {
 	Token token.Token // the `(` token
 	List  []Expression
+	End   token.Token // the closing `)` or `]`
 }
 
 func (el *ExpressionList) TokenLiteral() string {
@@ -398,6 +832,69 @@ func (el *ExpressionList) String() string {
 
 func (el *ExpressionList) Expression() {}
 
+// -----------------------------
+// Index Assignment Expression
+// -----------------------------
+
+// IndexAssignmentExpression is `array[index] = value` or `hashmap[key] = value`
+type IndexAssignmentExpression struct {
+	Token  token.Token // the `=` token
+	Target *IndexExpression
+	Value  Expression
+}
+
+func (iae *IndexAssignmentExpression) TokenLiteral() string {
+	return iae.Token.Literal
+}
+
+func (iae *IndexAssignmentExpression) String() string {
+	return iae.Target.String() + " = " + iae.Value.String()
+}
+
+func (iae *IndexAssignmentExpression) Expression() {}
+
+// -----------------------------
+// Member Assignment Expression
+// -----------------------------
+
+// MemberAssignmentExpression is `value.field = value`
+type MemberAssignmentExpression struct {
+	Token  token.Token // the `=` token
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (mae *MemberAssignmentExpression) TokenLiteral() string {
+	return mae.Token.Literal
+}
+
+func (mae *MemberAssignmentExpression) String() string {
+	return mae.Target.String() + " = " + mae.Value.String()
+}
+
+func (mae *MemberAssignmentExpression) Expression() {}
+
+// -----------------------------
+// Spread Expression
+// -----------------------------
+
+// SpreadExpression is `...array` in the arguments of a call, passing each
+// element as its own argument
+type SpreadExpression struct {
+	Token token.Token // the `...` token
+	Value Expression
+}
+
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+func (se *SpreadExpression) Expression() {}
+
 // -----------------------------
 // Function Call Expression
 // -----------------------------
@@ -484,6 +981,28 @@ func (ie *IndexExpression) String() string {
 
 func (ie *IndexExpression) Expression() {}
 
+// -----------------------------
+// Member Expression
+// -----------------------------
+
+// MemberExpression is `value.member`, reading a field of a struct, a string
+// key of a hashmap or a builtin method of the value
+type MemberExpression struct {
+	Token  token.Token // the `.` token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Member.String()
+}
+
+func (me *MemberExpression) Expression() {}
+
 // -----------------------------
 // String Literal Expression
 // -----------------------------
@@ -506,8 +1025,10 @@ func (sle *StringLiteralExpression) Expression() {}
 // HashMap Literal Expression
 // -----------------------------
 type HashmapLiteralExpression struct {
-	Token token.Token
-	Map   map[Expression]Expression
+	Token  token.Token
+	Map    map[Expression]Expression
+	Keys   []Expression // keys of Map in source order
+	Rbrace token.Token  // the `}`
 }
 
 func (hle *HashmapLiteralExpression) TokenLiteral() string {
@@ -518,8 +1039,8 @@ func (hle *HashmapLiteralExpression) String() string {
 	var out bytes.Buffer
 
 	elems := []string{}
-	for k, v := range hle.Map {
-		elems = append(elems, k.String()+":"+v.String())
+	for _, k := range hle.Keys {
+		elems = append(elems, k.String()+":"+hle.Map[k].String())
 	}
 
 	out.WriteString("{")
@@ -548,6 +1069,7 @@ func (ae *AssignmentExpression) String() string {
 	out.WriteString(ae.Ident.String())
 	out.WriteString(" = ")
 	out.WriteString(ae.Value.String())
+	out.WriteString(")")
 
 	return out.String()
 }
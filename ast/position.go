@@ -0,0 +1,120 @@
+package ast
+
+import "Klang/token"
+
+// StartToken returns the first token of node in the source
+func StartToken(node Node) token.Token {
+	switch node := node.(type) {
+	case *LetStatement:
+		return node.Token
+
+	case *FunctionStatement:
+		return node.Token
+
+	case *StructStatement:
+		return node.Token
+
+	case *ImplStatement:
+		return node.Token
+
+	case *TraitStatement:
+		return node.Token
+
+	case *ReturnStatement:
+		return node.Token
+
+	case *WhileStatement:
+		return node.Token
+
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			return StartToken(node.Expression)
+		}
+
+		return node.Token
+
+	case *InfixExpression:
+		return StartToken(node.Left)
+
+	case *AssignmentExpression:
+		return node.Ident.Token
+
+	case *IndexAssignmentExpression:
+		return StartToken(node.Target)
+
+	case *MemberAssignmentExpression:
+		return StartToken(node.Target)
+
+	case *FunctionCallExpression:
+		return StartToken(node.Function)
+
+	case *IndexExpression:
+		return StartToken(node.Ident)
+
+	case *MemberExpression:
+		return StartToken(node.Object)
+
+	case *Identifier:
+		return node.Token
+
+	case *IntegerLiteral:
+		return node.Token
+
+	case *FloatLiteral:
+		return node.Token
+
+	case *StringLiteralExpression:
+		return node.Token
+
+	case *BooleanLiteral:
+		return node.Token
+
+	case *PrefixExpression:
+		return node.Token
+
+	case *IfExpression:
+		return node.Token
+
+	case *MatchExpression:
+		return node.Token
+
+	case *MatchArm:
+		return StartToken(node.Pattern)
+
+	case *LiteralPattern:
+		return StartToken(node.Value)
+
+	case *ArrayPattern:
+		return node.Token
+
+	case *HashPattern:
+		return node.Token
+
+	case *AlternativePattern:
+		return StartToken(node.Alternatives[0])
+
+	case *FunctionLiteralExpression:
+		return node.Token
+
+	case *ArrayLiteralExpression:
+		return node.Token
+
+	case *HashmapLiteralExpression:
+		return node.Token
+
+	case *BlockStatement:
+		return node.Token
+
+	case *SpreadExpression:
+		return node.Token
+
+	case *Parameter:
+		return StartToken(node.Target())
+
+	case *TypeAnnotation:
+		return node.Token
+
+	default:
+		return token.Token{}
+	}
+}
@@ -0,0 +1,147 @@
+package ast
+
+import (
+	"Klang/token"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Fprint writes node to w as an indented tree. Nodes whose fields are all
+// plain values are written on a single line, tokens and fields tagged
+// `ast:"-"` are left out
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{w: w}
+	p.print(reflect.ValueOf(node), 0)
+	p.write("\n")
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) write(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *printer) newline(depth int) {
+	p.write("\n%s", strings.Repeat("  ", depth))
+}
+
+func (p *printer) print(val reflect.Value, depth int) {
+	switch val.Kind() {
+	case reflect.Invalid:
+		p.write("nil")
+
+	case reflect.Interface, reflect.Ptr:
+		if val.IsNil() {
+			p.write("nil")
+			return
+		}
+
+		p.print(val.Elem(), depth)
+
+	case reflect.Struct:
+		p.write("%s", val.Type().Name())
+		fields := nodeFields(val)
+
+		if isInline(fields) {
+			for _, field := range fields {
+				p.write(" %s=", field.name)
+				p.print(field.value, depth)
+			}
+
+			return
+		}
+
+		for _, field := range fields {
+			p.newline(depth + 1)
+			p.write("%s: ", field.name)
+			p.print(field.value, depth+1)
+		}
+
+	case reflect.Slice:
+		if val.Len() == 0 {
+			p.write("[]")
+			return
+		}
+
+		p.write("[")
+
+		for i := 0; i < val.Len(); i++ {
+			p.newline(depth + 1)
+			p.print(val.Index(i), depth+1)
+		}
+
+		p.newline(depth)
+		p.write("]")
+
+	case reflect.Map:
+		if val.Len() == 0 {
+			p.write("{}")
+			return
+		}
+
+		p.write("{")
+		iter := val.MapRange()
+
+		for iter.Next() {
+			p.newline(depth + 1)
+			p.print(iter.Key(), depth+1)
+			p.write(" => ")
+			p.print(iter.Value(), depth+1)
+		}
+
+		p.newline(depth)
+		p.write("}")
+
+	case reflect.String:
+		p.write("%q", val.String())
+
+	default:
+		p.write("%v", val.Interface())
+	}
+}
+
+type nodeField struct {
+	name  string
+	value reflect.Value
+}
+
+func nodeFields(val reflect.Value) []nodeField {
+	fields := []nodeField{}
+
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+
+		if field.PkgPath != "" || isTokenType(field.Type) || field.Tag.Get("ast") == "-" {
+			continue
+		}
+
+		fields = append(fields, nodeField{name: field.Name, value: val.Field(i)})
+	}
+
+	return fields
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+func isTokenType(typ reflect.Type) bool {
+	return typ == tokenType || (typ.Kind() == reflect.Slice && typ.Elem() == tokenType)
+}
+
+func isInline(fields []nodeField) bool {
+	for _, field := range fields {
+		switch field.value.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Struct, reflect.Slice, reflect.Map:
+			return false
+		}
+	}
+
+	return true
+}
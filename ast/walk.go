@@ -0,0 +1,186 @@
+package ast
+
+// Inspect traverses the tree rooted at node in source order, calling f for
+// each node. The children of a node are skipped when f returns false
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Inspect(child, f)
+	}
+}
+
+// children returns the direct children of node, nil ones left out
+func children(node Node) []Node {
+	nodes := []Node{}
+
+	add := func(children ...Node) {
+		for _, child := range children {
+			if !isNil(child) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			add(stmt)
+		}
+
+	case *LetStatement:
+		add(node.Name, node.Pattern, node.Type, node.Value)
+
+	case *FunctionStatement:
+		add(node.Name, node.Function)
+
+	case *ImplStatement:
+		add(node.Trait, node.Type)
+
+		for _, method := range node.Methods {
+			add(method)
+		}
+
+	case *TraitStatement:
+		add(node.Name)
+
+		for _, method := range node.Methods {
+			add(method)
+		}
+
+	case *StructStatement:
+		add(node.Name)
+
+		for _, field := range node.Fields {
+			add(field)
+		}
+
+	case *ReturnStatement:
+		add(node.ReturnValue)
+
+	case *ExpressionStatement:
+		add(node.Expression)
+
+	case *WhileStatement:
+		add(node.Condition, node.Body)
+
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			add(stmt)
+		}
+
+	case *PrefixExpression:
+		add(node.Right)
+
+	case *InfixExpression:
+		add(node.Left, node.Right)
+
+	case *AssignmentExpression:
+		add(node.Ident, node.Value)
+
+	case *IndexAssignmentExpression:
+		add(node.Target, node.Value)
+
+	case *MemberAssignmentExpression:
+		add(node.Target, node.Value)
+
+	case *IfExpression:
+		add(node.Condition, node.IfArm, node.ElseArm)
+
+	case *MatchExpression:
+		add(node.Value)
+
+		for _, arm := range node.Arms {
+			add(arm)
+		}
+
+	case *MatchArm:
+		add(node.Pattern, node.Guard, node.Body)
+
+	case *LiteralPattern:
+		add(node.Value)
+
+	case *ArrayPattern:
+		for _, elem := range node.Elements {
+			add(elem)
+		}
+
+		add(node.Rest)
+
+	case *HashPattern:
+		for i, key := range node.Keys {
+			add(key, node.Values[i])
+		}
+
+	case *AlternativePattern:
+		for _, alt := range node.Alternatives {
+			add(alt)
+		}
+
+	case *FunctionLiteralExpression:
+		for _, param := range node.Parameters {
+			add(param)
+		}
+
+		add(node.ReturnType, node.Body)
+
+	case *Parameter:
+		add(node.Name, node.Pattern, node.Type, node.Default)
+
+	case *SpreadExpression:
+		add(node.Value)
+
+	case *TypeAnnotation:
+		for _, param := range node.Params {
+			add(param)
+		}
+
+		add(node.Key, node.Elem, node.Return)
+
+	case *FunctionCallExpression:
+		add(node.Function, node.Args)
+
+	case *ExpressionList:
+		for _, expr := range node.List {
+			add(expr)
+		}
+
+	case *ArrayLiteralExpression:
+		add(node.Elements)
+
+	case *IndexExpression:
+		add(node.Ident, node.Index)
+
+	case *MemberExpression:
+		add(node.Object, node.Member)
+
+	case *HashmapLiteralExpression:
+		for _, key := range node.Keys {
+			add(key, node.Map[key])
+		}
+	}
+
+	return nodes
+}
+
+// isNil reports whether node is nil or a nil pointer wrapped in the interface
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	switch node := node.(type) {
+	case *Identifier:
+		return node == nil
+	case *BlockStatement:
+		return node == nil
+	case *ExpressionList:
+		return node == nil
+	case *TypeAnnotation:
+		return node == nil
+	}
+
+	return false
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"Klang/ast"
+	"Klang/eval"
+	"Klang/format"
+	"Klang/lexer"
+	"Klang/lint"
+	"Klang/lsp"
+	"Klang/object"
+	"Klang/parser"
+	"Klang/repl"
+	"Klang/resolve"
+	"Klang/token"
+	"Klang/typecheck"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// cli runs the commands of the command line with the given standard streams
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (c *cli) cmdRun(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(c.stderr, "usage: klang run <file.mk> [args...]")
+		return 2
+	}
+
+	source, ok := c.readSource(args[0])
+
+	if !ok {
+		return 1
+	}
+
+	program, ok := c.parse(args[0], source)
+
+	if !ok {
+		return 1
+	}
+
+	evaluator := eval.New(c.stdout, c.stderr)
+	evaluator.Args = args[1:]
+
+	if errors := typecheck.Check(program, evaluator.BuiltinNames()); len(errors) > 0 {
+		for _, err := range errors {
+			fmt.Fprintf(c.stderr, "%s:%s\n", args[0], err)
+		}
+
+		return 1
+	}
+
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+
+	if code, ok := exitCode(evaluated); ok {
+		return code
+	}
+
+	if evaluated.Type() == object.OBJECT_ERROR {
+		fmt.Fprintf(c.stderr, "%s: %s\n", args[0], evaluated.Inspect())
+		return 1
+	}
+
+	return 0
+}
+
+// exitCode returns the status passed to `exit` when it stopped the script
+// that evaluated to result
+func exitCode(result object.Object) (int, bool) {
+	err, ok := result.(*object.Error)
+
+	if !ok {
+		return 0, false
+	}
+
+	var exit *eval.ExitError
+
+	if !errors.As(err.Err, &exit) {
+		return 0, false
+	}
+
+	return exit.Code, true
+}
+
+func (c *cli) cmdRepl(args []string) int {
+	repl.Start(c.stdin, c.stdout)
+	return 0
+}
+
+func (c *cli) cmdExpr(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(c.stderr, "usage: klang -e <expr>")
+		return 2
+	}
+
+	program, ok := c.parse("-e", args[0])
+
+	if !ok {
+		return 1
+	}
+
+	evaluator := eval.New(c.stdout, c.stderr)
+	evaluator.Args = args[1:]
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+
+	if code, ok := exitCode(evaluated); ok {
+		return code
+	}
+
+	if ret, ok := evaluated.(*object.Return); ok {
+		evaluated = ret.Value
+	}
+
+	switch evaluated.Type() {
+	case object.OBJECT_ERROR:
+		fmt.Fprintln(c.stderr, evaluated.Inspect())
+		return 1
+
+	case object.OBJECT_NILL:
+		return 0
+
+	default:
+		fmt.Fprintln(c.stdout, evaluated.Inspect())
+		return 0
+	}
+}
+
+func (c *cli) cmdCheck(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(c.stderr, "usage: klang check <file.mk>...")
+		return 2
+	}
+
+	code := 0
+
+	for _, name := range args {
+		source, ok := c.readSource(name)
+
+		if !ok {
+			code = 1
+			continue
+		}
+
+		program, ok := c.parse(name, source)
+
+		if !ok {
+			code = 1
+			continue
+		}
+
+		predeclared := eval.New(io.Discard, io.Discard).BuiltinNames()
+		info := resolve.Resolve(program, predeclared)
+
+		for _, diag := range info.Diagnostics {
+			fmt.Fprintf(c.stderr, "%s:%s\n", name, diag)
+
+			if diag.Severity == resolve.Error {
+				code = 1
+			}
+		}
+
+		for _, err := range typecheck.Check(program, predeclared) {
+			fmt.Fprintf(c.stderr, "%s:%s\n", name, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func (c *cli) cmdTokens(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: klang tokens <file.mk>")
+		return 2
+	}
+
+	source, ok := c.readSource(args[0])
+
+	if !ok {
+		return 1
+	}
+
+	l := lexer.New(source)
+
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(c.stdout, "%d:%d\t%-14s %s\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+
+		if tok.Type == token.EOF {
+			return 0
+		}
+	}
+}
+
+func (c *cli) cmdAst(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: klang ast <file.mk>")
+		return 2
+	}
+
+	source, ok := c.readSource(args[0])
+
+	if !ok {
+		return 1
+	}
+
+	program, ok := c.parse(args[0], source)
+
+	if !ok {
+		return 1
+	}
+
+	ast.Fprint(c.stdout, program)
+	return 0
+}
+
+func (c *cli) cmdFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	write := flags.Bool("w", false, "rewrite the files in place")
+	list := flags.Bool("l", false, "list files whose layout differs")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: klang fmt [-w] [-l] <file.mk>...")
+		return 2
+	}
+
+	code := 0
+
+	for _, name := range flags.Args() {
+		source, ok := c.readSource(name)
+
+		if !ok {
+			code = 1
+			continue
+		}
+
+		formatted, err := format.Source(source)
+
+		if err != nil {
+			fmt.Fprintf(c.stderr, "%s:%s\n", name, err)
+			code = 1
+			continue
+		}
+
+		if *list {
+			if formatted != source {
+				fmt.Fprintln(c.stdout, name)
+				code = 1
+			}
+
+			continue
+		}
+
+		if *write && name != "-" {
+			if formatted == source {
+				continue
+			}
+
+			if err := os.WriteFile(name, []byte(formatted), 0644); err != nil {
+				fmt.Fprintf(c.stderr, "klang: %s\n", err)
+				code = 1
+			}
+
+			continue
+		}
+
+		fmt.Fprint(c.stdout, formatted)
+	}
+
+	return code
+}
+
+func (c *cli) cmdLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	asJSON := flags.Bool("json", false, "print the issues as JSON")
+	enable := flags.String("enable", "", "comma separated rules to run, all of them when empty")
+	disable := flags.String("disable", "", "comma separated rules to skip")
+	list := flags.Bool("rules", false, "list the rules and exit")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(c.stdout, "%-20s %-8s %s\n", rule.Name, rule.Severity, rule.Doc)
+		}
+
+		return 0
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(c.stderr, "usage: klang lint [-json] [-enable rules] [-disable rules] <file.mk>...")
+		return 2
+	}
+
+	rules, err := lint.Select(splitList(*enable), splitList(*disable))
+
+	if err != nil {
+		fmt.Fprintf(c.stderr, "klang: %s\n", err)
+		return 2
+	}
+
+	type fileIssue struct {
+		File string `json:"file"`
+		*lint.Issue
+	}
+
+	predeclared := eval.New(io.Discard, io.Discard).BuiltinNames()
+	issues := []fileIssue{}
+	code := 0
+
+	for _, name := range flags.Args() {
+		source, ok := c.readSource(name)
+
+		if !ok {
+			code = 1
+			continue
+		}
+
+		program, ok := c.parse(name, source)
+
+		if !ok {
+			code = 1
+			continue
+		}
+
+		for _, issue := range lint.Run(program, predeclared, rules) {
+			issues = append(issues, fileIssue{File: name, Issue: issue})
+		}
+	}
+
+	if len(issues) > 0 {
+		code = 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(issues)
+		return code
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintf(c.stdout, "%s:%s\n", issue.File, issue.Issue)
+	}
+
+	return code
+}
+
+// splitList splits a comma separated flag value, ignoring empty items
+func splitList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func (c *cli) cmdLsp(args []string) int {
+	if err := lsp.NewServer(c.stdin, c.stdout).Serve(); err != nil {
+		fmt.Fprintf(c.stderr, "klang: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// readSource reads name, or the standard input when name is `-`
+func (c *cli) readSource(name string) (string, bool) {
+	var content []byte
+	var err error
+
+	if name == "-" {
+		content, err = io.ReadAll(c.stdin)
+	} else {
+		content, err = os.ReadFile(name)
+	}
+
+	if err != nil {
+		fmt.Fprintf(c.stderr, "klang: %s\n", err)
+		return "", false
+	}
+
+	return string(content), true
+}
+
+// parse reports every syntax error of source as `name:line:column: message`
+func (c *cli) parse(name, source string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	for _, err := range p.Errors() {
+		fmt.Fprintf(c.stderr, "%s:%s\n", name, err)
+	}
+
+	return program, len(p.Errors()) == 0
+}
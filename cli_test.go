@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cliResult is what a run of the command line left behind
+type cliResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func runTestCli(stdin string, args ...string) cliResult {
+	var stdout, stderr bytes.Buffer
+	code := runCli(args, strings.NewReader(stdin), &stdout, &stderr)
+	return cliResult{code, stdout.String(), stderr.String()}
+}
+
+// writeScripts writes files, named by their base name, in a temporary
+// directory and returns the path of each
+func writeScripts(t *testing.T, files map[string]string) map[string]string {
+	dir := t.TempDir()
+	paths := make(map[string]string)
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		paths[name] = path
+	}
+
+	return paths
+}
+
+func TestCli(t *testing.T) {
+	paths := writeScripts(t, map[string]string{
+		"hello.mk":   "let who = \"world\";\nprintln(\"hello \" + who);\n",
+		"args.mk":    "println(args());\n",
+		"syntax.mk":  "let = 1;\n",
+		"runtime.mk": "println(\"before\");\nlet f = fn(a) { a / 0 };\nf(1);\n",
+		"types.mk":   "let x: int = \"a\";\n",
+		"unused.mk":  "let unused = 1;\n",
+		"messy.mk":   "let x=1\n",
+		"exit.mk":    "println(\"bye\");\nfn f() { exit(4) }\nf();\nprintln(\"after\");\n",
+	})
+
+	missing := filepath.Join(filepath.Dir(paths["hello.mk"]), "missing.mk")
+
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected cliResult
+	}{
+		{[]string{"version"}, "", cliResult{0, "klang " + VERSION + "\n", ""}},
+		{[]string{"--version"}, "", cliResult{0, "klang " + VERSION + "\n", ""}},
+		{[]string{"help"}, "", cliResult{0, USAGE, ""}},
+		{[]string{"nope"}, "", cliResult{2, "", "klang: unknown command nope\n\n" + USAGE}},
+
+		{[]string{"-e", "1 + 2"}, "", cliResult{0, "3\n", ""}},
+		{[]string{"-e", "let x = 1"}, "", cliResult{0, "", ""}},
+		{[]string{"-e", "print(args())", "a", "b"}, "", cliResult{0, "[a, b]\n", ""}},
+		{[]string{"-e", "let = 1"}, "", cliResult{1, "", "-e:1:5: expected next token to be IDENTIFIER, got ASSIGN instead\n-e:1:5: unexpected token ASSIGN `=`\n"}},
+		{[]string{"-e", "1 + \"a\""}, "", cliResult{1, "", "error: type mismatch: OBJECT_INTEGER + OBJECT_STRING\n"}},
+		{[]string{"-e", "println(1); exit(3); println(2)"}, "", cliResult{3, "1\n", ""}},
+		{[]string{"-e", "exit()"}, "", cliResult{0, "", ""}},
+		{[]string{"-e"}, "", cliResult{2, "", "usage: klang -e <expr>\n"}},
+
+		{[]string{"run", paths["hello.mk"]}, "", cliResult{0, "hello world\n", ""}},
+		{[]string{paths["hello.mk"]}, "", cliResult{0, "hello world\n", ""}},
+		{[]string{"run", paths["args.mk"], "x", "y"}, "", cliResult{0, "[x, y]\n", ""}},
+		{[]string{"run", "-"}, "println(6 * 7)", cliResult{0, "42\n", ""}},
+		{[]string{"-"}, "println(1)", cliResult{0, "1\n", ""}},
+		{[]string{"run", paths["syntax.mk"]}, "", cliResult{1, "", paths["syntax.mk"] + ":1:5: expected next token to be IDENTIFIER, got ASSIGN instead\n" + paths["syntax.mk"] + ":1:5: unexpected token ASSIGN `=`\n"}},
+		{[]string{"run", paths["runtime.mk"]}, "", cliResult{1, "before\n", paths["runtime.mk"] + ": error: division by zero\n\tin f called at 3:1\n"}},
+		{[]string{"run", paths["types.mk"]}, "", cliResult{1, "", paths["types.mk"] + ":1:14: cannot use string as int in the declaration of x\n"}},
+		{[]string{"run", missing}, "", cliResult{1, "", "klang: open " + missing + ": no such file or directory\n"}},
+		{[]string{"run", paths["exit.mk"]}, "", cliResult{4, "bye\n", ""}},
+		{[]string{"run"}, "", cliResult{2, "", "usage: klang run <file.mk> [args...]\n"}},
+
+		{[]string{"check", paths["hello.mk"]}, "", cliResult{0, "", ""}},
+		{[]string{"check", paths["unused.mk"]}, "", cliResult{0, "", paths["unused.mk"] + ":1:5: warning: unused is declared but never used\n"}},
+		{[]string{"check", paths["types.mk"], paths["hello.mk"]}, "", cliResult{1, "", paths["types.mk"] + ":1:5: warning: x is declared but never used\n" + paths["types.mk"] + ":1:14: cannot use string as int in the declaration of x\n"}},
+		{[]string{"check", "-"}, "let = 1", cliResult{1, "", "-:1:5: expected next token to be IDENTIFIER, got ASSIGN instead\n-:1:5: unexpected token ASSIGN `=`\n"}},
+		{[]string{"check"}, "", cliResult{2, "", "usage: klang check <file.mk>...\n"}},
+
+		{[]string{"tokens", "-"}, "x", cliResult{0, "1:1\tIDENTIFIER     x\n1:2\tEOF            EOF\n", ""}},
+		{[]string{"tokens"}, "", cliResult{2, "", "usage: klang tokens <file.mk>\n"}},
+
+		{[]string{"ast", "-"}, "1", cliResult{0, "Program\n  Statements: [\n    ExpressionStatement\n      Expression: IntegerLiteral Value=1\n  ]\n", ""}},
+		{[]string{"ast", "-"}, "let = 1", cliResult{1, "", "-:1:5: expected next token to be IDENTIFIER, got ASSIGN instead\n-:1:5: unexpected token ASSIGN `=`\n"}},
+		{[]string{"ast"}, "", cliResult{2, "", "usage: klang ast <file.mk>\n"}},
+
+		{[]string{"fmt", "-"}, "let x=1", cliResult{0, "let x = 1;\n", ""}},
+		{[]string{"fmt", "-l", paths["messy.mk"], paths["hello.mk"]}, "", cliResult{1, paths["messy.mk"] + "\n", ""}},
+		{[]string{"fmt", "-"}, "let = 1", cliResult{1, "", "-:1:5: expected next token to be IDENTIFIER, got ASSIGN instead\n"}},
+		{[]string{"fmt"}, "", cliResult{2, "", "usage: klang fmt [-w] [-l] <file.mk>...\n"}},
+		{[]string{"fmt", "-x"}, "", cliResult{2, "", "flag provided but not defined: -x\nUsage of fmt:\n  -l\tlist files whose layout differs\n  -w\trewrite the files in place\n"}},
+
+		{[]string{"lint", paths["hello.mk"]}, "", cliResult{0, "", ""}},
+		{[]string{"lint", paths["unused.mk"]}, "", cliResult{1, paths["unused.mk"] + ":1:5: unused is declared but never used (unused)\n", ""}},
+		{[]string{"lint", "-enable", "nope", paths["hello.mk"]}, "", cliResult{2, "", "klang: unknown rule nope\n"}},
+		{[]string{"lint"}, "", cliResult{2, "", "usage: klang lint [-json] [-enable rules] [-disable rules] <file.mk>...\n"}},
+
+		{[]string{"repl"}, "1 + 1\n", cliResult{0, "Welcome To K Programming Language\nType :help for the list of commands\n>> 2\n>> ", ""}},
+		{[]string{}, ":quit\n", cliResult{0, "Welcome To K Programming Language\nType :help for the list of commands\n>> ", ""}},
+		{[]string{"lsp"}, "", cliResult{0, "", ""}},
+	}
+
+	for _, tt := range tests {
+		if result := runTestCli(tt.stdin, tt.args...); result != tt.expected {
+			t.Errorf("klang %s: result is not matching expected.\nwant=%+v\ngot=%+v", strings.Join(tt.args, " "), tt.expected, result)
+		}
+	}
+}
+
+func TestCliFmtWrite(t *testing.T) {
+	paths := writeScripts(t, map[string]string{"messy.mk": "let x=1\n"})
+
+	if result := runTestCli("", "fmt", "-w", paths["messy.mk"]); result != (cliResult{0, "", ""}) {
+		t.Fatalf("fmt -w: unexpected result %+v", result)
+	}
+
+	content, err := os.ReadFile(paths["messy.mk"])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "let x = 1;\n" {
+		t.Fatalf("fmt -w did not rewrite the file, got=%q", content)
+	}
+}
+
+func TestCliLintRules(t *testing.T) {
+	result := runTestCli("", "lint", "-rules")
+
+	if result.code != 0 || !strings.Contains(result.stdout, "unused") {
+		t.Fatalf("lint -rules: unexpected result %+v", result)
+	}
+}
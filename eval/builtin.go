@@ -2,11 +2,13 @@ package eval
 
 import (
 	"Klang/object"
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"os"
 )
 
-type BuiltinFn func(args ...object.Object) object.Object
+type BuiltinFn func(e *Evaluator, args ...object.Object) object.Object
 
 func (bf BuiltinFn) Inspect() string {
 	return ""
@@ -17,7 +19,7 @@ func (bf BuiltinFn) Type() object.ObjectType {
 }
 
 var builtins = map[string]BuiltinFn{
-	"len": func(args ...object.Object) object.Object {
+	"len": func(e *Evaluator, args ...object.Object) object.Object {
 		length := len(args)
 
 		if length != 1 {
@@ -33,14 +35,221 @@ var builtins = map[string]BuiltinFn{
 
 		return &object.Integer{Value: int64(arrLen)}
 	},
-	"print": func(args ...object.Object) object.Object {
-		arguments := []string{}
+	"print": func(e *Evaluator, args ...object.Object) object.Object {
+		return e.writeArgs(e.Stdout, args, " ")
+	},
+	"println": func(e *Evaluator, args ...object.Object) object.Object {
+		return e.writeArgs(e.Stdout, args, "")
+	},
+	"eprint": func(e *Evaluator, args ...object.Object) object.Object {
+		return e.writeArgs(e.Stderr, args, " ")
+	},
+	"printf": func(e *Evaluator, args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return NILL
+		}
+
+		format, ok := args[0].(*object.String)
+
+		if !ok {
+			return NILL
+		}
+
+		values := []interface{}{}
+
+		for _, arg := range args[1:] {
+			value, err := e.nativeValue(arg)
 
-		for _, arg := range args {
-			arguments = append(arguments, arg.Inspect())
+			if err != nil {
+				return err
+			}
+
+			values = append(values, value)
+		}
+
+		lw := &limitWriter{e: e}
+
+		if fmt.Fprintf(lw, format.Value, values...); lw.err != nil {
+			return lw.err
 		}
 
-		fmt.Printf("%s\n", strings.Join(arguments, " "))
+		lw.flush(e.Stdout)
 		return NILL
 	},
+	"freeze": func(e *Evaluator, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("freeze expects a value")
+		}
+
+		freeze(args[0])
+		return args[0]
+	},
+}
+
+// systemBuiltins reach outside of the interpreter: the file system, the
+// environment and the process itself. Sandboxed evaluators leave them out
+var systemBuiltins = map[string]BuiltinFn{
+	"readFile": func(e *Evaluator, args ...object.Object) object.Object {
+		if len(args) != 1 || args[0].Type() != object.OBJECT_STRING {
+			return newError("readFile expects a path")
+		}
+
+		content, err := os.ReadFile(args[0].(*object.String).Value)
+
+		if err != nil {
+			return newError("readFile: %s", err)
+		}
+
+		if err := e.allocate(int64(len(content))); err != nil {
+			return err
+		}
+
+		return &object.String{Value: string(content)}
+	},
+	"writeFile": func(e *Evaluator, args ...object.Object) object.Object {
+		if len(args) != 2 || args[0].Type() != object.OBJECT_STRING {
+			return newError("writeFile expects a path and a content")
+		}
+
+		path := args[0].(*object.String).Value
+
+		content, limitErr := e.inspect(args[1])
+
+		if limitErr != nil {
+			return limitErr
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return newError("writeFile: %s", err)
+		}
+
+		return NILL
+	},
+	"getenv": func(e *Evaluator, args ...object.Object) object.Object {
+		if len(args) != 1 || args[0].Type() != object.OBJECT_STRING {
+			return newError("getenv expects a variable name")
+		}
+
+		val, ok := os.LookupEnv(args[0].(*object.String).Value)
+
+		if !ok {
+			return NILL
+		}
+
+		return &object.String{Value: val}
+	},
+	"args": func(e *Evaluator, args ...object.Object) object.Object {
+		arguments := []object.Object{}
+
+		for _, arg := range e.Args {
+			arguments = append(arguments, &object.String{Value: arg})
+		}
+
+		return &object.Array{Value: arguments}
+	},
+	"exit": func(e *Evaluator, args ...object.Object) object.Object {
+		code := 0
+
+		if len(args) == 1 {
+			if num, ok := args[0].(*object.Integer); ok {
+				code = int(num.Value)
+			}
+		}
+
+		return e.abort(&ExitError{Code: code}, "exit status %d", code)
+	},
+}
+
+// ErrExit is matched, through errors.Is, by the error of a script calling
+// `exit`. The evaluation stops there and the host decides what to do with
+// the process
+var ErrExit = errors.New("exit")
+
+// ExitError carries the status a script passed to `exit`
+type ExitError struct {
+	Code int
+}
+
+func (ee *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", ee.Code)
+}
+
+func (ee *ExitError) Is(target error) bool {
+	return target == ErrExit
+}
+
+// freeze makes obj immutable along with the arrays and hashmaps it holds
+func freeze(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		if obj.Frozen {
+			return
+		}
+
+		obj.Frozen = true
+
+		for _, elem := range obj.Value {
+			freeze(elem)
+		}
+
+	case *object.HashMap:
+		if obj.Frozen {
+			return
+		}
+
+		obj.Frozen = true
+
+		for _, val := range obj.Value {
+			freeze(val)
+		}
+
+	case *object.Struct:
+		if obj.Frozen {
+			return
+		}
+
+		obj.Frozen = true
+
+		for _, field := range obj.Fields {
+			freeze(field)
+		}
+	}
+}
+
+// writeArgs writes the inspected form of args joined by sep, followed by a
+// newline. The line is built within the limits of e before it is written
+func (e *Evaluator) writeArgs(w io.Writer, args []object.Object, sep string) object.Object {
+	lw := &limitWriter{e: e}
+
+	for i, arg := range args {
+		if i > 0 && sep != "" {
+			io.WriteString(lw, sep)
+		}
+
+		object.Fprint(lw, arg)
+	}
+
+	if io.WriteString(lw, "\n"); lw.err != nil {
+		return lw.err
+	}
+
+	lw.flush(w)
+	return NILL
+}
+
+// nativeValue unwraps scalar objects so printf verbs such as %d and %q
+// behave the same way they do in Go. Anything else is passed as its inspected form
+func (e *Evaluator) nativeValue(obj object.Object) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	default:
+		return e.inspect(obj)
+	}
 }
@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"Klang/ast"
+	"Klang/object"
+)
+
+// destructure declares the names of pattern in env, each bound to the part of
+// value it stands for. Unlike a match arm, a value that does not have the
+// shape of pattern is an error
+func (e *Evaluator) destructure(pattern ast.Pattern, value object.Object, env *object.Environment, constant bool) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return bindPattern(pattern, value, env, constant)
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+
+		if !ok {
+			return newError("cannot destructure %s from %s", pattern, value.Type())
+		}
+
+		length := len(array.Value)
+
+		if length < len(pattern.Elements) || (pattern.Rest == nil && length != len(pattern.Elements)) {
+			return newError("cannot destructure %s from an array of length %d", pattern, length)
+		}
+
+		for i, elem := range pattern.Elements {
+			if err := e.destructure(elem, array.Value[i], env, constant); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest == nil {
+			return nil
+		}
+
+		rest := append([]object.Object{}, array.Value[len(pattern.Elements):]...)
+		return bindPattern(pattern.Rest, &object.Array{Value: rest}, env, constant)
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.HashMap)
+
+		if !ok {
+			return newError("cannot destructure %s from %s", pattern, value.Type())
+		}
+
+		for i, key := range pattern.Keys {
+			hashable, ok := e.eval(key, env).(object.Hashable)
+
+			if !ok {
+				return newError("invalid key type in pattern: %s", key)
+			}
+
+			val, ok := hash.Value[hashable.Hashkey()]
+
+			if !ok {
+				return newError("cannot destructure %s: missing key %s", pattern, key)
+			}
+
+			if err := e.destructure(pattern.Values[i], val, env, constant); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return newError("cannot destructure into %s", pattern)
+}
@@ -3,7 +3,11 @@ package eval
 import (
 	"Klang/ast"
 	"Klang/object"
-	"log"
+	"Klang/token"
+	"context"
+	"fmt"
+	"io"
+	"sort"
 )
 
 var (
@@ -12,13 +16,123 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+// Evaluator holds the state shared by a single interpreter instance.
+// Every builtin writes through Stdout/Stderr, so a host can capture
+// the output of a script by supplying its own writers.
+type Evaluator struct {
+	Stdout   io.Writer
+	Stderr   io.Writer
+	Args     []string // returned to scripts by the `args` builtin
+	Limits   Limits
+	builtins map[string]BuiltinFn
+	methods  map[object.ObjectType]map[string]BuiltinFn
+	sandbox  bool
+	run      run
+}
+
+func New(stdout, stderr io.Writer) *Evaluator {
+	e := newEvaluator(stdout, stderr, builtins, systemBuiltins)
+	e.Limits.MaxDepth = DefaultMaxDepth
+	return e
+}
+
+// NewSandbox returns an evaluator for untrusted code. It has no access to
+// the file system, the environment or the process, and it starts with
+// SandboxLimits
+func NewSandbox(stdout, stderr io.Writer) *Evaluator {
+	e := newEvaluator(stdout, stderr, builtins)
+	e.Limits = SandboxLimits
+	e.sandbox = true
+	return e
+}
+
+func newEvaluator(stdout, stderr io.Writer, builtinSets ...map[string]BuiltinFn) *Evaluator {
+	e := &Evaluator{Stdout: stdout, Stderr: stderr, methods: builtinMethods}
+	e.builtins = make(map[string]BuiltinFn)
+
+	// each evaluator gets its own copy, so registering a builtin
+	// on one instance is never visible to another
+	for _, set := range builtinSets {
+		for name, fn := range set {
+			e.builtins[name] = fn
+		}
+	}
+
+	return e
+}
+
+// BuiltinNames returns the names of the builtins visible to scripts, sorted
+func (e *Evaluator) BuiltinNames() []string {
+	names := []string{}
+
+	for name := range e.builtins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Sandboxed reports whether e was created by NewSandbox
+func (e *Evaluator) Sandboxed() bool {
+	return e.sandbox
+}
+
+// Eval evaluates node in env without a deadline
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates node in env, stopping with a runtime error once
+// ctx is done or one of the evaluator Limits is exceeded
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) (result object.Object) {
+	cancel := e.begin(ctx)
+	defer e.end(cancel, &result)
+
+	return e.eval(node, env)
+}
+
+// Apply calls a K function or builtin with already evaluated arguments.
+// A `return` inside the function body is unwrapped, so the caller always
+// receives the returned value itself
+func (e *Evaluator) Apply(obj object.Object, args []object.Object) object.Object {
+	return e.ApplyContext(context.Background(), obj, args)
+}
+
+// ApplyContext is Apply bounded by ctx and the evaluator Limits
+func (e *Evaluator) ApplyContext(ctx context.Context, obj object.Object, args []object.Object) (result object.Object) {
+	cancel := e.begin(ctx)
+	defer e.end(cancel, &result)
+
+	return e.apply(obj, args, token.Token{})
+}
+
+// RegisterBuiltin makes fn callable from K code as name, replacing any builtin with the same name
+func (e *Evaluator) RegisterBuiltin(name string, fn BuiltinFn) {
+	e.builtins[name] = fn
+}
+
+// Builtin returns the builtin registered under name
+func (e *Evaluator) Builtin(name string) (BuiltinFn, bool) {
+	fn, ok := e.builtins[name]
+	return fn, ok
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		if err := e.hoist(node.Statements, env); err != nil {
+			return err
+		}
+
+		return e.evalProgram(node.Statements, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
@@ -27,9 +141,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Float{Value: float64(node.Value)}
 
 	case *ast.InfixExpression:
-		return evalInfixExpression(node, env)
+		return e.evalInfixExpression(node, env)
 
 	case *ast.StringLiteralExpression:
+		if err := e.allocate(int64(len(node.Value))); err != nil {
+			return err
+		}
+
 		return &object.String{Value: node.Value}
 
 	case *ast.BooleanLiteral:
@@ -39,60 +157,83 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return FALSE
 
 	case *ast.LetStatement:
-		return evalLetStatement(node, env)
+		return e.evalLetStatement(node, env)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.ArrayLiteralExpression:
-		return evalArrayLiteralExpression(node, env)
+		return e.evalArrayLiteralExpression(node, env)
 
 	case *ast.HashmapLiteralExpression:
-		return evalHashMapLiteralExpression(node, env)
+		return e.evalHashMapLiteralExpression(node, env)
 
 	case *ast.IndexExpression:
-		return evalIndexExpression(node, env)
+		return e.evalIndexExpression(node, env)
+
+	case *ast.MemberExpression:
+		return e.evalMemberExpression(node, env)
 
 	case *ast.PrefixExpression:
-		return evalPrefixExpression(node, env)
+		return e.evalPrefixExpression(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.WhileStatement:
-		return evalWhileStatement(node, env)
+		return e.evalWhileStatement(node, env)
 
 	case *ast.AssignmentExpression:
-		return evalAssignmentExpression(node, env)
+		return e.evalAssignmentExpression(node, env)
+
+	case *ast.IndexAssignmentExpression:
+		return e.evalIndexAssignmentExpression(node, env)
+
+	case *ast.MemberAssignmentExpression:
+		return e.evalMemberAssignmentExpression(node, env)
+
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env)
 
 	case *ast.FunctionLiteralExpression:
-		return evalFunctionLiteralExpression(node, env)
+		return e.evalFunctionLiteralExpression(node, env)
 
 	case *ast.FunctionCallExpression:
-		return evalFunctionCallExpression(node, env)
+		return e.evalFunctionCallExpression(node, env)
 
 	case *ast.ExpressionList:
-		return evalExpressionList(node, env)
+		return e.evalExpressionList(node, env)
 
 	case *ast.ReturnStatement:
-		return evalReturnStatement(node, env)
+		return e.evalReturnStatement(node, env)
+
+	case *ast.FunctionStatement:
+		return e.evalFunctionStatement(node, env)
+
+	case *ast.StructStatement:
+		return e.evalStructStatement(node, env)
+
+	case *ast.ImplStatement:
+		return e.evalImplStatement(node, env)
+
+	case *ast.TraitStatement:
+		return e.evalTraitStatement(node, env)
 
 	default:
-		log.Fatalf("Unhandled case for: %T", node)
-		return NILL
+		return newError("unhandled node: %T", node)
 	}
 }
 
-func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
-	var result object.Object
+func (e *Evaluator) evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
+	var result object.Object = NILL
 
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.eval(stmt, env)
 
-		if result.Type() == object.OBJECT_RETURN {
+		if result.Type() == object.OBJECT_RETURN || isError(result) {
 			return result
 		}
 	}
@@ -100,11 +241,59 @@ func evalProgram(statements []ast.Statement, env *object.Environment) object.Obj
 	return result
 }
 
-func evalInfixExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
-	left := Eval(node.Left, env).(*object.Integer).Value
-	right := Eval(node.Right, env).(*object.Integer).Value
+func (e *Evaluator) evalInfixExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	leftObj := e.eval(node.Left, env)
 
-	switch node.Operator {
+	if isError(leftObj) {
+		return leftObj
+	}
+
+	rightObj := e.eval(node.Right, env)
+
+	if isError(rightObj) {
+		return rightObj
+	}
+
+	if result, ok := e.overload(node.Operator, leftObj, rightObj, node.Token); ok {
+		return result
+	}
+
+	// the string built by `+` counts against the memory limit
+	if left, ok := leftObj.(*object.String); ok && node.Operator == "+" {
+		if right, ok := rightObj.(*object.String); ok {
+			if err := e.allocate(int64(len(left.Value) + len(right.Value))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return evalInfix(node.Operator, leftObj, rightObj)
+}
+
+func evalInfix(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.OBJECT_INTEGER && right.Type() == object.OBJECT_INTEGER:
+		return evalIntegerInfix(operator, left.(*object.Integer).Value, right.(*object.Integer).Value)
+
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfix(operator, toFloat(left), toFloat(right))
+
+	case left.Type() == object.OBJECT_STRING && right.Type() == object.OBJECT_STRING:
+		return evalStringInfix(operator, left.(*object.String).Value, right.(*object.String).Value)
+
+	case operator == "==":
+		return nativeBoolToObject(objectsEqual(left, right))
+
+	case operator == "!=":
+		return nativeBoolToObject(!objectsEqual(left, right))
+
+	default:
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfix(operator string, left, right int64) object.Object {
+	switch operator {
 	case "+":
 		return &object.Integer{Value: left + right}
 
@@ -115,71 +304,226 @@ func evalInfixExpression(node *ast.InfixExpression, env *object.Environment) obj
 		return &object.Integer{Value: left * right}
 
 	case "/":
+		if right == 0 {
+			return newError("division by zero")
+		}
+
 		return &object.Integer{Value: left / right}
 
 	case ">":
-		return &object.Boolean{Value: left > right}
+		return nativeBoolToObject(left > right)
 
 	case ">=":
-		return &object.Boolean{Value: left >= right}
+		return nativeBoolToObject(left >= right)
 
 	case "<":
-		return &object.Boolean{Value: left < right}
+		return nativeBoolToObject(left < right)
 
 	case "<=":
-		return &object.Boolean{Value: left <= right}
+		return nativeBoolToObject(left <= right)
 
 	case "==":
-		return &object.Boolean{Value: left == right}
+		return nativeBoolToObject(left == right)
 
 	case "!=":
-		return &object.Boolean{Value: left != right}
+		return nativeBoolToObject(left != right)
 
 	default:
-		log.Fatalf("Unknown infix operator %s\n", node.Operator)
-		return NILL
+		return newError("unknown infix operator %s", operator)
 	}
 }
 
-func evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
-	val := Eval(node.Value, env)
-	env.Set(node.Name.Value, val)
-	return NILL
+func evalFloatInfix(operator string, left, right float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: left + right}
+
+	case "-":
+		return &object.Float{Value: left - right}
+
+	case "*":
+		return &object.Float{Value: left * right}
+
+	case "/":
+		return &object.Float{Value: left / right}
+
+	case ">":
+		return nativeBoolToObject(left > right)
+
+	case ">=":
+		return nativeBoolToObject(left >= right)
+
+	case "<":
+		return nativeBoolToObject(left < right)
+
+	case "<=":
+		return nativeBoolToObject(left <= right)
+
+	case "==":
+		return nativeBoolToObject(left == right)
+
+	case "!=":
+		return nativeBoolToObject(left != right)
+
+	default:
+		return newError("unknown infix operator %s", operator)
+	}
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if builtinFun, ok := builtins[node.Value]; ok {
-		return builtinFun
+func evalStringInfix(operator string, left, right string) object.Object {
+	switch operator {
+	case "+":
+		return &object.String{Value: left + right}
+
+	case "==":
+		return nativeBoolToObject(left == right)
+
+	case "!=":
+		return nativeBoolToObject(left != right)
+
+	default:
+		return newError("unknown operator: %s %s %s", object.OBJECT_STRING, operator, object.OBJECT_STRING)
 	}
+}
 
-	if val := env.Get(node.Value); val != nil {
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.OBJECT_INTEGER || obj.Type() == object.OBJECT_FLOAT
+}
+
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
+// objectsEqual compares values of any type, hashable values by content and
+// everything else by identity
+func objectsEqual(left, right object.Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	if leftStruct, ok := left.(*object.Struct); ok {
+		return structsEqual(leftStruct, right.(*object.Struct), make(map[[2]*object.Struct]bool))
+	}
+
+	leftHash, ok := left.(object.Hashable)
+
+	if !ok {
+		return left == right
+	}
+
+	return leftHash.Hashkey() == right.(object.Hashable).Hashkey()
+}
+
+func nativeBoolToObject(value bool) *object.Boolean {
+	if value {
+		return TRUE
+	}
+
+	return FALSE
+}
+
+func (e *Evaluator) evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
+	val := e.eval(node.Value, env)
+
+	if isError(val) {
 		return val
 	}
 
-	log.Printf("undefined identifier: %s\n", node.Value)
+	if node.Pattern != nil {
+		if err := e.destructure(node.Pattern, val, env, node.Constant()); err != nil {
+			return err
+		}
+
+		return NILL
+	}
+
+	// a function literal bound by `let` takes the name of the binding
+	if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+		if _, ok := node.Value.(*ast.FunctionLiteralExpression); ok {
+			fn.Name = node.Name.Value
+		}
+	}
+
+	declare := env.Declare
+
+	if node.Constant() {
+		declare = env.DeclareConst
+	}
+
+	if err := declare(node.Name.Value, val, node.Name); err != nil {
+		return newError("%s", err)
+	}
+
+	return NILL
+}
+
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := lookup(node, env); ok {
+		return val
+	}
+
+	if builtinFun, ok := e.builtins[node.Value]; ok {
+		return builtinFun
+	}
+
+	fmt.Fprintf(e.Stderr, "undefined identifier: %s\n", node.Value)
 	return NILL
 }
 
-func evalArrayLiteralExpression(node *ast.ArrayLiteralExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalArrayLiteralExpression(node *ast.ArrayLiteralExpression, env *object.Environment) object.Object {
 	objects := []object.Object{}
 
 	for _, elem := range node.Elements.List {
-		obj := Eval(elem, env)
+		obj := e.eval(elem, env)
+
+		if isError(obj) {
+			return obj
+		}
+
 		objects = append(objects, obj)
 	}
 
+	if err := e.allocate(int64(len(objects)) * sizeOfElement); err != nil {
+		return err
+	}
+
 	arr := &object.Array{Value: objects}
 	return arr
 }
 
-func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) object.Object {
-	ident := Eval(node.Ident, env)
-	index := Eval(node.Index, env)
+func (e *Evaluator) evalIndexExpression(node *ast.IndexExpression, env *object.Environment) object.Object {
+	ident := e.eval(node.Ident, env)
+
+	if isError(ident) {
+		return ident
+	}
+
+	index := e.eval(node.Index, env)
+
+	if isError(index) {
+		return index
+	}
+
+	if value, ok := ident.(*object.Struct); ok {
+		if method, ok := value.Def.Method("__index__"); ok {
+			return e.apply(&object.Method{Receiver: value, Function: method}, []object.Object{index}, node.Token)
+		}
+	}
 
 	switch ident.Type() {
 	case object.OBJECT_ARRAY:
 		array := ident.(*object.Array).Value
-		idx := index.(*object.Integer).Value
+		integer, ok := index.(*object.Integer)
+
+		if !ok {
+			return newError("array index must be an integer, got %s", index.Type())
+		}
+
+		idx := integer.Value
 
 		arrLen := len(array) - 1
 
@@ -191,7 +535,11 @@ func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) obj
 
 	case object.OBJECT_HASHMAP:
 		hash := ident.(*object.HashMap).Value
-		idx := index.(object.Hashable)
+		idx, ok := index.(object.Hashable)
+
+		if !ok {
+			return newError("invalid key type: %s", index.Type())
+		}
 
 		if val, ok := hash[idx.Hashkey()]; ok {
 			return val
@@ -200,56 +548,98 @@ func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) obj
 		return NILL
 
 	default:
+		if indexer, ok := ident.(object.Indexer); ok {
+			return indexer.Index(index)
+		}
+
 		return NILL
 	}
 }
 
-func evalPrefixExpression(node *ast.PrefixExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalPrefixExpression(node *ast.PrefixExpression, env *object.Environment) object.Object {
 	switch node.Operator {
 	case "!":
-		val := Eval(node.Right, env)
+		val := e.eval(node.Right, env)
+
+		if isError(val) {
+			return val
+		}
+
 		boolean := isTruthy(val)
-		return &object.Boolean{Value: !boolean}
+		return nativeBoolToObject(!boolean)
 
 	case "-":
-		val := Eval(node.Right, env).(*object.Integer).Value
-		return &object.Integer{Value: -val}
+		val := e.eval(node.Right, env)
 
-	default:
-		log.Fatalf("Unknown prefix operator %s\n", node.Operator)
-		return NILL
+		if isError(val) {
+			return val
+		}
+
+		switch val := val.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -val.Value}
 
+		case *object.Float:
+			return &object.Float{Value: -val.Value}
+
+		default:
+			return newError("unknown operator: -%s", val.Type())
+		}
+
+	default:
+		return newError("unknown prefix operator %s", node.Operator)
 	}
 }
 
-func evalHashMapLiteralExpression(node *ast.HashmapLiteralExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalHashMapLiteralExpression(node *ast.HashmapLiteralExpression, env *object.Environment) object.Object {
 	hashMap := make(map[object.Hash]object.Object)
 
-	for k, v := range node.Map {
-		key := Eval(k, env)
-		val := Eval(v, env)
+	for _, k := range node.Keys {
+		v := node.Map[k]
+		key := e.eval(k, env)
+
+		if isError(key) {
+			return key
+		}
+
+		val := e.eval(v, env)
+
+		if isError(val) {
+			return val
+		}
 
 		hash, ok := key.(object.Hashable)
 
 		if !ok {
-			log.Fatalf("invalid key type: %T", key)
-			return NILL
+			return newError("invalid key type: %s", key.Type())
 		}
 
 		hashMap[hash.Hashkey()] = val
 	}
 
+	if err := e.allocate(int64(len(hashMap)) * sizeOfEntry); err != nil {
+		return err
+	}
+
 	return &object.HashMap{Value: hashMap}
 }
 
-func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(node.Condition, env)
+func (e *Evaluator) evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(node.Condition, env)
+
+	if isError(condition) {
+		return condition
+	}
 
 	if isTruthy(condition) {
-		return Eval(node.IfArm, env)
+		return e.eval(node.IfArm, env)
+	}
+
+	if node.ElseArm == nil {
+		return NILL
 	}
 
-	return Eval(node.ElseArm, env)
+	return e.eval(node.ElseArm, env)
 }
 
 func isTruthy(obj object.Object) bool {
@@ -265,70 +655,408 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalBlockStatement(node *ast.BlockStatement, env *object.Environment) object.Object {
-	return evalProgram(node.Statements, env)
+func (e *Evaluator) evalBlockStatement(node *ast.BlockStatement, env *object.Environment) object.Object {
+	return e.evalProgram(node.Statements, env)
 }
 
-func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
-	var res object.Object
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	// a loop whose body never runs is nil
+	var res object.Object = NILL
+
+	for {
+		condition := e.eval(node.Condition, env)
+
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			break
+		}
 
-	for Eval(node.Condition, env).(*object.Boolean).Value {
-		res = Eval(node.Body, env)
+		res = e.eval(node.Body, env)
+
+		if res.Type() == object.OBJECT_RETURN || isError(res) {
+			return res
+		}
 	}
 
 	return res
 }
 
-func evalAssignmentExpression(node *ast.AssignmentExpression, env *object.Environment) object.Object {
-	val := Eval(node.Value, env)
-	env.Set(node.Ident.Value, val)
+func (e *Evaluator) evalAssignmentExpression(node *ast.AssignmentExpression, env *object.Environment) object.Object {
+	val := e.eval(node.Value, env)
+
+	if isError(val) {
+		return val
+	}
+
+	if err := assign(node.Ident, env, val); err != nil {
+		name := node.Ident.Value
+
+		if _, declared := env.Lookup(name); !declared {
+			if _, ok := e.builtins[name]; ok {
+				return newError("cannot assign to builtin %s", name)
+			}
+		}
+
+		return newError("%s", err)
+	}
+
 	return NILL
 }
 
-func evalExpressionList(node *ast.ExpressionList, env *object.Environment) object.Object {
+// lookup finds the value of ident in env, through the slot the resolver
+// located it at when there is one
+func lookup(ident *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	if ident.Resolved {
+		return env.LookupAt(ident.Depth, ident.Slot, ident.Value)
+	}
+
+	return env.Lookup(ident.Value)
+}
+
+// assign stores val in the binding of ident visible from env, like lookup
+func assign(ident *ast.Identifier, env *object.Environment, val object.Object) error {
+	if ident.Resolved {
+		return env.AssignAt(ident.Depth, ident.Slot, ident.Value, val)
+	}
+
+	return env.Assign(ident.Value, val)
+}
+
+func (e *Evaluator) evalIndexAssignmentExpression(node *ast.IndexAssignmentExpression, env *object.Environment) object.Object {
+	target := e.eval(node.Target.Ident, env)
+
+	if isError(target) {
+		return target
+	}
+
+	index := e.eval(node.Target.Index, env)
+
+	if isError(index) {
+		return index
+	}
+
+	val := e.eval(node.Value, env)
+
+	if isError(val) {
+		return val
+	}
+
+	switch target := target.(type) {
+	case *object.Array:
+		if target.Frozen {
+			return newError("cannot assign to an element of a frozen array")
+		}
+
+		integer, ok := index.(*object.Integer)
+
+		if !ok {
+			return newError("array index must be an integer, got %s", index.Type())
+		}
+
+		if integer.Value < 0 || integer.Value >= int64(len(target.Value)) {
+			return newError("array index %d out of range for length %d", integer.Value, len(target.Value))
+		}
+
+		target.Value[integer.Value] = val
+
+	case *object.HashMap:
+		return e.setKey(target, index, val)
+
+	case object.IndexAssigner:
+		if err := target.SetIndex(index, val); err != nil {
+			return err
+		}
+
+	default:
+		return newError("cannot assign to an index of %s", target.Type())
+	}
+
+	return NILL
+}
+
+// setKey stores val under key in hash, for `hash[key] = val` and
+// `hash.key = val`
+func (e *Evaluator) setKey(hash *object.HashMap, key, val object.Object) object.Object {
+	if hash.Frozen {
+		return newError("cannot assign to a key of a frozen hashmap")
+	}
+
+	hashable, ok := key.(object.Hashable)
+
+	if !ok {
+		return newError("invalid key type: %s", key.Type())
+	}
+
+	if _, ok := hash.Value[hashable.Hashkey()]; !ok {
+		if err := e.allocate(sizeOfEntry); err != nil {
+			return err
+		}
+	}
+
+	hash.Value[hashable.Hashkey()] = val
+	return NILL
+}
+
+func (e *Evaluator) evalExpressionList(node *ast.ExpressionList, env *object.Environment) object.Object {
 	expressions := []object.Object{}
 
 	for _, expr := range node.List {
-		obj := Eval(expr, env)
-		expressions = append(expressions, obj)
+		spread, isSpread := expr.(*ast.SpreadExpression)
+
+		if isSpread {
+			expr = spread.Value
+		}
+
+		obj := e.eval(expr, env)
+
+		if isError(obj) {
+			return obj
+		}
+
+		if !isSpread {
+			expressions = append(expressions, obj)
+			continue
+		}
+
+		arr, ok := obj.(*object.Array)
+
+		if !ok {
+			return newError("cannot spread %s, expected an array", obj.Type())
+		}
+
+		expressions = append(expressions, arr.Value...)
 	}
 
 	return &object.Array{Value: expressions}
 }
 
-func evalFunctionLiteralExpression(node *ast.FunctionLiteralExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalFunctionLiteralExpression(node *ast.FunctionLiteralExpression, env *object.Environment) object.Object {
 	return &object.Function{Parameters: node.Parameters, Body: node.Body, Environment: env}
 }
 
-func evalFunctionCallExpression(node *ast.FunctionCallExpression, env *object.Environment) object.Object {
-	obj := Eval(node.Function, env)
-	args := Eval(node.Args, env).(*object.Array)
-
-	switch obj.Type() {
+func (e *Evaluator) evalFunctionStatement(node *ast.FunctionStatement, env *object.Environment) object.Object {
+	if err := env.Declare(node.Name.Value, e.function(node, env), node.Name); err != nil {
+		return newError("%s", err)
+	}
 
-	case object.OBJECT_FUNCTION:
-		fn := obj.(*object.Function)
+	return NILL
+}
 
-		// start function own scope and inherit from outter scope
-		fnEnv := object.NewEnvironmentWithParent(fn.Environment)
+func (e *Evaluator) function(node *ast.FunctionStatement, env *object.Environment) *object.Function {
+	return &object.Function{Name: node.Name.Value, Parameters: node.Function.Parameters, Body: node.Function.Body, Environment: env}
+}
 
-		// bind args to params
-		for k, v := range args.Value {
-			fnEnv.Set(fn.Parameters[k].Value, v)
+// hoist binds the functions declared by the statements of a program or a
+// function body before any of them runs, so that they may call each other
+// whatever their order. Declarations nested in `if` and `while` blocks are
+// bound when reached
+func (e *Evaluator) hoist(statements []ast.Statement, env *object.Environment) object.Object {
+	for _, stmt := range statements {
+		if fnStmt, ok := stmt.(*ast.FunctionStatement); ok {
+			if err := env.Declare(fnStmt.Name.Value, e.function(fnStmt, env), fnStmt.Name); err != nil {
+				return newError("%s", err)
+			}
 		}
+	}
 
-		return Eval(fn.Body, fnEnv)
+	return nil
+}
 
-	default:
+func (e *Evaluator) evalFunctionCallExpression(node *ast.FunctionCallExpression, env *object.Environment) object.Object {
+	obj := e.eval(node.Function, env)
+
+	if isError(obj) {
+		return obj
+	}
+
+	args := e.eval(node.Args, env)
+
+	if isError(args) {
+		return args
+	}
+
+	return e.apply(obj, args.(*object.Array).Value, ast.StartToken(node.Function))
+}
+
+// apply calls obj with args, site being the start of the call in the source
+// or the zero token when the host makes the call. Tail calls made by the
+// body of a function run in the loop below, in constant Go stack space
+func (e *Evaluator) apply(obj object.Object, args []object.Object, site token.Token) object.Object {
+	obj, args = unbind(obj, args)
+	fn, ok := obj.(*object.Function)
+
+	if !ok {
 		if builtinFun, ok := obj.(BuiltinFn); ok {
-			return builtinFun(args.Value...)
+			return builtinFun(e, args...)
 		}
 
-		return NILL
+		if def, ok := obj.(*object.StructType); ok {
+			return e.construct(def, args)
+		}
+
+		if method, ok := obj.(*BoundMethod); ok {
+			return method.Fn(e, append([]object.Object{method.Receiver}, args...)...)
+		}
+
+		return newError("not a function: %s", obj.Type())
+	}
+
+	if err := e.enterCall(); err != nil {
+		return err
+	}
+	defer e.leaveCall()
+
+	var frames []frame
+
+	for {
+		frames = pushFrame(frames, frame{fn: fn, site: site})
+		result := e.call(fn, args)
+		tail, ok := result.(*tailCall)
+
+		if !ok {
+			return traced(result, frames)
+		}
+
+		callee, calleeArgs := unbind(tail.fn, tail.args)
+		next, ok := callee.(*object.Function)
+
+		if !ok {
+			return traced(e.apply(tail.fn, tail.args, tail.site), frames)
+		}
+
+		// the tail call replaces the call of fn
+		fn, args, site = next, calleeArgs, tail.site
 	}
 }
 
-func evalReturnStatement(node *ast.ReturnStatement, env *object.Environment) object.Object {
-	value := Eval(node.ReturnValue, env)
+// unbind turns the call of a method of a struct into the call of its
+// function, the receiver passed before args
+func unbind(obj object.Object, args []object.Object) (object.Object, []object.Object) {
+	if method, ok := obj.(*object.Method); ok {
+		return method.Function, append([]object.Object{method.Receiver}, args...)
+	}
+
+	return obj, args
+}
+
+// call runs the body of fn once with args, the result being a *tailCall
+// when the body ends with a call in tail position
+func (e *Evaluator) call(fn *object.Function, args []object.Object) object.Object {
+	// start function own scope and inherit from outter scope
+	fnEnv := object.NewEnvironmentWithParent(fn.Environment)
+
+	if err := e.bindArguments(fn, fnEnv, args); err != nil {
+		return err
+	}
+
+	if err := e.hoist(fn.Body.Statements, fnEnv); err != nil {
+		return err
+	}
+
+	result := e.evalTailBlock(fn.Body, fnEnv)
+
+	if ret, ok := result.(*object.Return); ok {
+		return ret.Value
+	}
+
+	return result
+}
+
+// bindArguments binds args to the parameters of fn in env. Missing arguments
+// take their default value, evaluated in env so that it may refer to the
+// parameters before it, and the rest parameter collects the extra ones
+func (e *Evaluator) bindArguments(fn *object.Function, env *object.Environment, args []object.Object) object.Object {
+	required, max := 0, len(fn.Parameters)
+
+	for _, param := range fn.Parameters {
+		switch {
+		case param.Rest:
+			max = -1
+		case param.Default == nil:
+			required++
+		}
+	}
+
+	if len(args) < required || (max >= 0 && len(args) > max) {
+		return newError("wrong number of arguments: expected %s, got %d", arity(required, max), len(args))
+	}
+
+	for k, param := range fn.Parameters {
+		var value object.Object
+
+		switch {
+		case param.Rest:
+			rest := []object.Object{}
+
+			if k < len(args) {
+				rest = append(rest, args[k:]...)
+			}
+
+			value = &object.Array{Value: rest}
+
+		case k < len(args):
+			value = args[k]
+
+		default:
+			value = e.eval(param.Default, env)
+
+			if isError(value) {
+				return value
+			}
+		}
+
+		if param.Pattern != nil {
+			if err := e.destructure(param.Pattern, value, env, false); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := env.Declare(param.Name.Value, value, param.Name); err != nil {
+			return newError("%s", err)
+		}
+	}
+
+	return nil
+}
+
+// arity describes the accepted number of arguments, max being -1 when unbounded
+func arity(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+func (e *Evaluator) evalReturnStatement(node *ast.ReturnStatement, env *object.Environment) object.Object {
+	var value object.Object
+
+	// the value returned from a function is in tail position
+	if e.run.depth > 0 {
+		value = e.evalTail(node.ReturnValue, env)
+	} else {
+		value = e.eval(node.ReturnValue, env)
+	}
+
+	if isError(value) {
+		return value
+	}
+
 	return &object.Return{Value: value}
 }
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.OBJECT_ERROR
+}
+
+func newError(format string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
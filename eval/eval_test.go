@@ -0,0 +1,552 @@
+package eval
+
+import (
+	"Klang/lexer"
+	"Klang/object"
+	"Klang/parser"
+	"Klang/resolve"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%q: parse error: %s", input, p.Errors()[0])
+	}
+
+	var out bytes.Buffer
+	return New(&out, &out).Eval(program, object.NewEnvironment())
+}
+
+// message is the result of a program without the trace of errors
+func message(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return "error: " + err.Message
+	}
+
+	return obj.Inspect()
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a, b) { a + b }; f(1, 2, 3)", "error: wrong number of arguments: expected 2, got 3"},
+		{"let f = fn(a, b) { a + b }; f(1)", "error: wrong number of arguments: expected 2, got 1"},
+		{"let f = fn(x, y = 2) { x * y }; f(3)", "6"},
+		{"let f = fn(x, y = 2) { x * y }; f(3, 3)", "9"},
+		{"let f = fn(x, y = x + 1) { y }; f(3)", "4"},
+		{"let f = fn(x, y = 2) { x * y }; f()", "error: wrong number of arguments: expected 1 to 2, got 0"},
+		{"let f = fn(first, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(first, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(first, ...rest) { rest }; f()", "error: wrong number of arguments: expected at least 1, got 0"},
+		{"let f = fn(a, b, c) { [a, b, c] }; let xs = [2, 3]; f(1, ...xs)", "[1, 2, 3]"},
+		{"let f = fn(...all) { all }; f(...[1], 2, ...[])", "[1, 2]"},
+		{"let f = fn(a) { a }; f(...1)", "error: cannot spread OBJECT_INTEGER, expected an array"},
+		{"let f = fn(a) { a }; f(...[1, 2])", "error: wrong number of arguments: expected 1, got 2"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(a, b) { a + b } add(1, 2)", "3"},
+		{"fn add(a, b) { a + b } add", "fn add(a, b){((a+b));}"},
+		{"let add = fn(a, b) { a + b }; add", "fn add(a, b){((a+b));}"},
+		{"let add = fn(a, b) { a + b }; let plus = add; plus", "fn add(a, b){((a+b));}"},
+		{"fn(a) { a }", "fn(a){(a);}"},
+		// declarations are hoisted to the top of their scope
+		{"let x = even(10); fn even(n) { if n == 0 { true } else { odd(n - 1) } } fn odd(n) { if n == 0 { false } else { even(n - 1) } } x", "true"},
+		{"fn f() { return g(); fn g() { 1 } } f()", "1"},
+		{"fn fact(n) { if n < 2 { return 1; } n * fact(n - 1) } fact(5)", "120"},
+		{"fn f() { 1 } f = 2; f", "2"},
+		{"let x = 0; if true { x = g(); fn g() { 1 } } x", "error: not a function: OBJECT_NILL"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	input := `fn inner(x) { x / 0 }
+fn outer(x) {
+	inner(x)
+}
+let wrap = fn() { outer(1) };
+fn() { wrap() }();`
+
+	expected := "error: division by zero\n\tin inner called at 3:2\n\tin outer called at 5:19\n\tin wrap called at 6:8\n\tin anonymous function called at 6:1"
+
+	if got := testEval(t, input).Inspect(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	input = "fn loop(n) { 1 + loop(n + 1) } loop(0)"
+	err, ok := testEval(t, input).(*object.Error)
+
+	if !ok || len(err.Trace) != maxTrace {
+		t.Errorf("expected a trace of %d calls, got %v", maxTrace, err)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// every call below nests deeper than DefaultMaxDepth without tail calls
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn count(n, acc) { if n == 0 { return acc; } return count(n - 1, acc + 1); } count(100000, 0)", "100000"},
+		{"fn count(n, acc) { if n == 0 { acc } else { count(n - 1, acc + 1) } } count(100000, 0)", "100000"},
+		{"fn even(n) { if n == 0 { true } else { odd(n - 1) } } fn odd(n) { if n == 0 { false } else { even(n - 1) } } even(100001)", "false"},
+		{"fn down(n) { while true { if n == 0 { return \"done\"; } return down(n - 1); } } down(100000)", "done"},
+		{"let f = fn(n, ...rest) { if n == 0 { len(rest) } else { f(n - 1, ...rest) } }; f(100000, 1, 2)", "2"},
+		{"fn last(xs) { len(xs) } fn wrap(n) { if n == 0 { last([1, 2]) } else { wrap(n - 1) } } wrap(100000)", "2"},
+		// calls whose result is used are not in tail position
+		{"fn sum(n) { if n == 0 { 0 } else { n + sum(n - 1) } } sum(100000)", "error: maximum recursion depth exceeded (10000)"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 1; x", "1"},
+		{"const x = 1; x = 2", "error: cannot assign to constant x"},
+		{"const x = 1; let f = fn() { x = 2 }; f()", "error: cannot assign to constant x"},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f()", "3"},
+		{"const x = 1; let x = 2", "error: cannot redeclare constant x"},
+		{"const f = 1; fn f() {}", "error: f is already declared in this scope"},
+		{"len = 1", "error: cannot assign to builtin len"},
+		{"let x = 1; let f = fn() { x = 2 }; f(); x", "2"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2]; a[1] = 3; a", "[1, 3]"},
+		{"let a = [1, 2]; a[2] = 3", "error: array index 2 out of range for length 2"},
+		{"let a = [1, 2]; a[\"x\"] = 3", "error: array index must be an integer, got OBJECT_STRING"},
+		{"let h = {\"a\": 1}; h[\"a\"] = 2; h[\"b\"] = 3; [h[\"a\"], h[\"b\"]]", "[2, 3]"},
+		{"let h = {}; h[[1]] = 2", "error: invalid key type: OBJECT_ARRAY"},
+		{"let s = \"ab\"; s[0] = \"c\"", "error: cannot assign to an index of OBJECT_STRING"},
+		{"let a = freeze([1, [2]]); a[0] = 3", "error: cannot assign to an element of a frozen array"},
+		{"let a = freeze([1, [2]]); a[1][0] = 3", "error: cannot assign to an element of a frozen array"},
+		{"let h = freeze({\"a\": {\"b\": 1}}); h[\"a\"][\"c\"] = 2", "error: cannot assign to a key of a frozen hashmap"},
+		{"const a = [1]; a[0] = 2; a", "[2]"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; let x = 2", "error: x is already declared in this scope"},
+		{"fn f() {} fn f() {}", "error: f is already declared in this scope"},
+		{"let f = fn(a, a) { a }; f(1, 2)", "error: a is already declared in this scope"},
+		{"x = 1", "error: cannot assign to undeclared x"},
+		{"let i = 0; let s = 0; while i < 3 { let d = i * 2; s = s + d; i = i + 1 } s", "6"},
+		{"while false { 1 }", "nil"},
+		{"let counter = fn() { let n = 0; fn() { n = n + 1; n } }; let c = counter(); c(); c(); c()", "3"},
+		{"let f = fn(c) { if c { let a = 1; } let b = 2; b }; [f(true), f(false)]", "[2, 2]"},
+		{"let a = 1; let f = fn() { let g = fn() { a = a + 1 }; g(); a }; f()", "2"},
+		{"let f = fn(len) { len }; f(3)", "3"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%q: parse error: %s", tt.input, p.Errors()[0])
+		}
+
+		var out bytes.Buffer
+		evaluator := New(&out, &out)
+
+		// by name first, then through the slots found by the resolver
+		unresolved := message(evaluator.Eval(program, object.NewEnvironment()))
+		resolve.Resolve(program, evaluator.BuiltinNames())
+		resolved := message(evaluator.Eval(program, object.NewEnvironment()))
+
+		if unresolved != tt.expected || resolved != tt.expected {
+			t.Errorf("%q: expected %q, got %q by name and %q by slot", tt.input, tt.expected, unresolved, resolved)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match 1 { 1 => "one", _ => "other" }`, "one"},
+		{`match 2.0 { 1 => "one", 2 => "two", _ => "other" }`, "two"},
+		{`match "b" { "a" | "b" => "ab", _ => "other" }`, "ab"},
+		{`match -1 { -1 => "minus one", _ => "other" }`, "minus one"},
+		{`match true { false => 0, true => 1 }`, "1"},
+		{`match 5 { n if n > 3 => n * 2, n => n }`, "10"},
+		{`match 2 { n if n > 3 => n * 2, n => n }`, "2"},
+		{`match [1, 2] { [] => "empty", [x] => x, [x, y] => x + y }`, "3"},
+		{`match [1, 2, 3] { [x] => x, [first, ...rest] => rest }`, "[2, 3]"},
+		{`match [1, [2, 3]] { [_, [a, b]] => a * b }`, "6"},
+		{`match {"name": "k", "age": 3} { {name, "age": 3} => name, _ => "other" }`, "k"},
+		{`match {"name": "k"} { {name, age} => age, _ => "no age" }`, "no age"},
+		{`match 3 { 1 => 2 }`, "error: no match arm for 3"},
+		{`match [1] { [x] => { let y = x + 1; y } }`, "2"},
+		{`let x = 1; match 2 { x => x }; x`, "1"},
+		{`let f = fn(n) { match n { 0 => 0, _ => f(n - 1) } }; f(10)`, "0"},
+		{`let x = 5; if x < 3 { "a" } else if x < 6 { "b" } else { "c" }`, "b"},
+		{`let x = 7; if x < 3 { "a" } else if x < 6 { "b" } else { "c" }`, "c"},
+		{`let x = 7; if x < 3 { "a" } else if x < 6 { "b" }`, "nil"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [a, b] = [1, 2]; a + b`, "3"},
+		{`let [first, ...rest] = [1, 2, 3]; rest`, "[2, 3]"},
+		{`let [x, ...rest] = [1]; rest`, "[]"},
+		{`let [_, second] = [1, 2]; second`, "2"},
+		{`let {name, "age": years} = {"name": "k", "age": 3}; [name, years]`, "[k, 3]"},
+		{`let [[a, b], {c}] = [[1, 2], {"c": 3}]; a + b + c`, "6"},
+		{`const [a] = [1]; a = 2`, "error: cannot assign to constant a"},
+		{`let [a, b] = [1]`, "error: cannot destructure [a, b] from an array of length 1"},
+		{`let [a] = [1, 2]`, "error: cannot destructure [a] from an array of length 2"},
+		{`let [a, b, ...rest] = [1]`, "error: cannot destructure [a, b, ...rest] from an array of length 1"},
+		{`let {name} = {"age": 3}`, `error: cannot destructure {name}: missing key "name"`},
+		{`let {name} = [1]`, "error: cannot destructure {name} from OBJECT_ARRAY"},
+		{`let [a] = 1`, "error: cannot destructure [a] from OBJECT_INTEGER"},
+		{`fn add([a, b]) { a + b } add([1, 2])`, "3"},
+		{`fn origin({x, y} = {"x": 0, "y": 0}) { [x, y] } origin()`, "[0, 0]"},
+		{`let greet = fn({name}, greeting) { greeting + " " + name }; greet({"name": "k"}, "hi")`, "hi k"},
+		{`fn add([a, b]) { a + b } add([1])`, "error: cannot destructure [a, b] from an array of length 1"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Point { x, y } Point(1, 2)`, "Point{x: 1, y: 2}"},
+		{`struct Point { x, y } let p = Point(1, 2); p.x + p.y`, "3"},
+		{`struct Point { x, y } let p = Point(1, 2); p.x = 5; p`, "Point{x: 5, y: 2}"},
+		{`struct Line { from, to } struct Point { x, y } let l = Line(Point(0, 0), Point(1, 2)); l.to.y`, "2"},
+		{`struct Line { from, to } struct Point { x, y } let l = Line(Point(0, 0), Point(1, 2)); l.to.y = 3; l`, "Line{from: Point{x: 0, y: 0}, to: Point{x: 1, y: 3}}"},
+		{`struct Empty {} Empty()`, "Empty{}"},
+		{`struct Point { x, y } Point`, "struct Point { x, y }"},
+		{`struct Point { x, y } Point(1, 2) == Point(1, 2)`, "true"},
+		{`struct Point { x, y } Point(1, 2) == Point(2, 1)`, "false"},
+		{`struct Point { x, y } Point(1, 2) != Point(1, 2)`, "false"},
+		{`struct A { x } struct B { x } A(1) == B(1)`, "false"},
+		{`struct Point { x, y } Point(1, 2) == {"x": 1, "y": 2}`, "false"},
+		{`struct Point { x, y } Point(1)`, "error: wrong number of arguments to Point: expected 2, got 1"},
+		{`struct Point { x, y } Point(1, 2).z`, "error: Point has no field or method z"},
+		{`struct Point { x, y } let p = Point(1, 2); p.z = 3`, "error: Point has no field z"},
+		{`let n = 1; n.x`, "error: OBJECT_INTEGER has no method x"},
+		{`let a = [1]; a.x = 1`, "error: cannot assign to field x of OBJECT_ARRAY"},
+		{`struct Point { x, y } let p = freeze(Point([1], 2)); p.x = 3`, "error: cannot assign to a field of a frozen Point"},
+		{`struct Point { x, y } let p = freeze(Point([1], 2)); p.x[0] = 3`, "error: cannot assign to an element of a frozen array"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = {"name": "k", "age": 3}; [user.name, user.age]`, "[k, 3]"},
+		{`let user = {"name": "k"}; user.age`, "nil"},
+		{`let user = {"name": "k"}; user.age = 3; user["age"]`, "3"},
+		{`let h = {"len": 5}; [h.len, {"a": 1}.len()]`, "[5, 1]"},
+		{`let h = {2: "b", 1: "a", "c": 3}; [h.keys(), h.values()]`, "[[1, 2, c], [a, b, 3]]"},
+		{`{"a": 1}.has("a")`, "true"},
+		{`freeze({"a": 1}).a = 2`, "error: cannot assign to a key of a frozen hashmap"},
+		{`let a = [1]; a.push(2, 3).push(4); a`, "[1, 2, 3, 4]"},
+		{`let a = [1, 2]; [a.pop(), a, [].pop()]`, "[2, [1], nil]"},
+		{`[1, 2, 3].map(fn(x) { x * 2 }).filter(fn(x) { x > 2 })`, "[4, 6]"},
+		{`[1, 2, 3].reduce(fn(acc, x) { acc + x }, 10)`, "16"},
+		{`[1, 2, 3].map(fn(x) { x.y })`, "error: OBJECT_INTEGER has no method y"},
+		{`[1, "a"].contains("a")`, "true"},
+		{`[1, 2, 3].reverse().join(", ")`, "3, 2, 1"},
+		{`[1].len()`, "1"},
+		{`freeze([1]).push(2)`, "error: cannot push to a frozen array"},
+		{`"abc".upper()`, "ABC"},
+		{`" Abc ".trim().lower().len()`, "3"},
+		{`"a,b".split(",")`, "[a, b]"},
+		{`["abc".contains("b"), "abc".startsWith("a"), "abc".endsWith("b")]`, "[true, true, false]"},
+		{`"a-b-c".replace("-", "+")`, "a+b+c"},
+		{`"abc".split(1)`, "error: split expects a separator"},
+		{`"abc".nope()`, "error: OBJECT_STRING has no method nope"},
+		{`let upper = "abc".upper; upper()`, "ABC"},
+		{`fn last(xs) { xs.pop() } last([1, 2])`, "2"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestImpl(t *testing.T) {
+	point := `struct Point { x, y } impl Point { fn sum(self) { self.x + self.y } fn add(self, other) { Point(self.x + other.x, self.y + other.y) } } `
+	shape := `trait Shape { fn area(self); fn describe(self) { ["area", self.area()] } } `
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + `Point(1, 2).sum()`, "3"},
+		{point + `Point(1, 2).add(Point(3, 4))`, "Point{x: 4, y: 6}"},
+		{point + `let p = Point(1, 2); p.add(p).sum()`, "6"},
+		{point + `Point.sum(Point(2, 3))`, "5"},
+		{point + `let sum = Point(1, 1).sum; sum()`, "2"},
+		{point + `Point(1, 2).nope()`, "error: Point has no field or method nope"},
+		{point + `Point.nope`, "error: Point has no method nope"},
+		{point + `impl Point { fn sum(self) { 0 } }`, "error: duplicate method sum of Point"},
+		{point + `impl Point { fn x(self) { 0 } }`, "error: method x of Point has the name of a field"},
+		{point + `let i = 0; while i < 2 { impl Point { fn twice(self) { self.sum() * 2 } } i = i + 1 } Point(1, 2).twice()`, "6"},
+		{`struct P { x } impl P { fn get(self) { self.x } fn set(self, x) { self.x = x } } let p = P(1); p.set(5); p.get()`, "5"},
+		{`struct P { x } impl P { fn count(self, n) { if n == 0 { self.x } else { self.count(n - 1) } } } P(7).count(10000)`, "7"},
+		{`impl Nope {}`, "error: undefined struct Nope"},
+		{`let n = 1; impl n {}`, "error: cannot impl n: not a struct"},
+		{`struct Square { side } ` + shape + `impl Shape for Square { fn area(self) { self.side * self.side } } Square(3).describe()`, "[area, 9]"},
+		{`struct Square { side } ` + shape + `impl Shape for Square { fn area(self) { 1 } fn describe(self) { "square" } } Square(3).describe()`, "square"},
+		{`struct Square { side } ` + shape + `impl Shape for Square {}`, "error: impl of Shape for Square is missing method area"},
+		{`struct Square { side } ` + shape + `impl Shape for Square { fn area(self) { 1 } fn perimeter(self) { 4 } }`, "error: perimeter is not a method of trait Shape"},
+		{`struct Square { side } impl Nope for Square {}`, "error: undefined trait Nope"},
+		{`struct Square { side } impl Square for Square {}`, "error: cannot impl Square for Square: not a trait"},
+		{`struct Square { side } ` + shape + `impl Square { fn describe(self) { "" } } impl Shape for Square { fn area(self) { 1 } }`, "error: duplicate method describe of Square"},
+		{shape + `Shape`, "trait Shape"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vec := `struct Vec { x, y } impl Vec { fn __add__(self, o) { Vec(self.x + o.x, self.y + o.y) } fn __sub__(self, o) { Vec(self.x - o.x, self.y - o.y) } fn __mul__(self, k) { Vec(self.x * k, self.y * k) } fn __div__(self, k) { Vec(self.x / k, self.y / k) } fn __lt__(self, o) { self.x < o.x } fn __index__(self, i) { [self.x, self.y][i] } } `
+	money := `struct Money { cents, label } impl Money { fn __eq__(self, o) { self.cents == o.cents } } `
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vec + `Vec(1, 2) + Vec(3, 4)`, "Vec{x: 4, y: 6}"},
+		{vec + `Vec(5, 5) - Vec(1, 2)`, "Vec{x: 4, y: 3}"},
+		{vec + `Vec(1, 2) * 3`, "Vec{x: 3, y: 6}"},
+		{vec + `Vec(4, 6) / 2`, "Vec{x: 2, y: 3}"},
+		{vec + `[Vec(1, 9) < Vec(2, 0), Vec(2, 0) < Vec(1, 9)]`, "[true, false]"},
+		{vec + `let v = Vec(7, 8); [v[0], v[1], v[2]]`, "[7, 8, nil]"},
+		{vec + `Vec(1, 2) == Vec(1, 2)`, "true"},
+		{vec + `Vec(1, 2) > Vec(0, 0)`, "error: type mismatch: OBJECT_STRUCT > OBJECT_STRUCT"},
+		{vec + `Vec(1, 2) + 1`, "error: OBJECT_INTEGER has no method x"},
+		{money + `Money(100, "a") == Money(100, "b")`, "true"},
+		{money + `[Money(100, "a") != Money(100, "b"), Money(1, "a") != Money(2, "a")]`, "[false, true]"},
+		{money + `Money(1, "a") + Money(2, "a")`, "error: type mismatch: OBJECT_STRUCT + OBJECT_STRUCT"},
+		{`struct P { x } P(1)[0]`, "nil"},
+		{`1 + 2 * 3`, "7"},
+		{`"a" + "b"`, "ab"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCyclicValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; a`, "[[...]]"},
+		{`let a = [1]; a.push(a); [a, a]`, "[[1, [...]], [1, [...]]]"},
+		{`let h = {"a": 1}; h.self = h; [h.self.self.a, h.len()]`, "[1, 2]"},
+		{`let h = {}; h["h"] = h; h`, "{h:{...}}"},
+		{`struct N { next } let a = N(1); a.next = a; a`, "N{next: N{...}}"},
+		{`struct N { next } let a = N(1); a.next = a; a == N(a)`, "true"},
+		{`struct N { next } let a = N(1); a.next = a; let b = N(1); b.next = b; a == b`, "false"},
+		{`struct N { next } let a = N(1); a.next = a; a == a`, "true"},
+		{`let a = [1]; a[0] = a; a == a`, "true"},
+	}
+
+	for _, tt := range tests {
+		if got := message(testEval(t, tt.input)); got != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	var out bytes.Buffer
+	program := parser.New(lexer.New(`let a = [1]; a[0] = a; print(a)`)).ParseProgram()
+	New(&out, &out).Eval(program, object.NewEnvironment())
+
+	if out.String() != "[[...]]\n" {
+		t.Errorf("expected %q, got %q", "[[...]]\n", out.String())
+	}
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdout string
+		stderr string
+	}{
+		{`print("a", 1, [true])`, "a 1 [true]\n", ""},
+		{`println("a", "b")`, "ab\n", ""},
+		{`print()`, "\n", ""},
+		{`eprint("oops", 2)`, "", "oops 2\n"},
+		{`printf("%d-%s-%.1f-%t-%v", 1, "s", 1.5, true, [1])`, "1-s-1.5-true-[1]", ""},
+		{`printf(1)`, "", ""},
+		{`print("out"); eprint("err"); println("more")`, "out\nmore\n", "err\n"},
+		{`missing`, "", "undefined identifier: missing\n"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		var stdout, stderr bytes.Buffer
+		New(&stdout, &stderr).Eval(program, object.NewEnvironment())
+
+		if stdout.String() != tt.stdout {
+			t.Errorf("%s: stdout is not matching expected. want=%q, got=%q", tt.input, tt.stdout, stdout.String())
+		}
+
+		if stderr.String() != tt.stderr {
+			t.Errorf("%s: stderr is not matching expected. want=%q, got=%q", tt.input, tt.stderr, stderr.String())
+		}
+	}
+}
+
+func TestOutputIsPerEvaluator(t *testing.T) {
+	program := parser.New(lexer.New(`print("hello")`)).ParseProgram()
+
+	var first, second bytes.Buffer
+	New(&first, &first).Eval(program, object.NewEnvironment())
+	New(&second, &second).Eval(program, object.NewEnvironment())
+	New(&second, &second).Eval(program, object.NewEnvironment())
+
+	if first.String() != "hello\n" || second.String() != "hello\nhello\n" {
+		t.Fatalf("evaluators share their output, got=%q and %q", first.String(), second.String())
+	}
+}
+
+func TestOutputIsNotKeptCharged(t *testing.T) {
+	// 1000 lines of 1 KiB, far more than MaxMemory once added up
+	program := parser.New(lexer.New(`let s = "0123456789abcdef"; let i = 0; while i < 6 { s = s + s; i = i + 1 }
+let n = 0; while n < 1000 { print(s); n = n + 1 }`)).ParseProgram()
+
+	var out bytes.Buffer
+	evaluator := New(&out, &out)
+	evaluator.Limits = Limits{MaxMemory: 1 << 16}
+
+	if result := evaluator.Eval(program, object.NewEnvironment()); isError(result) {
+		t.Fatalf("unexpected error: %s", result.Inspect())
+	}
+
+	if out.Len() != 1000*1025 {
+		t.Fatalf("expected %d bytes of output, got=%d", 1000*1025, out.Len())
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     int
+		expected string
+	}{
+		{`exit()`, 0, ""},
+		{`print(1); exit(3); print(2)`, 3, "1\n"},
+		// exit stops the whole script, not only the function calling it
+		{`fn f() { exit(4) } [1, 2].map(fn(x) { print(x); f() }); print("after")`, 4, "1\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		evaluator := New(&out, &out)
+		result := evaluator.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+
+		err, ok := result.(*object.Error)
+
+		if !ok || !errors.Is(err.Err, ErrExit) {
+			t.Errorf("%q: expected an exit error, got=%s", tt.input, result.Inspect())
+			continue
+		}
+
+		if code := err.Err.(*ExitError).Code; code != tt.code {
+			t.Errorf("%q: exit status is not matching expected. want=%d, got=%d", tt.input, tt.code, code)
+		}
+
+		if out.String() != tt.expected {
+			t.Errorf("%q: output is not matching expected. want=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"Klang/ast"
+	"Klang/object"
+	"Klang/token"
+	"sort"
+)
+
+func (e *Evaluator) evalTraitStatement(node *ast.TraitStatement, env *object.Environment) object.Object {
+	trait := &object.Trait{Name: node.Name.Value, Required: []string{}, Defaults: map[string]*object.Function{}}
+
+	for _, method := range node.Methods {
+		if method.Function.Body == nil {
+			trait.Required = append(trait.Required, method.Name.Value)
+			continue
+		}
+
+		fn := e.function(method, env)
+		fn.Name = trait.Name + "." + fn.Name
+		trait.Defaults[method.Name.Value] = fn
+	}
+
+	if err := env.Declare(node.Name.Value, trait, node.Name); err != nil {
+		return newError("%s", err)
+	}
+
+	return NILL
+}
+
+// evalImplStatement adds the methods of node to its struct, with the
+// defaults of the trait it implements, if any, for the methods it leaves
+// out. Nothing is added when one of them cannot be
+func (e *Evaluator) evalImplStatement(node *ast.ImplStatement, env *object.Environment) object.Object {
+	obj, ok := lookup(node.Type, env)
+
+	if !ok {
+		return newError("undefined struct %s", node.Type.Value)
+	}
+
+	def, ok := obj.(*object.StructType)
+
+	if !ok {
+		return newError("cannot impl %s: not a struct", node.Type.Value)
+	}
+
+	methods := make(map[string]*object.Function)
+	names := []string{}
+
+	for _, method := range node.Methods {
+		fn := e.function(method, env)
+		fn.Name = def.Name + "." + fn.Name
+		methods[method.Name.Value] = fn
+		names = append(names, method.Name.Value)
+	}
+
+	if node.Trait != nil {
+		defaults, err := e.implementTrait(node.Trait, def, methods, env)
+
+		if err != nil {
+			return err
+		}
+
+		names = append(names, defaults...)
+	}
+
+	for _, name := range names {
+		fn := methods[name]
+
+		if def.Field(name) >= 0 {
+			return newError("method %s of %s has the name of a field", name, def.Name)
+		}
+
+		// running the same impl again, as in a loop, redefines its methods
+		if existing, ok := def.Method(name); ok && existing.Body != fn.Body {
+			return newError("duplicate method %s of %s", name, def.Name)
+		}
+	}
+
+	if def.Methods == nil {
+		def.Methods = make(map[string]*object.Function)
+	}
+
+	for name, fn := range methods {
+		def.Methods[name] = fn
+	}
+
+	return NILL
+}
+
+// implementTrait checks that methods, those of an impl of the trait named
+// by ident for def, are the methods of the trait and adds the defaults
+// they leave out, returning their sorted names
+func (e *Evaluator) implementTrait(ident *ast.Identifier, def *object.StructType, methods map[string]*object.Function, env *object.Environment) ([]string, object.Object) {
+	obj, ok := lookup(ident, env)
+
+	if !ok {
+		return nil, newError("undefined trait %s", ident.Value)
+	}
+
+	trait, ok := obj.(*object.Trait)
+
+	if !ok {
+		return nil, newError("cannot impl %s for %s: not a trait", ident.Value, def.Name)
+	}
+
+	for name := range methods {
+		if _, ok := trait.Defaults[name]; !ok && !contains(trait.Required, name) {
+			return nil, newError("%s is not a method of trait %s", name, trait.Name)
+		}
+	}
+
+	for _, name := range trait.Required {
+		if _, ok := methods[name]; !ok {
+			return nil, newError("impl of %s for %s is missing method %s", trait.Name, def.Name, name)
+		}
+	}
+
+	defaults := []string{}
+
+	for name, fn := range trait.Defaults {
+		if _, ok := methods[name]; !ok {
+			methods[name] = fn
+			defaults = append(defaults, name)
+		}
+	}
+
+	sort.Strings(defaults)
+	return defaults, nil
+}
+
+// overload calls the method of left overloading operator with right, ok
+// being false when left is not a struct defining one
+func (e *Evaluator) overload(operator string, left, right object.Object, site token.Token) (object.Object, bool) {
+	value, ok := left.(*object.Struct)
+
+	if !ok {
+		return nil, false
+	}
+
+	name, negate := object.OperatorMethods[operator], false
+
+	if operator == "!=" {
+		name, negate = object.OperatorMethods["=="], true
+	}
+
+	method, ok := value.Def.Method(name)
+
+	if !ok {
+		return nil, false
+	}
+
+	result := e.apply(&object.Method{Receiver: value, Function: method}, []object.Object{right}, site)
+
+	if negate && !isError(result) {
+		result = nativeBoolToObject(!isTruthy(result))
+	}
+
+	return result, true
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,205 @@
+package eval
+
+import (
+	"Klang/object"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultMaxDepth keeps deeply recursive scripts well clear of the Go stack limit
+const DefaultMaxDepth = 10000
+
+// SandboxLimits are the limits of evaluators created by NewSandbox
+var SandboxLimits = Limits{
+	MaxSteps:   10000000,
+	MaxDepth:   1000,
+	MaxMemory:  64 << 20,
+	MaxNesting: 1000,
+	Timeout:    5 * time.Second,
+}
+
+// approximate cost in bytes of the values tracked by Limits.MaxMemory
+const (
+	sizeOfElement = 16
+	sizeOfEntry   = 48
+)
+
+// how many steps are evaluated between two checks of the context
+const contextCheckInterval = 256
+
+var (
+	ErrCanceled       = errors.New("execution canceled")
+	ErrTimeout        = errors.New("execution timed out")
+	ErrStepLimit      = errors.New("step limit exceeded")
+	ErrRecursionLimit = errors.New("maximum recursion depth exceeded")
+	ErrMemoryLimit    = errors.New("memory limit exceeded")
+	ErrInternal       = errors.New("internal error")
+)
+
+// Limits bounds a single evaluation. A zero value disables the limit
+type Limits struct {
+	MaxSteps   int64         // number of AST nodes evaluated
+	MaxDepth   int           // nesting of K function calls
+	MaxMemory  int64         // approximate bytes allocated for strings, arrays and hashmaps
+	MaxNesting int           // nesting of the source, given to the parser as parser.Parser.MaxNesting by whoever parses it
+	Timeout    time.Duration // wall clock time, on top of any deadline of the context
+}
+
+// run is the bookkeeping of the evaluation in progress. It is reset
+// whenever the outermost Eval or Apply starts
+type run struct {
+	ctx    context.Context
+	active int
+	steps  int64
+	depth  int
+	memory int64
+	err    *object.Error // once set, every following step fails with it
+}
+
+func (e *Evaluator) begin(ctx context.Context) context.CancelFunc {
+	cancel := func() {}
+
+	if e.run.active == 0 {
+		if e.Limits.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, e.Limits.Timeout)
+		}
+
+		e.run = run{ctx: ctx}
+	}
+
+	e.run.active++
+	return cancel
+}
+
+// end closes the evaluation opened by begin. A Go panic raised while
+// evaluating is turned into a runtime error instead of tearing down the host
+func (e *Evaluator) end(cancel context.CancelFunc, result *object.Object) {
+	e.run.active--
+	cancel()
+
+	if r := recover(); r != nil {
+		*result = &object.Error{Message: fmt.Sprintf("%s: %v", ErrInternal, r), Err: ErrInternal}
+	}
+}
+
+func (e *Evaluator) step() *object.Error {
+	if e.run.err != nil {
+		return e.run.err
+	}
+
+	e.run.steps++
+
+	if e.Limits.MaxSteps > 0 && e.run.steps > e.Limits.MaxSteps {
+		return e.abort(ErrStepLimit, "%s (%d)", ErrStepLimit, e.Limits.MaxSteps)
+	}
+
+	if e.run.ctx != nil && e.run.steps%contextCheckInterval == 0 {
+		switch e.run.ctx.Err() {
+		case nil:
+
+		case context.DeadlineExceeded:
+			return e.abort(ErrTimeout, "%s", ErrTimeout)
+
+		default:
+			return e.abort(ErrCanceled, "%s", ErrCanceled)
+		}
+	}
+
+	return nil
+}
+
+func (e *Evaluator) enterCall() *object.Error {
+	if e.Limits.MaxDepth > 0 && e.run.depth >= e.Limits.MaxDepth {
+		return e.abort(ErrRecursionLimit, "%s (%d)", ErrRecursionLimit, e.Limits.MaxDepth)
+	}
+
+	e.run.depth++
+	return nil
+}
+
+func (e *Evaluator) leaveCall() {
+	e.run.depth--
+}
+
+// Allocate charges size bytes against Limits.MaxMemory. Builtins that
+// create large values should call it before building them
+func (e *Evaluator) Allocate(size int64) *object.Error {
+	return e.allocate(size)
+}
+
+func (e *Evaluator) allocate(size int64) *object.Error {
+	if e.run.err != nil {
+		return e.run.err
+	}
+
+	e.run.memory += size
+
+	if e.Limits.MaxMemory > 0 && e.run.memory > e.Limits.MaxMemory {
+		return e.abort(ErrMemoryLimit, "%s (%d bytes)", ErrMemoryLimit, e.Limits.MaxMemory)
+	}
+
+	return nil
+}
+
+// release gives back size bytes charged by allocate for a value that is
+// gone by the time the evaluation goes on, such as the text of a print
+func (e *Evaluator) release(size int64) {
+	e.run.memory -= size
+}
+
+// limitWriter charges the text written through it against the limits of
+// an evaluator, one step and its size per write, and keeps it in out. It
+// stops accepting text once a limit is reached, err being the error
+type limitWriter struct {
+	e       *Evaluator
+	out     bytes.Buffer
+	charged int64
+	err     *object.Error
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.err == nil {
+		if lw.err = lw.e.step(); lw.err == nil {
+			lw.err = lw.e.allocate(int64(len(p)))
+		}
+	}
+
+	if lw.err != nil {
+		return 0, lw.err.Err
+	}
+
+	lw.charged += int64(len(p))
+	return lw.out.Write(p)
+}
+
+// flush writes the text to w and releases the memory it was charged
+func (lw *limitWriter) flush(w io.Writer) {
+	w.Write(lw.out.Bytes())
+	lw.e.release(lw.charged)
+	lw.charged = 0
+	lw.out.Reset()
+}
+
+// inspect is the Inspect form of obj, built within the limits of e. A
+// value whose elements are shared many times is stopped with a limit
+// error instead of being expanded in full
+func (e *Evaluator) inspect(obj object.Object) (string, *object.Error) {
+	lw := &limitWriter{e: e}
+	object.Fprint(lw, obj)
+
+	if lw.err != nil {
+		return "", lw.err
+	}
+
+	// the string outlives the writer, it stays charged
+	return lw.out.String(), nil
+}
+
+func (e *Evaluator) abort(err error, format string, args ...interface{}) *object.Error {
+	e.run.err = &object.Error{Message: fmt.Sprintf(format, args...), Err: err}
+	return e.run.err
+}
@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"Klang/ast"
+	"Klang/object"
+)
+
+func (e *Evaluator) evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	arm, armEnv, err := e.selectArm(node, env)
+
+	if err != nil {
+		return err
+	}
+
+	return e.eval(arm.Body, armEnv)
+}
+
+// selectArm returns the first arm of node whose pattern matches the value
+// and whose guard holds, along with the environment of its bindings. Each
+// arm is tried in an environment of its own, so that a pattern failing
+// halfway leaves nothing bound
+func (e *Evaluator) selectArm(node *ast.MatchExpression, env *object.Environment) (*ast.MatchArm, *object.Environment, object.Object) {
+	value := e.eval(node.Value, env)
+
+	if isError(value) {
+		return nil, nil, value
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnvironmentWithParent(env)
+		matched, err := e.match(arm.Pattern, value, armEnv)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := e.eval(arm.Guard, armEnv)
+
+			if isError(guard) {
+				return nil, nil, guard
+			}
+
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return arm, armEnv, nil
+	}
+
+	inspected, err := e.inspect(value)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, nil, newError("no match arm for %s", inspected)
+}
+
+// match reports whether value matches pattern, declaring the names the
+// pattern binds in env
+func (e *Evaluator) match(pattern ast.Pattern, value object.Object, env *object.Environment) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return true, bindPattern(pattern, value, env, false)
+
+	case *ast.LiteralPattern:
+		literal := e.eval(pattern.Value, env)
+
+		if isError(literal) {
+			return false, literal
+		}
+
+		return evalInfix("==", literal, value) == TRUE, nil
+
+	case *ast.AlternativePattern:
+		for _, alt := range pattern.Alternatives {
+			if matched, err := e.match(alt, value, env); matched || err != nil {
+				return matched, err
+			}
+		}
+
+		return false, nil
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+
+		if !ok || len(array.Value) < len(pattern.Elements) {
+			return false, nil
+		}
+
+		if pattern.Rest == nil && len(array.Value) != len(pattern.Elements) {
+			return false, nil
+		}
+
+		for i, elem := range pattern.Elements {
+			if matched, err := e.match(elem, array.Value[i], env); !matched || err != nil {
+				return matched, err
+			}
+		}
+
+		if pattern.Rest == nil {
+			return true, nil
+		}
+
+		rest := append([]object.Object{}, array.Value[len(pattern.Elements):]...)
+		return true, bindPattern(pattern.Rest, &object.Array{Value: rest}, env, false)
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.HashMap)
+
+		if !ok {
+			return false, nil
+		}
+
+		for i, key := range pattern.Keys {
+			hashable, ok := e.eval(key, env).(object.Hashable)
+
+			if !ok {
+				return false, newError("invalid key type in pattern: %s", key)
+			}
+
+			val, ok := hash.Value[hashable.Hashkey()]
+
+			if !ok {
+				return false, nil
+			}
+
+			if matched, err := e.match(pattern.Values[i], val, env); !matched || err != nil {
+				return matched, err
+			}
+		}
+
+		return true, nil
+	}
+
+	return false, newError("unknown pattern: %s", pattern)
+}
+
+// bindPattern declares the name of a pattern in env, `_` binding nothing
+func bindPattern(ident *ast.Identifier, value object.Object, env *object.Environment, constant bool) object.Object {
+	if ident.Value == "_" {
+		return nil
+	}
+
+	declare := env.Declare
+
+	if constant {
+		declare = env.DeclareConst
+	}
+
+	if err := declare(ident.Value, value, ident); err != nil {
+		return newError("%s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,407 @@
+package eval
+
+import (
+	"Klang/object"
+	"Klang/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// BoundMethod is a builtin method read from its receiver, as `arr.push`.
+// Calling it passes the receiver before the arguments
+type BoundMethod struct {
+	Receiver object.Object
+	Name     string
+	Fn       BuiltinFn
+}
+
+func (bm *BoundMethod) Inspect() string {
+	return "method " + bm.Name
+}
+
+func (bm *BoundMethod) Type() object.ObjectType {
+	return object.OBJECT_BUILTIN
+}
+
+// builtinMethods are the builtins reached with `.` on a value of their type,
+// the value itself being their first argument
+var builtinMethods = map[object.ObjectType]map[string]BuiltinFn{
+	object.OBJECT_ARRAY: {
+		"len": func(e *Evaluator, args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(len(args[0].(*object.Array).Value))}
+		},
+		"push": func(e *Evaluator, args ...object.Object) object.Object {
+			array := args[0].(*object.Array)
+
+			if array.Frozen {
+				return newError("cannot push to a frozen array")
+			}
+
+			if err := e.allocate(int64(len(args)-1) * sizeOfElement); err != nil {
+				return err
+			}
+
+			array.Value = append(array.Value, args[1:]...)
+			return array
+		},
+		"pop": func(e *Evaluator, args ...object.Object) object.Object {
+			array := args[0].(*object.Array)
+
+			if array.Frozen {
+				return newError("cannot pop from a frozen array")
+			}
+
+			if len(array.Value) == 0 {
+				return NILL
+			}
+
+			last := array.Value[len(array.Value)-1]
+			array.Value = array.Value[:len(array.Value)-1]
+			return last
+		},
+		"map": func(e *Evaluator, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("map expects a function")
+			}
+
+			array := args[0].(*object.Array)
+
+			if err := e.allocate(int64(len(array.Value)) * sizeOfElement); err != nil {
+				return err
+			}
+
+			mapped := []object.Object{}
+
+			for _, elem := range array.Value {
+				result := e.apply(args[1], []object.Object{elem}, token.Token{})
+
+				if isError(result) {
+					return result
+				}
+
+				mapped = append(mapped, result)
+			}
+
+			return &object.Array{Value: mapped}
+		},
+		"filter": func(e *Evaluator, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("filter expects a function")
+			}
+
+			array := args[0].(*object.Array)
+
+			if err := e.allocate(int64(len(array.Value)) * sizeOfElement); err != nil {
+				return err
+			}
+
+			kept := []object.Object{}
+
+			for _, elem := range array.Value {
+				result := e.apply(args[1], []object.Object{elem}, token.Token{})
+
+				if isError(result) {
+					return result
+				}
+
+				if isTruthy(result) {
+					kept = append(kept, elem)
+				}
+			}
+
+			return &object.Array{Value: kept}
+		},
+		"reduce": func(e *Evaluator, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("reduce expects a function and an initial value")
+			}
+
+			acc := args[2]
+
+			for _, elem := range args[0].(*object.Array).Value {
+				if acc = e.apply(args[1], []object.Object{acc, elem}, token.Token{}); isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+		"contains": func(e *Evaluator, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("contains expects a value")
+			}
+
+			for _, elem := range args[0].(*object.Array).Value {
+				if evalInfix("==", elem, args[1]) == TRUE {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		},
+		"join": func(e *Evaluator, args ...object.Object) object.Object {
+			sep, ok := stringArg(args, 1)
+
+			if !ok {
+				return newError("join expects a separator")
+			}
+
+			elems := []string{}
+			size := int64(0)
+
+			for i, elem := range args[0].(*object.Array).Value {
+				var text string
+
+				switch elem := elem.(type) {
+				case *object.String:
+					text = elem.Value
+
+				default:
+					inspected, err := e.inspect(elem)
+
+					if err != nil {
+						return err
+					}
+
+					text = inspected
+				}
+
+				if i > 0 {
+					size += int64(len(sep))
+				}
+
+				elems = append(elems, text)
+				size += int64(len(text))
+			}
+
+			return e.newString(size, func() string { return strings.Join(elems, sep) })
+		},
+		"reverse": func(e *Evaluator, args ...object.Object) object.Object {
+			array := args[0].(*object.Array)
+
+			if err := e.allocate(int64(len(array.Value)) * sizeOfElement); err != nil {
+				return err
+			}
+
+			reversed := make([]object.Object, len(array.Value))
+
+			for i, elem := range array.Value {
+				reversed[len(array.Value)-1-i] = elem
+			}
+
+			return &object.Array{Value: reversed}
+		},
+	},
+	object.OBJECT_STRING: {
+		"len": func(e *Evaluator, args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(utf8.RuneCountInString(args[0].(*object.String).Value))}
+		},
+		"upper": func(e *Evaluator, args ...object.Object) object.Object {
+			value := args[0].(*object.String).Value
+			return e.newString(int64(len(value)), func() string { return strings.ToUpper(value) })
+		},
+		"lower": func(e *Evaluator, args ...object.Object) object.Object {
+			value := args[0].(*object.String).Value
+			return e.newString(int64(len(value)), func() string { return strings.ToLower(value) })
+		},
+		"trim": func(e *Evaluator, args ...object.Object) object.Object {
+			value := strings.TrimSpace(args[0].(*object.String).Value)
+			return e.newString(int64(len(value)), func() string { return value })
+		},
+		"split": func(e *Evaluator, args ...object.Object) object.Object {
+			sep, ok := stringArg(args, 1)
+
+			if !ok {
+				return newError("split expects a separator")
+			}
+
+			value := args[0].(*object.String).Value
+
+			if err := e.allocate(int64(strings.Count(value, sep)+1) * sizeOfElement); err != nil {
+				return err
+			}
+
+			parts := strings.Split(value, sep)
+
+			elems := []object.Object{}
+
+			for _, part := range parts {
+				elems = append(elems, &object.String{Value: part})
+			}
+
+			return &object.Array{Value: elems}
+		},
+		"contains": func(e *Evaluator, args ...object.Object) object.Object {
+			sub, ok := stringArg(args, 1)
+
+			if !ok {
+				return newError("contains expects a string")
+			}
+
+			return nativeBoolToObject(strings.Contains(args[0].(*object.String).Value, sub))
+		},
+		"startsWith": func(e *Evaluator, args ...object.Object) object.Object {
+			prefix, ok := stringArg(args, 1)
+
+			if !ok {
+				return newError("startsWith expects a string")
+			}
+
+			return nativeBoolToObject(strings.HasPrefix(args[0].(*object.String).Value, prefix))
+		},
+		"endsWith": func(e *Evaluator, args ...object.Object) object.Object {
+			suffix, ok := stringArg(args, 1)
+
+			if !ok {
+				return newError("endsWith expects a string")
+			}
+
+			return nativeBoolToObject(strings.HasSuffix(args[0].(*object.String).Value, suffix))
+		},
+		"replace": func(e *Evaluator, args ...object.Object) object.Object {
+			old, okOld := stringArg(args, 1)
+			replacement, okNew := stringArg(args, 2)
+
+			if !okOld || !okNew {
+				return newError("replace expects the string to replace and its replacement")
+			}
+
+			value := args[0].(*object.String).Value
+			size := int64(len(value)) + int64(strings.Count(value, old))*int64(len(replacement)-len(old))
+
+			return e.newString(size, func() string { return strings.ReplaceAll(value, old, replacement) })
+		},
+	},
+	object.OBJECT_HASHMAP: {
+		"len": func(e *Evaluator, args ...object.Object) object.Object {
+			return &object.Integer{Value: int64(len(args[0].(*object.HashMap).Value))}
+		},
+		"keys": func(e *Evaluator, args ...object.Object) object.Object {
+			hash := args[0].(*object.HashMap)
+
+			if err := e.allocate(int64(len(hash.Value)) * sizeOfElement); err != nil {
+				return err
+			}
+
+			keys := []object.Object{}
+
+			for _, key := range sortedKeys(hash) {
+				keys = append(keys, keyObject(key))
+			}
+
+			return &object.Array{Value: keys}
+		},
+		"values": func(e *Evaluator, args ...object.Object) object.Object {
+			hash := args[0].(*object.HashMap)
+
+			if err := e.allocate(int64(len(hash.Value)) * sizeOfElement); err != nil {
+				return err
+			}
+
+			values := []object.Object{}
+
+			for _, key := range sortedKeys(hash) {
+				values = append(values, hash.Value[key])
+			}
+
+			return &object.Array{Value: values}
+		},
+		"has": func(e *Evaluator, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("has expects a key")
+			}
+
+			key, ok := args[1].(object.Hashable)
+
+			if !ok {
+				return newError("invalid key type: %s", args[1].Type())
+			}
+
+			_, found := args[0].(*object.HashMap).Value[key.Hashkey()]
+			return nativeBoolToObject(found)
+		},
+	},
+}
+
+// MethodNames returns the names of the builtin methods of values of type typ, sorted
+func (e *Evaluator) MethodNames(typ object.ObjectType) []string {
+	names := []string{}
+
+	for name := range e.methods[typ] {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// stringArg returns the i-th argument when it is a string
+func stringArg(args []object.Object, i int) (string, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+
+	str, ok := args[i].(*object.String)
+
+	if !ok {
+		return "", false
+	}
+
+	return str.Value, true
+}
+
+// newString makes the string build returns, charging its size bytes
+// against the memory limit before it is built
+func (e *Evaluator) newString(size int64, build func() string) object.Object {
+	if err := e.allocate(size); err != nil {
+		return err
+	}
+
+	value := build()
+
+	// changing the case of some letters changes their length in UTF-8
+	if grown := int64(len(value)) - size; grown > 0 {
+		if err := e.allocate(grown); err != nil {
+			return err
+		}
+	}
+
+	return &object.String{Value: value}
+}
+
+// sortedKeys returns the keys of hash in a stable order, integers first
+func sortedKeys(hash *object.HashMap) []object.Hash {
+	keys := []object.Hash{}
+
+	for key := range hash.Value {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+
+		if keys[i].Type == object.OBJECT_INTEGER {
+			a, _ := strconv.ParseInt(keys[i].Value, 10, 64)
+			b, _ := strconv.ParseInt(keys[j].Value, 10, 64)
+			return a < b
+		}
+
+		return keys[i].Value < keys[j].Value
+	})
+
+	return keys
+}
+
+// keyObject returns the value a hashmap key was made from
+func keyObject(key object.Hash) object.Object {
+	if key.Type == object.OBJECT_INTEGER {
+		value, _ := strconv.ParseInt(key.Value, 10, 64)
+		return &object.Integer{Value: value}
+	}
+
+	return &object.String{Value: key.Value}
+}
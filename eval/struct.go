@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"Klang/ast"
+	"Klang/object"
+)
+
+func (e *Evaluator) evalStructStatement(node *ast.StructStatement, env *object.Environment) object.Object {
+	def := &object.StructType{Name: node.Name.Value, Fields: []string{}}
+
+	for _, field := range node.Fields {
+		def.Fields = append(def.Fields, field.Value)
+	}
+
+	if err := env.Declare(node.Name.Value, def, node.Name); err != nil {
+		return newError("%s", err)
+	}
+
+	return NILL
+}
+
+// construct builds a value of def from args, one for each field in order
+func (e *Evaluator) construct(def *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(def.Fields) {
+		return newError("wrong number of arguments to %s: expected %d, got %d", def.Name, len(def.Fields), len(args))
+	}
+
+	if err := e.allocate(int64(len(args)) * sizeOfElement); err != nil {
+		return err
+	}
+
+	return &object.Struct{Def: def, Fields: append([]object.Object{}, args...)}
+}
+
+func (e *Evaluator) evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := e.eval(node.Object, env)
+
+	if isError(obj) {
+		return obj
+	}
+
+	return e.member(obj, node.Member.Value)
+}
+
+// member returns what `obj.name` reads: a field or method of a struct, the
+// value of a string key of a hashmap, or else a builtin method bound to obj.
+// Like an index, a missing key of a hashmap is nil
+func (e *Evaluator) member(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Struct:
+		if field := obj.Def.Field(name); field >= 0 {
+			return obj.Fields[field]
+		}
+
+		if method, ok := obj.Def.Method(name); ok {
+			return &object.Method{Receiver: obj, Function: method}
+		}
+
+		return newError("%s has no field or method %s", obj.Def.Name, name)
+
+	case *object.StructType:
+		// `Point.dist` is the method itself, taking the receiver as an argument
+		if method, ok := obj.Method(name); ok {
+			return method
+		}
+
+		return newError("%s has no method %s", obj.Name, name)
+
+	case *object.HashMap:
+		if val, ok := obj.Value[(&object.String{Value: name}).Hashkey()]; ok {
+			return val
+		}
+
+	case object.Indexer:
+		return obj.Index(&object.String{Value: name})
+	}
+
+	if method, ok := e.methods[obj.Type()][name]; ok {
+		return &BoundMethod{Receiver: obj, Name: name, Fn: method}
+	}
+
+	if obj.Type() == object.OBJECT_HASHMAP {
+		return NILL
+	}
+
+	return newError("%s has no method %s", obj.Type(), name)
+}
+
+func (e *Evaluator) evalMemberAssignmentExpression(node *ast.MemberAssignmentExpression, env *object.Environment) object.Object {
+	target := e.eval(node.Target.Object, env)
+
+	if isError(target) {
+		return target
+	}
+
+	val := e.eval(node.Value, env)
+
+	if isError(val) {
+		return val
+	}
+
+	if hash, ok := target.(*object.HashMap); ok {
+		return e.setKey(hash, &object.String{Value: node.Target.Member.Value}, val)
+	}
+
+	if assigner, ok := target.(object.IndexAssigner); ok {
+		if err := assigner.SetIndex(&object.String{Value: node.Target.Member.Value}, val); err != nil {
+			return err
+		}
+
+		return NILL
+	}
+
+	value, ok := target.(*object.Struct)
+
+	if !ok {
+		return newError("cannot assign to field %s of %s", node.Target.Member.Value, target.Type())
+	}
+
+	if value.Frozen {
+		return newError("cannot assign to a field of a frozen %s", value.Def.Name)
+	}
+
+	field := value.Def.Field(node.Target.Member.Value)
+
+	if field < 0 {
+		return newError("%s has no field %s", value.Def.Name, node.Target.Member.Value)
+	}
+
+	value.Fields[field] = val
+	return NILL
+}
+
+// structsEqual reports whether left and right are values of the same struct
+// type whose fields are equal. The pairs in seen are the ones being compared
+// further up: meeting one again means both values are cyclic, and they are
+// then only equal when they are the same value
+func structsEqual(left, right *object.Struct, seen map[[2]*object.Struct]bool) bool {
+	if left == right {
+		return true
+	}
+
+	pair := [2]*object.Struct{left, right}
+
+	if left.Def != right.Def || seen[pair] {
+		return false
+	}
+
+	seen[pair] = true
+	defer delete(seen, pair)
+
+	for i, field := range left.Fields {
+		leftField, leftOk := field.(*object.Struct)
+		rightField, rightOk := right.Fields[i].(*object.Struct)
+
+		if leftOk && rightOk {
+			if !structsEqual(leftField, rightField, seen) {
+				return false
+			}
+
+			continue
+		}
+
+		if evalInfix("==", field, right.Fields[i]) != TRUE {
+			return false
+		}
+	}
+
+	return true
+}
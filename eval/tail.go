@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"Klang/ast"
+	"Klang/object"
+	"Klang/token"
+	"fmt"
+)
+
+// A call is in tail position when the function making it returns whatever
+// the call returns: the value of a `return`, or the last expression of the
+// body, through the arms of an `if` or a `match`. Such a call is not made
+// where it is met: it is handed back to apply as a *tailCall, which runs it
+// in place of the function that made it. Recursion through tail calls thus
+// needs no Go stack and does not count towards Limits.MaxDepth:
+//
+//	fn sum(n, acc) { if n == 0 { acc } else { sum(n - 1, acc + n) } }
+//	fn count(n) { while n > 0 { return count(n - 1); } n }
+
+// tailCall is a call in tail position left for apply to make
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	site token.Token
+}
+
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return "TAIL_CALL"
+}
+
+// evalTail evaluates an expression in tail position
+func (e *Evaluator) evalTail(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.FunctionCallExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		fn := e.eval(node.Function, env)
+
+		if isError(fn) {
+			return fn
+		}
+
+		args := e.eval(node.Args, env)
+
+		if isError(args) {
+			return args
+		}
+
+		return &tailCall{fn: fn, args: args.(*object.Array).Value, site: ast.StartToken(node.Function)}
+
+	case *ast.IfExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		condition := e.eval(node.Condition, env)
+
+		if isError(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return e.evalTailBlock(node.IfArm, env)
+		}
+
+		if node.ElseArm == nil {
+			return NILL
+		}
+
+		return e.evalTailBlock(node.ElseArm, env)
+
+	case *ast.MatchExpression:
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		arm, armEnv, err := e.selectArm(node, env)
+
+		if err != nil {
+			return err
+		}
+
+		if block, ok := arm.Body.(*ast.BlockStatement); ok {
+			return e.evalTailBlock(block, armEnv)
+		}
+
+		return e.evalTail(arm.Body, armEnv)
+
+	default:
+		return e.eval(node, env)
+	}
+}
+
+// evalTailBlock evaluates a block whose value is in tail position, its last
+// expression being in tail position too
+func (e *Evaluator) evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
+	last := len(block.Statements) - 1
+
+	if last < 0 {
+		return NILL
+	}
+
+	if result := e.evalProgram(block.Statements[:last], env); result.Type() == object.OBJECT_RETURN || isError(result) {
+		return result
+	}
+
+	if stmt, ok := block.Statements[last].(*ast.ExpressionStatement); ok {
+		return e.evalTail(stmt.Expression, env)
+	}
+
+	return e.eval(block.Statements[last], env)
+}
+
+// maxTrace is the number of calls kept in the trace of an error, the
+// innermost ones, so that a runaway recursion does not flood the output
+const maxTrace = 20
+
+// frame is a call of fn made at site, recorded for the trace of errors
+type frame struct {
+	fn   *object.Function
+	site token.Token
+}
+
+// pushFrame appends f to frames, forgetting the outermost frame past maxTrace
+func pushFrame(frames []frame, f frame) []frame {
+	frames = append(frames, f)
+
+	if len(frames) > maxTrace {
+		frames = frames[1:]
+	}
+
+	return frames
+}
+
+// traced adds frames, innermost last, to the trace of result when it is an
+// error. Calls made by the host have no site and are left out
+func traced(result object.Object, frames []frame) object.Object {
+	err, ok := result.(*object.Error)
+
+	if !ok {
+		return result
+	}
+
+	for i := len(frames) - 1; i >= 0 && len(err.Trace) < maxTrace; i-- {
+		if frames[i].site.Line == 0 {
+			continue
+		}
+
+		name := frames[i].fn.Name
+
+		if name == "" {
+			name = "anonymous function"
+		}
+
+		err.Trace = append(err.Trace, fmt.Sprintf("%s called at %d:%d", name, frames[i].site.Line, frames[i].site.Column))
+	}
+
+	return err
+}
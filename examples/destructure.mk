@@ -0,0 +1,8 @@
+fn describe({name, "age": years}) {
+	println(name, " is ", years, " years old");
+}
+
+let [first, ...rest] = [{"name": "Ann", "age": 31}, {"name": "Bo", "age": 4}];
+
+describe(first);
+println(len(rest));
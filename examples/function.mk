@@ -6,3 +6,14 @@ let add = fn(x) {
 
 let addTwo = add(2);
 addTwo(7)
+
+// declarations are hoisted, fib can be called before it is declared
+println(fib(10));
+
+fn fib(n) {
+	if n < 2 {
+		return n;
+	}
+
+	fib(n - 1) + fib(n - 2)
+}
@@ -0,0 +1,27 @@
+struct Point { x, y }
+
+trait Shape {
+	fn area(self);
+
+	fn describe(self) {
+		["area", self.area()];
+	}
+}
+
+impl Point {
+	fn add(self, other) {
+		Point(self.x + other.x, self.y + other.y);
+	}
+}
+
+impl Shape for Point {
+	fn area(self) {
+		self.x * self.y;
+	}
+}
+
+let p = Point(1, 2).add(Point(3, 4));
+
+println(p);
+println(p.describe());
+println(Point.area(p));
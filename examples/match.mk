@@ -0,0 +1,14 @@
+fn classify(n) {
+	match n {
+		0 => "zero",
+		x if x < 0 => "negative",
+		_ => "positive",
+	}
+}
+
+let i = -2;
+
+while i < 3 {
+	println(classify(i));
+	i = i + 1;
+}
@@ -0,0 +1,19 @@
+fn isLong(word) {
+	word.len() > 3;
+}
+
+fn shout(word) {
+	word.upper();
+}
+
+let words = "the quick brown fox".split(" ");
+println(words.filter(isLong).map(shout).join(", "));
+
+let counts = {"words": words.len()};
+counts.letters = words.map(fn(word) {
+	word.len();
+}).reduce(fn(total, n) {
+	total + n;
+}, 0);
+
+println(counts.words, " words, ", counts.letters, " letters");
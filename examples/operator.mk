@@ -0,0 +1,38 @@
+struct Vec { x, y }
+
+impl Vec {
+	fn __add__(self, other) {
+		Vec(self.x + other.x, self.y + other.y);
+	}
+
+	fn __mul__(self, k) {
+		Vec(self.x * k, self.y * k);
+	}
+
+	fn __eq__(self, other) {
+		if self.x == other.x {
+			self.y == other.y;
+		} else {
+			false;
+		}
+	}
+
+	fn __lt__(self, other) {
+		self.norm() < other.norm();
+	}
+
+	fn __index__(self, i) {
+		[self.x, self.y][i];
+	}
+
+	fn norm(self) {
+		self.x * self.x + self.y * self.y;
+	}
+}
+
+let v = Vec(1, 2) + Vec(3, 4) * 2;
+
+println(v);
+println(v[0]);
+println(v == Vec(7, 10));
+println(Vec(0, 1) < v);
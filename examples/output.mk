@@ -0,0 +1,8 @@
+let name = "sobri";
+let age = 42;
+
+print("Name is", name);
+println("Name:", name);
+printf("%s is %d years old", name, age);
+println("");
+eprint("this goes to stderr");
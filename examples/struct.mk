@@ -0,0 +1,11 @@
+struct Point { x, y }
+
+fn add(a, b) {
+	Point(a.x + b.x, a.y + b.y);
+}
+
+let p = add(Point(1, 2), Point(3, 4));
+p.y = p.y * 10;
+
+println(p);
+println(p == Point(4, 60));
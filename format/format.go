@@ -0,0 +1,736 @@
+// Package format prints K syntax trees back as source code, in the single
+// canonical layout enforced by `klang fmt`: tab indentation, one statement
+// per line, spaces around binary operators and `;` after every statement
+// but declarations, loops and if or match statements. Comments and single
+// blank lines between statements are kept.
+package format
+
+import (
+	"Klang/ast"
+	"Klang/lexer"
+	"Klang/parser"
+	"Klang/token"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Source parses src and returns it formatted. The first syntax error is
+// returned when src cannot be parsed, and an error is returned as well for
+// a comment inside an expression printed on a single line, rather than
+// moving it away
+func Source(src string) (string, error) {
+	parse := parser.New(lexer.New(src))
+	program := parse.ParseProgram()
+
+	if len(parse.Errors()) > 0 {
+		return "", parse.Errors()[0]
+	}
+
+	p := &printer{comments: program.Comments}
+	formatted := p.program(program)
+
+	if len(p.moved) > 0 {
+		comment := p.moved[0]
+		return "", &parser.Error{Line: comment.Line, Column: comment.Column, Message: "comment inside an expression printed on one line, move it out of the expression"}
+	}
+
+	return formatted, nil
+}
+
+// Program returns the canonical source of program, comments included. A
+// comment inside an expression printed on a single line follows the
+// statement holding it
+func Program(program *ast.Program) string {
+	p := &printer{comments: program.Comments}
+	return p.program(program)
+}
+
+func (p *printer) program(program *ast.Program) string {
+	p.statements(program.Statements, -1)
+
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+
+	return p.out.String()
+}
+
+// Node returns the canonical source of a single node, without comments
+func Node(node ast.Node) string {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		return Program(node)
+
+	case *ast.Parameter:
+		p.parameter(node)
+
+	case ast.Statement:
+		p.statement(node)
+
+	case ast.Expression:
+		p.expression(node, parser.LOWEST)
+	}
+
+	return p.out.String()
+}
+
+type printer struct {
+	out      bytes.Buffer
+	indent   int
+	comments []token.Token // comments not printed yet
+	moved    []token.Token // comments printed after the line they were on
+	lastLine int           // last source line printed so far
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.write("\n")
+	p.write(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) seen(tok token.Token) {
+	if tok.Line > p.lastLine {
+		p.lastLine = tok.Line
+	}
+}
+
+// statements prints a statement list, one per line, with the comments found
+// before line end. A negative end flushes every remaining comment
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	first := true
+	open := -1 // where the `;` of an if or match statement goes when the next one needs it
+
+	for _, stmt := range stmts {
+		line := ast.StartToken(stmt).Line
+		first = p.flushComments(line, first)
+		p.lineBreak(line, first)
+
+		start := p.out.Len()
+		p.statement(stmt)
+
+		// `(`, `[` or `-` would continue the if or match before as a
+		// call, an index or a subtraction
+		if open >= 0 && start < p.out.Len() && strings.ContainsRune("([-", rune(p.out.Bytes()[start])) {
+			p.insert(open, ";")
+		}
+
+		open = -1
+
+		switch {
+		case !terminated(stmt):
+			p.write(";")
+
+		case isExpressionStatement(stmt):
+			open = p.out.Len()
+		}
+
+		first = false
+	}
+
+	if end < 0 {
+		end = int(^uint(0) >> 1)
+	}
+
+	p.flushComments(end, first)
+}
+
+// terminated reports whether stmt ends with its `}`, without a `;`: the
+// declarations, loops, and the if and match expressions used as statements
+func terminated(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.FunctionStatement:
+		// but for the methods a trait requires, which have no body
+		return stmt.Function.Body != nil
+
+	case *ast.StructStatement, *ast.ImplStatement, *ast.TraitStatement, *ast.WhileStatement:
+		return true
+
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression:
+			return true
+		}
+	}
+
+	return false
+}
+
+func isExpressionStatement(stmt ast.Statement) bool {
+	_, ok := stmt.(*ast.ExpressionStatement)
+	return ok
+}
+
+// insert writes s at offset of the output printed so far
+func (p *printer) insert(offset int, s string) {
+	tail := append([]byte(s), p.out.Bytes()[offset:]...)
+	p.out.Truncate(offset)
+	p.out.Write(tail)
+}
+
+// lineBreak starts the line of a statement found at line, keeping a single
+// blank line when the source had one or more
+func (p *printer) lineBreak(line int, first bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+
+	if !first && line > p.lastLine+1 {
+		p.write("\n")
+	}
+
+	p.newline()
+}
+
+// flushComments prints the comments found before line. A comment on the
+// line of the previous token stays at the end of that line. It returns
+// whether the enclosing list is still empty
+func (p *printer) flushComments(line int, first bool) bool {
+	for len(p.comments) > 0 && p.comments[0].Line < line {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		// the tokens around the comment were printed on a single line
+		if comment.Line < p.lastLine {
+			p.moved = append(p.moved, comment)
+		}
+
+		if comment.Line == p.lastLine && p.out.Len() > 0 {
+			p.write(" ")
+		} else {
+			p.lineBreak(comment.Line, first)
+			first = false
+		}
+
+		p.write(strings.TrimRight(comment.Literal, " \t\r"))
+		p.lastLine = comment.Line
+	}
+
+	return first
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.seen(stmt.Token)
+		p.write(stmt.Token.Literal + " ")
+		p.pattern(stmt.Target())
+
+		if stmt.Type != nil {
+			p.write(": " + stmt.Type.String())
+		}
+
+		p.write(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+
+	case *ast.FunctionStatement:
+		p.seen(stmt.Token)
+		p.write("fn " + stmt.Name.Value)
+		p.function(stmt.Function)
+
+	case *ast.StructStatement:
+		p.seen(stmt.Token)
+		p.write(stmt.String())
+		p.seen(stmt.Rbrace)
+
+	case *ast.ImplStatement:
+		p.seen(stmt.Token)
+
+		if stmt.Trait != nil {
+			p.write("impl " + stmt.Trait.Value + " for " + stmt.Type.Value + " ")
+		} else {
+			p.write("impl " + stmt.Type.Value + " ")
+		}
+
+		p.methods(stmt.Methods, stmt.Rbrace)
+
+	case *ast.TraitStatement:
+		p.seen(stmt.Token)
+		p.write("trait " + stmt.Name.Value + " ")
+		p.methods(stmt.Methods, stmt.Rbrace)
+
+	case *ast.ReturnStatement:
+		p.seen(stmt.Token)
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+
+	case *ast.WhileStatement:
+		p.seen(stmt.Token)
+		p.write("while ")
+		p.expression(stmt.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(stmt.Body)
+
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.seen(block.Token)
+	p.write("{")
+
+	if len(block.Statements) == 0 && !p.hasCommentBefore(block.Rbrace.Line) {
+		p.write("}")
+		p.seen(block.Rbrace)
+		return
+	}
+
+	p.indent++
+	p.statements(block.Statements, block.Rbrace.Line)
+	p.indent--
+
+	p.newline()
+	p.write("}")
+	p.seen(block.Rbrace)
+}
+
+// methods prints the methods of an impl or trait like the statements of a block
+func (p *printer) methods(methods []*ast.FunctionStatement, rbrace token.Token) {
+	p.write("{")
+
+	if len(methods) == 0 && !p.hasCommentBefore(rbrace.Line) {
+		p.write("}")
+		p.seen(rbrace)
+		return
+	}
+
+	stmts := []ast.Statement{}
+
+	for _, method := range methods {
+		stmts = append(stmts, method)
+	}
+
+	p.indent++
+	p.statements(stmts, rbrace.Line)
+	p.indent--
+
+	p.newline()
+	p.write("}")
+	p.seen(rbrace)
+}
+
+func (p *printer) hasCommentBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Line < line
+}
+
+// expression prints expr, wrapped in parentheses when it binds less tightly than prec
+func (p *printer) expression(expr ast.Expression, prec int) {
+	if expr == nil {
+		return
+	}
+
+	if precedenceOf(expr) < prec {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.seen(expr.Token)
+		p.write(expr.Value)
+
+	case *ast.IntegerLiteral:
+		p.seen(expr.Token)
+		p.write(strconv.FormatInt(expr.Value, 10))
+
+	case *ast.FloatLiteral:
+		p.seen(expr.Token)
+
+		if expr.Token.Literal != "" {
+			p.write(expr.Token.Literal)
+		} else {
+			p.write(strconv.FormatFloat(expr.Value, 'f', -1, 64))
+		}
+
+	case *ast.StringLiteralExpression:
+		p.seen(expr.Token)
+		p.write("\"" + expr.Value + "\"")
+
+	case *ast.BooleanLiteral:
+		p.seen(expr.Token)
+		p.write(strconv.FormatBool(expr.Value))
+
+	case *ast.PrefixExpression:
+		p.seen(expr.Token)
+		p.write(expr.Operator)
+		p.expression(expr.Right, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		opPrec := parser.Precedence(expr.Token.Type)
+		p.expression(expr.Left, opPrec)
+		p.write(" " + expr.Operator + " ")
+		p.expression(expr.Right, opPrec+1)
+
+	case *ast.AssignmentExpression:
+		p.write(expr.Ident.Value)
+		p.write(" = ")
+		p.expression(expr.Value, parser.LOWEST)
+
+	case *ast.IndexAssignmentExpression:
+		p.expression(expr.Target, parser.LOWEST)
+		p.write(" = ")
+		p.expression(expr.Value, parser.LOWEST)
+
+	case *ast.MemberAssignmentExpression:
+		p.expression(expr.Target, parser.LOWEST)
+		p.write(" = ")
+		p.expression(expr.Value, parser.LOWEST)
+
+	case *ast.IfExpression:
+		p.seen(expr.Token)
+		p.write("if ")
+		p.expression(expr.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(expr.IfArm)
+
+		if expr.ElseArm != nil {
+			p.write(" else ")
+			p.elseArm(expr.ElseArm)
+		}
+
+	case *ast.MatchExpression:
+		p.match(expr)
+
+	case *ast.FunctionLiteralExpression:
+		p.seen(expr.Token)
+		p.write("fn")
+		p.function(expr)
+
+	case *ast.FunctionCallExpression:
+		p.expression(expr.Function, parser.CALL)
+		p.write("(")
+		p.expressionList(expr.Args)
+		p.write(")")
+
+	case *ast.SpreadExpression:
+		p.seen(expr.Token)
+		p.write("...")
+		p.expression(expr.Value, parser.LOWEST)
+
+	case *ast.ArrayLiteralExpression:
+		p.array(expr)
+
+	case *ast.IndexExpression:
+		p.expression(expr.Ident, parser.CALL)
+		p.write("[")
+		p.expression(expr.Index, parser.LOWEST)
+		p.write("]")
+
+	case *ast.MemberExpression:
+		p.expression(expr.Object, parser.CALL)
+		p.write("." + expr.Member.Value)
+
+	case *ast.HashmapLiteralExpression:
+		p.hashmap(expr)
+	}
+}
+
+// elseArm prints the else arm of an if, an `else if` chain being parsed as
+// an arm holding nothing but the next if
+func (p *printer) elseArm(block *ast.BlockStatement) {
+	if block.Token.Type == token.IF && len(block.Statements) == 1 {
+		if stmt, ok := block.Statements[0].(*ast.ExpressionStatement); ok {
+			p.expression(stmt.Expression, parser.LOWEST)
+			return
+		}
+	}
+
+	p.block(block)
+}
+
+// match prints the arms of expr one per line, a comma ending the arms whose
+// body is not a block
+func (p *printer) match(expr *ast.MatchExpression) {
+	p.seen(expr.Token)
+	p.write("match ")
+	p.expression(expr.Value, parser.LOWEST)
+	p.write(" {")
+
+	if len(expr.Arms) == 0 && !p.hasCommentBefore(expr.Rbrace.Line) {
+		p.write("}")
+		p.seen(expr.Rbrace)
+		return
+	}
+
+	p.indent++
+
+	for i, arm := range expr.Arms {
+		line := ast.StartToken(arm).Line
+		first := p.flushComments(line, i == 0)
+		p.lineBreak(line, first)
+
+		p.pattern(arm.Pattern)
+
+		if arm.Guard != nil {
+			p.write(" if ")
+			p.expression(arm.Guard, parser.LOWEST)
+		}
+
+		p.write(" => ")
+
+		switch body := arm.Body.(type) {
+		case *ast.BlockStatement:
+			p.block(body)
+
+		case *ast.HashmapLiteralExpression:
+			// a `{` after `=>` would start a block
+			p.write("(")
+			p.hashmap(body)
+			p.write("),")
+
+		default:
+			p.expression(body, parser.LOWEST)
+			p.write(",")
+		}
+	}
+
+	p.flushComments(expr.Rbrace.Line, false)
+	p.indent--
+	p.newline()
+	p.write("}")
+	p.seen(expr.Rbrace)
+}
+
+func (p *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		p.seen(pattern.Token)
+		p.write(pattern.Value)
+
+	case *ast.LiteralPattern:
+		p.expression(pattern.Value, parser.LOWEST)
+
+	case *ast.ArrayPattern:
+		p.seen(pattern.Token)
+		p.write("[")
+
+		for i, elem := range pattern.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+
+			p.pattern(elem)
+		}
+
+		if pattern.Rest != nil {
+			if len(pattern.Elements) > 0 {
+				p.write(", ")
+			}
+
+			p.write("..." + pattern.Rest.Value)
+		}
+
+		p.write("]")
+
+	case *ast.HashPattern:
+		p.seen(pattern.Token)
+		p.write("{")
+
+		for i, key := range pattern.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+
+			if !pattern.Shorthand(i) {
+				p.expression(key, parser.LOWEST)
+				p.write(": ")
+			}
+
+			p.pattern(pattern.Values[i])
+		}
+
+		p.write("}")
+
+	case *ast.AlternativePattern:
+		for i, alt := range pattern.Alternatives {
+			if i > 0 {
+				p.write(" | ")
+			}
+
+			p.pattern(alt)
+		}
+	}
+}
+
+// function prints the parameters, result type and body of fn
+func (p *printer) function(fn *ast.FunctionLiteralExpression) {
+	p.write("(")
+
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			p.write(", ")
+		}
+
+		p.parameter(param)
+	}
+
+	p.write(")")
+
+	if fn.ReturnType != nil {
+		p.write(" -> " + fn.ReturnType.String())
+	}
+
+	// the methods a trait requires have no body
+	if fn.Body != nil {
+		p.write(" ")
+		p.block(fn.Body)
+	}
+}
+
+func (p *printer) parameter(param *ast.Parameter) {
+	if param.Rest {
+		p.write("...")
+	}
+
+	p.pattern(param.Target())
+
+	if param.Type != nil {
+		p.write(": " + param.Type.String())
+	}
+
+	if param.Default != nil {
+		p.write(" = ")
+		p.expression(param.Default, parser.ASSIGN+1)
+	}
+}
+
+func (p *printer) expressionList(list *ast.ExpressionList) {
+	if list == nil {
+		return
+	}
+
+	for i, expr := range list.List {
+		if i > 0 {
+			p.write(", ")
+		}
+
+		p.expression(expr, parser.LOWEST)
+	}
+
+	p.seen(list.End)
+}
+
+// array keeps the layout chosen by the author: elements written on their
+// own lines are printed one per line, otherwise the array stays on one line
+func (p *printer) array(array *ast.ArrayLiteralExpression) {
+	p.seen(array.Token)
+	multiline := false
+
+	for _, elem := range array.Elements.List {
+		if ast.StartToken(elem).Line > array.Token.Line {
+			multiline = true
+		}
+	}
+
+	if !multiline {
+		p.write("[")
+		p.expressionList(array.Elements)
+		p.write("]")
+		return
+	}
+
+	p.write("[")
+	p.indent++
+
+	for i, elem := range array.Elements.List {
+		if i > 0 {
+			p.write(",")
+		}
+
+		line := ast.StartToken(elem).Line
+		first := p.flushComments(line, i == 0)
+		p.lineBreak(line, first)
+		p.expression(elem, parser.LOWEST)
+	}
+
+	p.flushComments(array.Elements.End.Line, false)
+	p.indent--
+	p.newline()
+	p.write("]")
+	p.seen(array.Elements.End)
+}
+
+// hashmap keeps the layout chosen by the author: pairs written on their own
+// lines are printed one per line, otherwise the hashmap stays on one line
+func (p *printer) hashmap(hash *ast.HashmapLiteralExpression) {
+	p.seen(hash.Token)
+	multiline := false
+
+	for _, key := range hash.Keys {
+		if ast.StartToken(key).Line > hash.Token.Line {
+			multiline = true
+		}
+	}
+
+	if !multiline {
+		p.write("{")
+
+		for i, key := range hash.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+
+			p.pair(key, hash.Map[key])
+		}
+
+		p.write("}")
+		p.seen(hash.Rbrace)
+		return
+	}
+
+	p.write("{")
+	p.indent++
+
+	for i, key := range hash.Keys {
+		if i > 0 {
+			p.write(",")
+		}
+
+		line := ast.StartToken(key).Line
+		first := p.flushComments(line, i == 0)
+		p.lineBreak(line, first)
+		p.pair(key, hash.Map[key])
+	}
+
+	p.flushComments(hash.Rbrace.Line, false)
+	p.indent--
+	p.newline()
+	p.write("}")
+	p.seen(hash.Rbrace)
+}
+
+func (p *printer) pair(key, value ast.Expression) {
+	p.expression(key, parser.LOWEST)
+	p.write(": ")
+	p.expression(value, parser.LOWEST)
+}
+
+// precedenceOf is the binding power of expr as the parser sees it, operands
+// are only wrapped in parentheses when they bind less tightly than required
+func precedenceOf(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(expr.Token.Type)
+
+	case *ast.AssignmentExpression, *ast.IndexAssignmentExpression, *ast.MemberAssignmentExpression:
+		return parser.ASSIGN
+
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+
+	case *ast.FunctionCallExpression:
+		return parser.CALL
+
+	case *ast.IndexExpression, *ast.MemberExpression:
+		return parser.INDEX
+
+	default:
+		return parser.INDEX + 1
+	}
+}
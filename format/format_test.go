@@ -0,0 +1,164 @@
+package format
+
+import (
+	"Klang/eval"
+	"Klang/lexer"
+	"Klang/object"
+	"Klang/parser"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let y = (1 + 2) * 3;", "let y = (1 + 2) * 3;\n"},
+		{"let f = fn(a,b){return a}", "let f = fn(a, b) {\n\treturn a;\n};\n"},
+		{"if x { 1 } else { 2 }", "if x {\n\t1;\n} else {\n\t2;\n}\n"},
+		{"let h = {\"a\": 1, \"b\": [1,2]}", "let h = {\"a\": 1, \"b\": [1, 2]};\n"},
+		{"let h = {\n\"b\": 1,\n\"a\": 2}", "let h = {\n\t\"b\": 1,\n\t\"a\": 2\n};\n"},
+		{"let x = 1; // one\n\n\n// two\nx", "let x = 1; // one\n\n// two\nx;\n"},
+		{"x = -(1 + 2)", "x = -(1 + 2);\n"},
+		{"let e = fn() {}", "let e = fn() {};\n"},
+		{"let xs:[int]=[1]", "let xs: [int] = [1];\n"},
+		{"fn add(a:int,b){a+b}\nadd(1,2)", "fn add(a: int, b) {\n\ta + b;\n}\nadd(1, 2);\n"},
+		{"let f = fn(a,b=a*2,...rest){f(...rest,a)}", "let f = fn(a, b = a * 2, ...rest) {\n\tf(...rest, a);\n};\n"},
+		{"let f = fn(a:string,b:{string:[int]})->fn(int)->bool{}", "let f = fn(a: string, b: {string: [int]}) -> fn(int) -> bool {};\n"},
+		{"const limit=10", "const limit = 10;\n"},
+		{"a[i+1]=h[\"k\"]", "a[i + 1] = h[\"k\"];\n"},
+		{"if a {1} else if b {2} else {3}", "if a {\n\t1;\n} else if b {\n\t2;\n} else {\n\t3;\n}\n"},
+		{"let [a,...rest]=xs\nconst {name,\"n\":n}=h", "let [a, ...rest] = xs;\nconst {name, \"n\": n} = h;\n"},
+		{"fn f([x,y],{z}={\"z\":1}){x}", "fn f([x, y], {z} = {\"z\": 1}) {\n\tx;\n}\n"},
+		{"match x {1|2=>\"a\", [h,...t] if h>0=>{t} {name}=>({\"n\": name}), _=>-1}", "match x {\n\t1 | 2 => \"a\",\n\t[h, ...t] if h > 0 => {\n\t\tt;\n\t}\n\t{name} => ({\"n\": name}),\n\t_ => -1,\n}\n"},
+		{"struct Point {x,y,}\np.x=p.y+1", "struct Point { x, y }\np.x = p.y + 1;\n"},
+		{"struct Empty {\n}\nf(a).b[0].c", "struct Empty {}\nf(a).b[0].c;\n"},
+		{"trait Shape{fn area(self)->int\n// name\nfn name(self){\"s\"}}", "trait Shape {\n\tfn area(self) -> int;\n\t// name\n\tfn name(self) {\n\t\t\"s\";\n\t}\n}\n"},
+		{"let arr = [1, // one\n  2]", "let arr = [\n\t1, // one\n\t2\n];\n"},
+		{"let arr = [\n1,\n// two\n2 // last\n]\nlet b = [1, [2,\n3]] // end", "let arr = [\n\t1,\n\t// two\n\t2 // last\n];\nlet b = [1, [\n\t2,\n\t3\n]]; // end\n"},
+		{"impl Shape for P{fn area(self){1}\n\n\nfn b(self){2}}\nimpl P {\n}", "impl Shape for P {\n\tfn area(self) {\n\t\t1;\n\t}\n\n\tfn b(self) {\n\t\t2;\n\t}\n}\nimpl P {}\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+
+		if err != nil {
+			t.Fatalf("Source(%q) returned an error: %s", tt.input, err)
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	for _, input := range []string{"let = 1", "struct Point { x, x }", "p. = 1", "impl P for {}", "trait T { let x = 1 }"} {
+		if _, err := Source(input); err == nil {
+			t.Errorf("Source(%q) did not report the syntax error", input)
+		}
+	}
+}
+
+func TestMovedCommentError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(1, // one\n  2)", "1:6: comment inside an expression printed on one line, move it out of the expression"},
+		{"let x = 1 +\n// two\n2", "2:1: comment inside an expression printed on one line, move it out of the expression"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Source(tt.input); err == nil || err.Error() != tt.expected {
+			t.Errorf("Source(%q) error is not matching expected. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.mk")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once, err := Source(string(src))
+
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+
+		twice, err := Source(once)
+
+		if err != nil {
+			t.Fatalf("%s: formatted output does not parse: %s", file, err)
+		}
+
+		if once != twice {
+			t.Errorf("%s: formatting is not idempotent.\nonce=%q\ntwice=%q", file, once, twice)
+		}
+	}
+}
+
+// TestRoundTrip checks that formatting keeps what a program does: the
+// formatted source parses, evaluates to the same value and formats to itself
+func TestRoundTrip(t *testing.T) {
+	samples := []string{
+		`let f = fn(c) { if c { let a = 1; } let b = 2; b }; [f(true), f(false)]`,
+		`let {name, "age": years} = {"name": "k", "age": 3}; [name, years]`,
+		`let user = {"name": "k", "age": 3}; [user.name, user.age]`,
+		`let h = {"len": 5}; [h.len, {"a": 1}.len()]`,
+		`let h = {2: "b", 1: "a", "c": 3}; [h.keys(), h.values()]`,
+		`let f = fn() { 1 }; (f)()`,
+		`let x = 1; if x == 1 { [1] } else { [2] }; [3][0]`,
+		`let x = 1; if x == 1 { 5 } else { 6 }; -1`,
+		`let x = 1; if x == 1 { 5 } else { 6 } (1 + 2) * 3`,
+		`match 1 { 1 => fn(a) { a }, _ => fn(a) { 0 } }; (2)`,
+		`match 1 { _ => [5] } [0]`,
+		`fn f() { [1] } [f()[0], 2]`,
+		`let i = 0; while i < 2 { i = i + 1 } [i]`,
+		`struct P { x } [P(1).x]`,
+	}
+
+	for _, src := range samples {
+		once, err := Source(src)
+
+		if err != nil {
+			t.Fatalf("Source(%q) returned an error: %s", src, err)
+		}
+
+		twice, err := Source(once)
+
+		if err != nil {
+			t.Errorf("%q: formatted output does not parse: %s\n%s", src, err, once)
+			continue
+		}
+
+		if once != twice {
+			t.Errorf("%q: formatting is not idempotent.\nonce=%q\ntwice=%q", src, once, twice)
+		}
+
+		if want, got := evaluate(src), evaluate(once); want != got {
+			t.Errorf("%q: formatted program evaluates to %q, want %q", src, got, want)
+		}
+	}
+}
+
+func evaluate(src string) string {
+	program := parser.New(lexer.New(src)).ParseProgram()
+
+	var out bytes.Buffer
+	return eval.New(&out, &out).Eval(program, object.NewEnvironment()).Inspect()
+}
@@ -0,0 +1,556 @@
+package klang
+
+import (
+	"Klang/eval"
+	"Klang/object"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+)
+
+// Bind exposes an arbitrary Go value to scripts of this interpreter as name.
+// Functions become builtins, structs become host objects whose exported
+// fields and methods are reachable with `value["Field"]`
+func (i *Interpreter) Bind(name string, value interface{}) error {
+	if val := reflect.ValueOf(value); val.Kind() == reflect.Func {
+		i.evaluator.RegisterBuiltin(name, bindFunc(val, name))
+		return nil
+	}
+
+	obj, err := ToObject(value)
+
+	if err != nil {
+		return err
+	}
+
+	return i.define(name, obj)
+}
+
+// BindFunc wraps any Go function as a builtin. Arguments are converted to
+// the parameter types of fn, results are converted back with ToObject.
+// A trailing error result is raised as a runtime error, and functions with
+// several remaining results return them as an array
+func BindFunc(fn interface{}) (eval.BuiltinFn, error) {
+	val := reflect.ValueOf(fn)
+
+	if val.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot bind %T, expected a function", fn)
+	}
+
+	return bindFunc(val, funcName(val)), nil
+}
+
+func bindFunc(fn reflect.Value, name string) eval.BuiltinFn {
+	fnType := fn.Type()
+
+	return func(e *eval.Evaluator, args ...object.Object) object.Object {
+		call := &boundCall{e: e}
+		defer call.finish()
+
+		numIn := fnType.NumIn()
+
+		if fnType.IsVariadic() {
+			if len(args) < numIn-1 {
+				return newError("%s expects at least %d arguments, got %d", name, numIn-1, len(args))
+			}
+		} else if len(args) != numIn {
+			return newError("%s expects %d arguments, got %d", name, numIn, len(args))
+		}
+
+		in := []reflect.Value{}
+
+		for k, arg := range args {
+			var paramType reflect.Type
+
+			if fnType.IsVariadic() && k >= numIn-1 {
+				paramType = fnType.In(numIn - 1).Elem()
+			} else {
+				paramType = fnType.In(k)
+			}
+
+			val, err := fromObject(call, arg, paramType)
+
+			if err != nil {
+				return newError("%s: argument %d: %s", name, k+1, err)
+			}
+
+			in = append(in, val)
+		}
+
+		out := fn.Call(in)
+
+		// a failing callback without an error result to return it with
+		// fails the call instead
+		if failure := call.finish(); failure != nil {
+			return failure
+		}
+
+		// a trailing error is reported instead of returned
+		if len(out) > 0 && fnType.Out(len(out)-1) == errorType {
+			if err := out[len(out)-1]; !err.IsNil() {
+				return newError("%s: %s", name, err.Interface().(error))
+			}
+
+			out = out[:len(out)-1]
+		}
+
+		switch len(out) {
+		case 0:
+			return eval.NILL
+
+		case 1:
+			return toObject(out[0])
+
+		default:
+			results := []object.Object{}
+
+			for _, val := range out {
+				results = append(results, toObject(val))
+			}
+
+			return &object.Array{Value: results}
+		}
+	}
+}
+
+// toObject is the reflection based counterpart of ToObject
+func toObject(val reflect.Value) object.Object {
+	if !val.IsValid() {
+		return eval.NILL
+	}
+
+	if val.Type().Implements(objectType) && val.Kind() != reflect.Interface {
+		return val.Interface().(object.Object)
+	}
+
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			return eval.TRUE
+		}
+		return eval.FALSE
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: val.Int()}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &object.Integer{Value: int64(val.Uint())}
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: val.Float()}
+
+	case reflect.String:
+		return &object.String{Value: val.String()}
+
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.IsNil() {
+			return eval.NILL
+		}
+
+		elements := []object.Object{}
+
+		for k := 0; k < val.Len(); k++ {
+			elements = append(elements, toObject(val.Index(k)))
+		}
+
+		return &object.Array{Value: elements}
+
+	case reflect.Map:
+		if val.IsNil() {
+			return eval.NILL
+		}
+
+		hashMap := make(map[object.Hash]object.Object)
+		iter := val.MapRange()
+
+		for iter.Next() {
+			key, ok := toObject(iter.Key()).(object.Hashable)
+
+			if !ok {
+				continue
+			}
+
+			hashMap[key.Hashkey()] = toObject(iter.Value())
+		}
+
+		return &object.HashMap{Value: hashMap}
+
+	case reflect.Interface:
+		if val.IsNil() {
+			return eval.NILL
+		}
+
+		return toObject(val.Elem())
+
+	case reflect.Ptr:
+		if val.IsNil() {
+			return eval.NILL
+		}
+
+		if val.Elem().Kind() == reflect.Struct {
+			return &HostObject{Value: val}
+		}
+
+		return toObject(val.Elem())
+
+	case reflect.Struct:
+		// keep a pointer to a copy so pointer receiver methods are reachable
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		return &HostObject{Value: ptr}
+
+	case reflect.Func:
+		if val.IsNil() {
+			return eval.NILL
+		}
+
+		return bindFunc(val, funcName(val))
+
+	default:
+		return newError("cannot convert %s to a K value", val.Type())
+	}
+}
+
+// fromObject converts obj into a Go value of type typ, which is how
+// arguments reach bound functions. Outside of a call to run them in,
+// K functions cannot become Go functions
+func fromObject(call *boundCall, obj object.Object, typ reflect.Type) (reflect.Value, error) {
+	if typ.Implements(objectType) && reflect.TypeOf(obj).AssignableTo(typ) {
+		val := reflect.New(typ).Elem()
+		val.Set(reflect.ValueOf(obj))
+		return val, nil
+	}
+
+	if host, ok := obj.(*HostObject); ok {
+		if host.Value.Type().AssignableTo(typ) {
+			return host.Value, nil
+		}
+
+		if host.Value.Elem().Type().AssignableTo(typ) {
+			return host.Value.Elem(), nil
+		}
+	}
+
+	if obj.Type() == object.OBJECT_NILL {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(typ), nil
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		if typ.NumMethod() == 0 {
+			val := FromObject(obj)
+
+			if val == nil {
+				return reflect.Zero(typ), nil
+			}
+
+			return reflect.ValueOf(val), nil
+		}
+
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(typ), nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			val := reflect.New(typ).Elem()
+
+			if val.OverflowInt(i.Value) {
+				return val, fmt.Errorf("%d overflows %s", i.Value, typ)
+			}
+
+			val.SetInt(i.Value)
+			return val, nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*object.Integer); ok {
+			val := reflect.New(typ).Elem()
+
+			if i.Value < 0 || val.OverflowUint(uint64(i.Value)) {
+				return val, fmt.Errorf("%d overflows %s", i.Value, typ)
+			}
+
+			val.SetUint(uint64(i.Value))
+			return val, nil
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch num := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(num.Value).Convert(typ), nil
+
+		case *object.Integer:
+			return reflect.ValueOf(float64(num.Value)).Convert(typ), nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(typ), nil
+		}
+
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			slice := reflect.MakeSlice(typ, len(arr.Value), len(arr.Value))
+
+			for k, elem := range arr.Value {
+				val, err := fromObject(call, elem, typ.Elem())
+
+				if err != nil {
+					return val, fmt.Errorf("element %d: %s", k, err)
+				}
+
+				slice.Index(k).Set(val)
+			}
+
+			return slice, nil
+		}
+
+	case reflect.Array:
+		if arr, ok := obj.(*object.Array); ok && len(arr.Value) == typ.Len() {
+			array := reflect.New(typ).Elem()
+
+			for k, elem := range arr.Value {
+				val, err := fromObject(call, elem, typ.Elem())
+
+				if err != nil {
+					return val, fmt.Errorf("element %d: %s", k, err)
+				}
+
+				array.Index(k).Set(val)
+			}
+
+			return array, nil
+		}
+
+	case reflect.Map:
+		if hash, ok := obj.(*object.HashMap); ok {
+			hashMap := reflect.MakeMapWithSize(typ, len(hash.Value))
+
+			for key, elem := range hash.Value {
+				k, err := hashKeyValue(key, typ.Key())
+
+				if err != nil {
+					return k, err
+				}
+
+				val, err := fromObject(call, elem, typ.Elem())
+
+				if err != nil {
+					return val, fmt.Errorf("key %s: %s", key.Value, err)
+				}
+
+				hashMap.SetMapIndex(k, val)
+			}
+
+			return hashMap, nil
+		}
+
+	case reflect.Struct:
+		if hash, ok := obj.(*object.HashMap); ok {
+			return hashToStruct(call, hash, typ)
+		}
+
+	case reflect.Ptr:
+		if hash, ok := obj.(*object.HashMap); ok && typ.Elem().Kind() == reflect.Struct {
+			val, err := hashToStruct(call, hash, typ.Elem())
+
+			if err != nil {
+				return val, err
+			}
+
+			ptr := reflect.New(typ.Elem())
+			ptr.Elem().Set(val)
+			return ptr, nil
+		}
+
+	case reflect.Func:
+		if call == nil {
+			break
+		}
+
+		switch obj.(type) {
+		case *object.Function, *object.Method, eval.BuiltinFn:
+			return callbackFunc(call, obj, typ), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), typ)
+}
+
+// hashToStruct fills the exported fields of a new typ from the matching hashmap keys
+func hashToStruct(call *boundCall, hash *object.HashMap, typ reflect.Type) (reflect.Value, error) {
+	val := reflect.New(typ).Elem()
+
+	for key, elem := range hash.Value {
+		field, ok := typ.FieldByName(key.Value)
+
+		if !ok || field.PkgPath != "" {
+			return val, fmt.Errorf("%s has no field %s", typ, key.Value)
+		}
+
+		fieldVal, err := fromObject(call, elem, field.Type)
+
+		if err != nil {
+			return fieldVal, fmt.Errorf("field %s: %s", key.Value, err)
+		}
+
+		val.FieldByIndex(field.Index).Set(fieldVal)
+	}
+
+	return val, nil
+}
+
+func hashKeyValue(key object.Hash, typ reflect.Type) (reflect.Value, error) {
+	val := reflect.New(typ).Elem()
+
+	switch typ.Kind() {
+	case reflect.String:
+		val.SetString(key.Value)
+		return val, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, err := strconv.ParseInt(key.Value, 10, 64)
+
+		if err != nil || val.OverflowInt(num) {
+			return val, fmt.Errorf("cannot use key %s as %s", key.Value, typ)
+		}
+
+		val.SetInt(num)
+		return val, nil
+
+	case reflect.Interface:
+		return reflect.ValueOf(key.Value), nil
+
+	default:
+		return val, fmt.Errorf("unsupported map key type %s", typ)
+	}
+}
+
+// boundCall is a call in progress of a bound function, shared with the
+// callbacks made for its arguments. Go code may keep a callback or run it
+// on another goroutine, so its failures are recorded rather than raised
+type boundCall struct {
+	e       *eval.Evaluator
+	mu      sync.Mutex
+	done    bool
+	failure *object.Error
+}
+
+// fail records the first failure of a callback while the call runs. Once
+// the call has returned, there is nobody left to report it to and it is dropped
+func (call *boundCall) fail(failure *object.Error) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+
+	if !call.done && call.failure == nil {
+		call.failure = failure
+	}
+}
+
+func (call *boundCall) failed() bool {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+
+	return call.failure != nil
+}
+
+// finish ends the call and returns the failure recorded during it, if any
+func (call *boundCall) finish() *object.Error {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+
+	call.done = true
+	return call.failure
+}
+
+// callbackFunc turns a K function into a Go function of type typ,
+// so K code can pass callbacks to bound Go functions. An error of the
+// callback is returned when typ has a trailing error result. Otherwise the
+// callback returns zero values and its error fails the bound call, whose
+// following callbacks return zero values without running
+func callbackFunc(call *boundCall, fn object.Object, typ reflect.Type) reflect.Value {
+	returnsError := typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType
+
+	return reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		if !returnsError && call.failed() {
+			return zeroResults(typ)
+		}
+
+		args := []object.Object{}
+
+		for _, val := range in {
+			args = append(args, toObject(val))
+		}
+
+		result := call.e.Apply(fn, args)
+		failure, _ := result.(*object.Error)
+		out := []reflect.Value{}
+
+		for k := 0; k < typ.NumOut() && failure == nil; k++ {
+			outType := typ.Out(k)
+
+			if outType == errorType {
+				out = append(out, reflect.Zero(outType))
+				continue
+			}
+
+			val, err := fromObject(call, result, outType)
+
+			if err != nil {
+				failure = newError("callback result: %s", err)
+				break
+			}
+
+			out = append(out, val)
+		}
+
+		if failure == nil {
+			return out
+		}
+
+		out = zeroResults(typ)
+
+		if !returnsError {
+			call.fail(failure)
+			return out
+		}
+
+		out[len(out)-1] = reflect.ValueOf(fmt.Errorf("%s", failure.Message))
+		return out
+	})
+}
+
+// zeroResults returns the zero values of the results of the function type typ
+func zeroResults(typ reflect.Type) []reflect.Value {
+	out := []reflect.Value{}
+
+	for k := 0; k < typ.NumOut(); k++ {
+		out = append(out, reflect.Zero(typ.Out(k)))
+	}
+
+	return out
+}
+
+func funcName(fn reflect.Value) string {
+	if f := runtime.FuncForPC(fn.Pointer()); f != nil {
+		return f.Name()
+	}
+
+	return fn.Type().String()
+}
+
+func newError(format string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,276 @@
+package klang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+	Tags    []string
+	secret  string
+}
+
+func (a *account) Deposit(amount int) int {
+	a.Balance += amount
+	return a.Balance
+}
+
+func (a *account) Withdraw(amount int) (int, error) {
+	if amount > a.Balance {
+		return a.Balance, fmt.Errorf("insufficient funds")
+	}
+
+	a.Balance -= amount
+	return a.Balance, nil
+}
+
+func newTestInterpreter() *Interpreter {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	k.Bind("add", func(a, b int) int { return a + b })
+	k.Bind("small", func(n int8) int8 { return n })
+	k.Bind("natural", func(n uint) uint { return n })
+	k.Bind("sum", func(first int, rest ...int) int {
+		for _, n := range rest {
+			first += n
+		}
+		return first
+	})
+	k.Bind("divide", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	})
+	k.Bind("split", func(s string) (string, string) {
+		parts := strings.SplitN(s, ":", 2)
+		return parts[0], parts[1]
+	})
+	k.Bind("apply", func(f func(int) int, n int) int { return f(n) })
+	k.Bind("try", func(f func(int) (int, error), n int) (int, error) { return f(n) })
+	k.Bind("sortBy", func(xs []int, less func(a, b int) bool) []int {
+		sort.Slice(xs, func(i, j int) bool { return less(xs[i], xs[j]) })
+		return xs
+	})
+	k.Bind("account", &account{Owner: "k", Balance: 10, secret: "hidden"})
+
+	return k
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`add(1, 2)`, int64(3)},
+		{`small(127)`, int64(127)},
+		{`natural(7)`, int64(7)},
+		{`sum(1)`, int64(1)},
+		{`sum(1, 2, 3, 4)`, int64(10)},
+		{`sum(1, ...[2, 3])`, int64(6)},
+		{`divide(7, 2)`, int64(3)},
+		{`split("a:b")`, []interface{}{"a", "b"}},
+		{`apply(fn(n) { n * 2 }, 21)`, int64(42)},
+		{`apply(natural, 7)`, int64(7)},
+		{`try(fn(n) { n + 1 }, 1)`, int64(2)},
+		{`sortBy([3, 1, 2], fn(a, b) { a > b })`, []interface{}{int64(3), int64(2), int64(1)}},
+	}
+
+	for _, tt := range tests {
+		k := newTestInterpreter()
+		result, err := k.Run(tt.input)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: result is not matching expected. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(1)`, "add expects 2 arguments, got 1"},
+		{`add(1, 2, 3)`, "add expects 2 arguments, got 3"},
+		{`sum()`, "sum expects at least 1 arguments, got 0"},
+		{`add(1, "2")`, "add: argument 2: cannot use OBJECT_STRING as int"},
+		{`sum(1, 2, "3")`, "sum: argument 3: cannot use OBJECT_STRING as int"},
+		{`small(128)`, "small: argument 1: 128 overflows int8"},
+		{`small(-129)`, "small: argument 1: -129 overflows int8"},
+		{`natural(-1)`, "natural: argument 1: -1 overflows uint"},
+		{`divide(1, 0)`, "divide: division by zero"},
+		{`apply(fn(n) { n / 0 }, 1)`, "division by zero"},
+		{`apply(fn(n) { "one" }, 1)`, "callback result: cannot use OBJECT_STRING as int"},
+		{`apply(fn(a, b) { a }, 1)`, "wrong number of arguments: expected 2, got 1"},
+		{`try(fn(n) { n.missing() }, 1)`, "try: OBJECT_INTEGER has no method missing"},
+		{`try(fn(n) { "one" }, 1)`, "try: callback result: cannot use OBJECT_STRING as int"},
+		{`sortBy([3, 1, 2], fn(a, b) { a.len() })`, "OBJECT_INTEGER has no method len"},
+	}
+
+	for _, tt := range tests {
+		k := newTestInterpreter()
+		_, err := k.Run(tt.input)
+
+		var runtimeErr *RuntimeError
+
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a runtime error, got=%v", tt.input, err)
+			continue
+		}
+
+		if !strings.Contains(runtimeErr.Message, tt.expected) {
+			t.Errorf("%s: error is not matching expected. want=%q, got=%q", tt.input, tt.expected, runtimeErr.Message)
+		}
+	}
+}
+
+func TestBindFuncRejectsNonFunctions(t *testing.T) {
+	if _, err := BindFunc(42); err == nil {
+		t.Fatal("BindFunc(42) did not return an error")
+	}
+}
+
+func TestHostObject(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`account["Owner"]`, "k"},
+		{`account.Balance`, int64(10)},
+		{`account.Tags`, nil},
+		{`account["Deposit"](5)`, int64(15)},
+		{`account.Deposit(5); account.Balance`, int64(15)},
+		{`account.Withdraw(4)`, int64(6)},
+		{`account.Owner = "sobri"; account.Owner`, "sobri"},
+		{`account["Balance"] = 3; account.Deposit(1)`, int64(4)},
+		{`account.Tags = ["a", "b"]; account.Tags`, []interface{}{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		k := newTestInterpreter()
+		result, err := k.Run(tt.input)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: result is not matching expected. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestHostObjectErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`account.Missing`, "account has no field or method Missing"},
+		{`account.secret`, "account has no field or method secret"},
+		{`account[1]`, "host object index must be a string, got OBJECT_INTEGER"},
+		{`account.Withdraw(100)`, "account.Withdraw: insufficient funds"},
+		{`account.Deposit("5")`, "account.Deposit: argument 1: cannot use OBJECT_STRING as int"},
+		{`account.Missing = 1`, "account has no field Missing"},
+		{`account.secret = "x"`, "account has no field secret"},
+		{`account.Balance = "x"`, "account.Balance: cannot use OBJECT_STRING as int"},
+		{`account[1] = 1`, "host object index must be a string, got OBJECT_INTEGER"},
+	}
+
+	for _, tt := range tests {
+		k := newTestInterpreter()
+		_, err := k.Run(tt.input)
+
+		var runtimeErr *RuntimeError
+
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a runtime error, got=%v", tt.input, err)
+			continue
+		}
+
+		if !strings.Contains(runtimeErr.Message, tt.expected) {
+			t.Errorf("%s: error is not matching expected. want=%q, got=%q", tt.input, tt.expected, runtimeErr.Message)
+		}
+	}
+}
+
+func TestHostObjectSharesTheGoValue(t *testing.T) {
+	acct := &account{Balance: 1}
+	k := newTestInterpreter()
+
+	if err := k.Bind("mine", acct); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.Run(`mine.Deposit(2); mine.Owner = "k"`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if acct.Balance != 3 || acct.Owner != "k" {
+		t.Fatalf("bound value was not updated, got=%+v", acct)
+	}
+}
+
+func TestCallbackFailures(t *testing.T) {
+	k := newTestInterpreter()
+
+	var kept func(int) int
+	k.Bind("keep", func(f func(int) int) { kept = f })
+	k.Bind("times", func(n int, f func(int) int) {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+	})
+	k.Bind("elsewhere", func(f func(int) int, n int) int {
+		result := make(chan int)
+		go func() { result <- f(n) }()
+		return <-result
+	})
+
+	// the callbacks following a failure do not run
+	if _, err := k.Run(`let calls = []; times(3, fn(i) { calls.push(i); i / 0 })`); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("times: expected a division by zero, got=%v", err)
+	}
+
+	if result, err := k.Run(`calls`); err != nil || !reflect.DeepEqual(result, []interface{}{int64(0)}) {
+		t.Fatalf("times: expected a single call, got=%v %v", result, err)
+	}
+
+	if _, err := k.Run(`elsewhere(fn(n) { n / 0 }, 1)`); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("elsewhere: expected a division by zero, got=%v", err)
+	}
+
+	if _, err := k.Run(`keep(fn(n) { 10 / n })`); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := kept(2); result != 5 {
+		t.Fatalf("kept callback: expected 5, got=%d", result)
+	}
+
+	// once keep returned, a failure has nobody to be reported to
+	if result := kept(0); result != 0 {
+		t.Fatalf("kept callback: expected the zero value, got=%d", result)
+	}
+
+	done := make(chan int)
+	go func() { done <- kept(0) }()
+
+	if result := <-done; result != 0 {
+		t.Fatalf("kept callback on another goroutine: expected the zero value, got=%d", result)
+	}
+}
@@ -0,0 +1,189 @@
+package klang
+
+import (
+	"Klang/eval"
+	"Klang/object"
+	"fmt"
+	"reflect"
+)
+
+// ToObject converts a Go value into its K counterpart.
+//
+//	nil                    -> nil
+//	bool                   -> boolean
+//	int, int8 ... uint64   -> integer
+//	float32, float64       -> float
+//	string                 -> string
+//	[]interface{}          -> array
+//	map[string]interface{} -> hashmap
+//	Func                   -> builtin
+//
+// object.Object values are passed through untouched. Anything else is
+// converted by reflection: slices, arrays and maps become arrays and
+// hashmaps, structs become host objects and functions become builtins
+// through BindFunc
+func ToObject(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return eval.NILL, nil
+
+	case object.Object:
+		return value, nil
+
+	case bool:
+		if value {
+			return eval.TRUE, nil
+		}
+		return eval.FALSE, nil
+
+	case int:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case int8:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case int16:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case int32:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case int64:
+		return &object.Integer{Value: value}, nil
+
+	case uint:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case uint8:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case uint16:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case uint32:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case uint64:
+		return &object.Integer{Value: int64(value)}, nil
+
+	case float32:
+		return &object.Float{Value: float64(value)}, nil
+
+	case float64:
+		return &object.Float{Value: value}, nil
+
+	case string:
+		return &object.String{Value: value}, nil
+
+	case []interface{}:
+		elements := []object.Object{}
+
+		for _, elem := range value {
+			obj, err := ToObject(elem)
+
+			if err != nil {
+				return nil, err
+			}
+
+			elements = append(elements, obj)
+		}
+
+		return &object.Array{Value: elements}, nil
+
+	case map[string]interface{}:
+		hashMap := make(map[object.Hash]object.Object)
+
+		for key, val := range value {
+			obj, err := ToObject(val)
+
+			if err != nil {
+				return nil, err
+			}
+
+			hashMap[(&object.String{Value: key}).Hashkey()] = obj
+		}
+
+		return &object.HashMap{Value: hashMap}, nil
+
+	case Func:
+		return wrapFunc(value), nil
+
+	case func(args ...interface{}) (interface{}, error):
+		return wrapFunc(value), nil
+
+	default:
+		obj := toObject(reflect.ValueOf(value))
+
+		if err, ok := obj.(*object.Error); ok {
+			return nil, fmt.Errorf("%s", err.Message)
+		}
+
+		return obj, nil
+	}
+}
+
+// FromObject converts a K value into its Go counterpart, the reverse of ToObject.
+// Hashmap keys are converted to their string form. Values without a Go
+// counterpart, such as functions, are returned as the object itself. An
+// array or hashmap nested in itself gives a slice or map nested in itself
+func FromObject(obj object.Object) interface{} {
+	return fromObjectSeen(obj, make(map[object.Object]interface{}))
+}
+
+// fromObjectSeen converts obj, converted holding the arrays and hashmaps
+// converted so far so that a cycle is converted once
+func fromObjectSeen(obj object.Object, converted map[object.Object]interface{}) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Nill:
+		return nil
+
+	case *object.Boolean:
+		return obj.Value
+
+	case *object.Integer:
+		return obj.Value
+
+	case *object.Float:
+		return obj.Value
+
+	case *object.String:
+		return obj.Value
+
+	case *object.Array:
+		if value, ok := converted[obj]; ok {
+			return value
+		}
+
+		elements := make([]interface{}, len(obj.Value))
+		converted[obj] = elements
+
+		for i, elem := range obj.Value {
+			elements[i] = fromObjectSeen(elem, converted)
+		}
+
+		return elements
+
+	case *object.HashMap:
+		if value, ok := converted[obj]; ok {
+			return value
+		}
+
+		hashMap := make(map[string]interface{})
+		converted[obj] = hashMap
+
+		for key, val := range obj.Value {
+			hashMap[key.Value] = fromObjectSeen(val, converted)
+		}
+
+		return hashMap
+
+	case *object.Return:
+		return fromObjectSeen(obj.Value, converted)
+
+	case *HostObject:
+		return obj.Value.Interface()
+
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,79 @@
+package klang
+
+import (
+	"Klang/object"
+	"fmt"
+	"reflect"
+)
+
+// HostObject wraps a Go struct so K code can read and assign its exported
+// fields and call its methods:
+//
+//	user["Name"]
+//	user.Name = "k"
+//	user["Greet"]("hello")
+type HostObject struct {
+	Value reflect.Value // always a pointer to a struct
+}
+
+func (h *HostObject) Inspect() string {
+	return fmt.Sprintf("%+v", h.Value.Elem().Interface())
+}
+
+func (h *HostObject) Type() object.ObjectType {
+	return object.OBJECT_HOST
+}
+
+func (h *HostObject) Index(key object.Object) object.Object {
+	name, ok := key.(*object.String)
+
+	if !ok {
+		return newError("host object index must be a string, got %s", key.Type())
+	}
+
+	typeName := h.Value.Elem().Type().Name()
+
+	if method := h.Value.MethodByName(name.Value); method.IsValid() {
+		return bindFunc(method, typeName+"."+name.Value)
+	}
+
+	field, ok := h.Value.Elem().Type().FieldByName(name.Value)
+
+	if !ok || field.PkgPath != "" {
+		return newError("%s has no field or method %s", typeName, name.Value)
+	}
+
+	return toObject(h.Value.Elem().FieldByIndex(field.Index))
+}
+
+// SetIndex assigns value, converted to the type of the field, to the
+// exported field named by key
+func (h *HostObject) SetIndex(key, value object.Object) object.Object {
+	name, ok := key.(*object.String)
+
+	if !ok {
+		return newError("host object index must be a string, got %s", key.Type())
+	}
+
+	typeName := h.Value.Elem().Type().Name()
+	field, ok := h.Value.Elem().Type().FieldByName(name.Value)
+
+	if !ok || field.PkgPath != "" {
+		return newError("%s has no field %s", typeName, name.Value)
+	}
+
+	val, err := fromObject(nil, value, field.Type)
+
+	if err != nil {
+		return newError("%s.%s: %s", typeName, name.Value, err)
+	}
+
+	h.Value.Elem().FieldByIndex(field.Index).Set(val)
+	return nil
+}
+
+// compile time checks that host objects are indexable by the evaluator
+var (
+	_ object.Indexer       = (*HostObject)(nil)
+	_ object.IndexAssigner = (*HostObject)(nil)
+)
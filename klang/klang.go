@@ -0,0 +1,255 @@
+// Package klang embeds the K interpreter into Go programs.
+//
+//	k := klang.New(os.Stdout, os.Stderr)
+//	k.SetGlobal("name", "sobri")
+//	k.Run(`let greet = fn(who) { return "hello " + who; }`)
+//	greeting, err := k.Call("greet", "world")
+package klang
+
+import (
+	"Klang/eval"
+	"Klang/lexer"
+	"Klang/object"
+	"Klang/parser"
+	"Klang/resolve"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Func is a Go function callable from K code. Arguments and the result
+// are converted with FromObject and ToObject, a non nil error is raised
+// as a runtime error in the calling script
+type Func func(args ...interface{}) (interface{}, error)
+
+// Interpreter is a single K instance. Globals and registered builtins
+// are private to the instance, so many interpreters can live side by side
+type Interpreter struct {
+	evaluator *eval.Evaluator
+	env       *object.Environment
+}
+
+func New(stdout, stderr io.Writer) *Interpreter {
+	return &Interpreter{
+		evaluator: eval.New(stdout, stderr),
+		env:       newEnvironment(),
+	}
+}
+
+// NewSandbox returns an interpreter for untrusted scripts: builtins that
+// touch the file system, the environment or the process are not visible,
+// and every run is bounded by eval.SandboxLimits
+func NewSandbox(stdout, stderr io.Writer) *Interpreter {
+	return &Interpreter{
+		evaluator: eval.NewSandbox(stdout, stderr),
+		env:       newEnvironment(),
+	}
+}
+
+// newEnvironment returns the global scope of an interpreter, where a
+// declaration replaces a binding of the same name so that a script can be
+// run again, or declare a name set with SetGlobal
+func newEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+	env.Redeclarable = true
+	return env
+}
+
+// ParseError is returned by Run when the source is not valid K
+type ParseError struct {
+	Errors []*parser.Error
+}
+
+func (pe *ParseError) Error() string {
+	messages := []string{}
+
+	for _, err := range pe.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "parse error: " + strings.Join(messages, "; ")
+}
+
+// RuntimeError is returned when evaluation fails. When a limit or a call
+// to `exit` stopped the script, Err matches the eval.Err* value, so callers
+// can test it with errors.Is
+type RuntimeError struct {
+	Message string
+	Err     error
+	Trace   []string // the calls the error went through, innermost first
+}
+
+func (re *RuntimeError) Error() string {
+	return "runtime error: " + re.Message
+}
+
+func (re *RuntimeError) Unwrap() error {
+	return re.Err
+}
+
+// Run evaluates source in the global scope of the interpreter and returns
+// the value of the last statement. Globals persist across runs, a `let` of
+// one already bound replaces it, unless it is a constant
+func (i *Interpreter) Run(source string) (interface{}, error) {
+	return i.RunContext(context.Background(), source)
+}
+
+// RunContext is Run stopped with a runtime error as soon as ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, source string) (interface{}, error) {
+	l := lexer.New(source)
+	p := parser.New(l)
+	p.MaxNesting = i.evaluator.Limits.MaxNesting
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	// locate the bindings of identifiers, the evaluator then reads most of
+	// them by slot instead of looking their name up
+	resolve.Resolve(program, i.evaluator.BuiltinNames())
+
+	result, err := i.result(i.evaluator.EvalContext(ctx, program, i.env))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+// Call invokes the global function or builtin named fnName with args
+// converted by ToObject, and converts its result back to a Go value
+func (i *Interpreter) Call(fnName string, args ...interface{}) (interface{}, error) {
+	return i.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call stopped with a runtime error as soon as ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, fnName string, args ...interface{}) (interface{}, error) {
+	fn, ok := i.env.Lookup(fnName)
+
+	if !ok {
+		builtin, ok := i.evaluator.Builtin(fnName)
+
+		if !ok {
+			return nil, fmt.Errorf("undefined function: %s", fnName)
+		}
+
+		fn = builtin
+	}
+
+	objects := []object.Object{}
+
+	for _, arg := range args {
+		obj, err := ToObject(arg)
+
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, obj)
+	}
+
+	result, err := i.result(i.evaluator.ApplyContext(ctx, fn, objects))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+// SetGlobal binds value to name in the global scope
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+
+	if err != nil {
+		return err
+	}
+
+	return i.define(name, obj)
+}
+
+// define binds obj to name in the global scope, replacing the value of a
+// name already bound
+func (i *Interpreter) define(name string, obj object.Object) error {
+	if _, ok := i.env.Lookup(name); ok {
+		return i.env.Assign(name, obj)
+	}
+
+	return i.env.Declare(name, obj, nil)
+}
+
+// GetGlobal returns the value bound to name in the global scope
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	obj, ok := i.env.Lookup(name)
+
+	if !ok {
+		return nil, false
+	}
+
+	return FromObject(obj), true
+}
+
+// Register exposes fn to scripts of this interpreter as a builtin called name
+func (i *Interpreter) Register(name string, fn Func) {
+	i.evaluator.RegisterBuiltin(name, wrapFunc(fn))
+}
+
+// SetLimits bounds every following Run and Call
+func (i *Interpreter) SetLimits(limits eval.Limits) {
+	i.evaluator.Limits = limits
+}
+
+// Sandboxed reports whether the interpreter was created by NewSandbox
+func (i *Interpreter) Sandboxed() bool {
+	return i.evaluator.Sandboxed()
+}
+
+// Evaluator returns the evaluator backing the interpreter
+func (i *Interpreter) Evaluator() *eval.Evaluator {
+	return i.evaluator
+}
+
+// Environment returns the global scope of the interpreter
+func (i *Interpreter) Environment() *object.Environment {
+	return i.env
+}
+
+func (i *Interpreter) result(obj object.Object) (object.Object, error) {
+	switch obj := obj.(type) {
+	case *object.Error:
+		return nil, &RuntimeError{Message: obj.Message, Err: obj.Err, Trace: obj.Trace}
+
+	case *object.Return:
+		return obj.Value, nil
+
+	default:
+		return obj, nil
+	}
+}
+
+func wrapFunc(fn Func) eval.BuiltinFn {
+	return func(e *eval.Evaluator, args ...object.Object) object.Object {
+		values := []interface{}{}
+
+		for _, arg := range args {
+			values = append(values, FromObject(arg))
+		}
+
+		result, err := fn(values...)
+
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		obj, err := ToObject(result)
+
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+
+		return obj
+	}
+}
@@ -0,0 +1,312 @@
+package klang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`1 + 2`, int64(3)},
+		{`1.5 * 2`, 3.0},
+		{`"a" + "b"`, "ab"},
+		{`!true`, false},
+		{`let x = 1`, nil},
+		{`[1, "a", [true]]`, []interface{}{int64(1), "a", []interface{}{true}}},
+		{`{"a": {"b": [1, 2]}, 3: nil}`, map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{int64(1), int64(2)}}, "3": nil}},
+		{`let f = fn() { return 5; }; f()`, int64(5)},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		result, err := New(&out, &out).Run(tt.input)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s: result is not matching expected. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRunKeepsGlobals(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	if _, err := k.Run(`let count = 1; let inc = fn() { count = count + 1 }`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := k.Run(`inc(); inc()`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if count, ok := k.GetGlobal("count"); !ok || count != int64(3) {
+		t.Fatalf("count is not matching expected. want=3, got=%v", count)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	_, err := k.Run(`let = 1`)
+	var parseErr *ParseError
+
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Fatalf("expected a parse error, got=%v", err)
+	}
+
+	_, err = k.Run(`let f = fn(x) { x + "a" }; f(1)`)
+	var runtimeErr *RuntimeError
+
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a runtime error, got=%v", err)
+	}
+
+	if !strings.Contains(runtimeErr.Message, "type mismatch") {
+		t.Errorf("error is not matching expected, got=%q", runtimeErr.Message)
+	}
+
+	if len(runtimeErr.Trace) == 0 || !strings.Contains(runtimeErr.Trace[0], "f") {
+		t.Errorf("trace does not name the failing function, got=%q", runtimeErr.Trace)
+	}
+}
+
+func TestCall(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	_, err := k.Run(`
+		let greet = fn(who) { return "hello " + who; };
+		let total = fn(xs) { let sum = 0; let i = 0; while i < len(xs) { sum = sum + xs[i]; i = i + 1 } sum };
+		let keys = fn(h) { h.keys() };
+		let fail = fn() { 1 / 0 };
+	`)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		fn       string
+		args     []interface{}
+		expected interface{}
+	}{
+		{"greet", []interface{}{"world"}, "hello world"},
+		{"total", []interface{}{[]interface{}{1, 2, int64(3)}}, int64(6)},
+		{"total", []interface{}{[]int{4, 5}}, int64(9)},
+		{"keys", []interface{}{map[string]interface{}{"a": 1}}, []interface{}{"a"}},
+		{"len", []interface{}{[]interface{}{"a", "b"}}, int64(2)},
+	}
+
+	for _, tt := range tests {
+		result, err := k.Call(tt.fn, tt.args...)
+
+		if err != nil {
+			t.Errorf("%s%v: unexpected error: %s", tt.fn, tt.args, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s%v: result is not matching expected. want=%#v, got=%#v", tt.fn, tt.args, tt.expected, result)
+		}
+	}
+
+	if _, err := k.Call("missing"); err == nil || err.Error() != "undefined function: missing" {
+		t.Errorf("calling an undefined function: got=%v", err)
+	}
+
+	var runtimeErr *RuntimeError
+
+	if _, err := k.Call("fail"); !errors.As(err, &runtimeErr) {
+		t.Errorf("calling a failing function: expected a runtime error, got=%v", err)
+	}
+
+	if _, err := k.Call("greet", make(chan int)); err == nil {
+		t.Errorf("calling with an unconvertible argument did not return an error")
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	config := map[string]interface{}{
+		"name":  "k",
+		"ports": []interface{}{int64(80), int64(443)},
+		"tls":   map[string]interface{}{"on": true},
+	}
+
+	if err := k.SetGlobal("config", config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := k.Run(`config.ports[1] + config.name.len()`)
+
+	if err != nil || result != int64(444) {
+		t.Fatalf("reading a global set from Go: want=444, got=%v (%v)", result, err)
+	}
+
+	if got, ok := k.GetGlobal("config"); !ok || !reflect.DeepEqual(got, config) {
+		t.Fatalf("GetGlobal is not matching SetGlobal. want=%#v, got=%#v", config, got)
+	}
+
+	// setting a name again replaces its value
+	if err := k.SetGlobal("config", 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, _ := k.GetGlobal("config"); got != int64(1) {
+		t.Fatalf("SetGlobal did not replace the value, got=%v", got)
+	}
+
+	if _, ok := k.GetGlobal("missing"); ok {
+		t.Fatal("GetGlobal found an undefined name")
+	}
+
+	if err := k.SetGlobal("ch", make(chan int)); err == nil {
+		t.Fatal("SetGlobal accepted an unconvertible value")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+
+	k.Register("join", func(args ...interface{}) (interface{}, error) {
+		parts := []string{}
+
+		for _, arg := range args {
+			s, ok := arg.(string)
+
+			if !ok {
+				return nil, fmt.Errorf("join: %v is not a string", arg)
+			}
+
+			parts = append(parts, s)
+		}
+
+		return strings.Join(parts, ","), nil
+	})
+
+	result, err := k.Run(`join("a", "b", "c")`)
+
+	if err != nil || result != "a,b,c" {
+		t.Fatalf("calling a registered function: want=%q, got=%v (%v)", "a,b,c", result, err)
+	}
+
+	if result, err := k.Call("join", "x", "y"); err != nil || result != "x,y" {
+		t.Fatalf("calling a registered function from Go: want=%q, got=%v (%v)", "x,y", result, err)
+	}
+
+	_, err = k.Run(`join("a", 1)`)
+	var runtimeErr *RuntimeError
+
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "join: 1 is not a string" {
+		t.Fatalf("error of a registered function is not raised, got=%v", err)
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	tests := []interface{}{
+		nil,
+		true,
+		int64(-7),
+		2.5,
+		"text",
+		[]interface{}{},
+		map[string]interface{}{},
+		[]interface{}{int64(1), []interface{}{"a", []interface{}{nil, false}}},
+		map[string]interface{}{
+			"list": []interface{}{map[string]interface{}{"x": int64(1)}, 1.5},
+			"map":  map[string]interface{}{"deep": map[string]interface{}{"s": "v"}},
+		},
+	}
+
+	for _, value := range tests {
+		obj, err := ToObject(value)
+
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned an error: %s", value, err)
+			continue
+		}
+
+		if got := FromObject(obj); !reflect.DeepEqual(got, value) {
+			t.Errorf("round trip is not matching. want=%#v, got=%#v", value, got)
+		}
+	}
+}
+
+func TestToObjectByReflection(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{[]string{"a", "b"}, []interface{}{"a", "b"}},
+		{[2]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{map[string][]int{"a": {1}}, map[string]interface{}{"a": []interface{}{int64(1)}}},
+		{map[int]bool{1: true}, map[string]interface{}{"1": true}},
+		{uint8(255), int64(255)},
+		{float32(0.5), 0.5},
+		{[]int(nil), nil},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.value)
+
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned an error: %s", tt.value, err)
+			continue
+		}
+
+		if got := FromObject(obj); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ToObject(%#v) is not matching expected. want=%#v, got=%#v", tt.value, tt.expected, got)
+		}
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Error("ToObject accepted a channel")
+	}
+}
+
+func TestRunRedeclaresGlobals(t *testing.T) {
+	var out bytes.Buffer
+	k := New(&out, &out)
+	script := `let x = 1; let double = fn(n) { n * 2 }; double(x)`
+
+	for run := 1; run <= 2; run++ {
+		if result, err := k.Run(script); err != nil || result != int64(2) {
+			t.Fatalf("run %d: want=2, got=%v (%v)", run, result, err)
+		}
+	}
+
+	if err := k.SetGlobal("limit", 10); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result, err := k.Run(`let limit = limit + 1; limit`); err != nil || result != int64(11) {
+		t.Fatalf("declaring a global set from Go: want=11, got=%v (%v)", result, err)
+	}
+
+	if _, err := k.Run(`const max = 1`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err := k.Run(`let max = 2`)
+	var runtimeErr *RuntimeError
+
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "cannot redeclare constant max" {
+		t.Fatalf("redeclaring a constant: got=%v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package klang
+
+import (
+	"Klang/eval"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSandbox() (*Interpreter, *bytes.Buffer) {
+	var out bytes.Buffer
+	return NewSandbox(&out, &out), &out
+}
+
+func TestSandboxHidesSystemBuiltins(t *testing.T) {
+	tests := []string{
+		`readFile("/etc/passwd")`,
+		`writeFile("/tmp/klang-sandbox", "pwned")`,
+		`getenv("HOME")`,
+		`exit(1)`,
+		`args()`,
+	}
+
+	for _, input := range tests {
+		k, _ := newTestSandbox()
+		_, err := k.Run(input)
+
+		var runtimeErr *RuntimeError
+
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("%s: expected a runtime error, got=%v", input, err)
+		}
+	}
+}
+
+func TestSandboxKeepsCoreBuiltins(t *testing.T) {
+	k, out := newTestSandbox()
+
+	if _, err := k.Run(`print("hello", len([1, 2]))`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out.String() != "hello 2\n" {
+		t.Fatalf("output is not matching expected. want=%q, got=%q", "hello 2\n", out.String())
+	}
+}
+
+func TestSandboxEnforcesLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   eval.Limits
+		expected error
+	}{
+		{`while true { 1 }`, eval.Limits{MaxSteps: 1000}, eval.ErrStepLimit},
+		{`while true { 1 }`, eval.Limits{Timeout: 10 * time.Millisecond}, eval.ErrTimeout},
+		{`let f = fn(n) { return 1 + f(n + 1) }; f(0)`, eval.Limits{MaxDepth: 100}, eval.ErrRecursionLimit},
+		// tail calls do not nest, a runaway one is stopped by the other limits
+		{`let f = fn(n) { return f(n + 1) }; f(0)`, eval.Limits{MaxDepth: 100, MaxSteps: 100000}, eval.ErrStepLimit},
+		{`let a = [1, 2, 3, 4]; while true { a = [a, a, a, a] }`, eval.Limits{MaxMemory: 1 << 16}, eval.ErrMemoryLimit},
+		{`let s = "ab"; while true { s = s + s }`, eval.Limits{MaxMemory: 1 << 16}, eval.ErrMemoryLimit},
+		{`let s = "ab"; while true { s = s + s }`, eval.SandboxLimits, eval.ErrMemoryLimit},
+		// printing a value whose elements are shared expands it, the text is
+		// charged as it is built
+		{doubled(23) + `print(a)`, eval.SandboxLimits, eval.ErrStepLimit},
+		{doubled(30) + `print(a)`, eval.Limits{MaxMemory: 1 << 16}, eval.ErrMemoryLimit},
+		{doubled(30) + `print(a)`, eval.Limits{Timeout: 10 * time.Millisecond}, eval.ErrTimeout},
+		{doubled(30) + `printf("%v", a)`, eval.Limits{MaxSteps: 100000}, eval.ErrStepLimit},
+		{doubled(30) + `match a { 1 => 1 }`, eval.Limits{MaxMemory: 1 << 16}, eval.ErrMemoryLimit},
+		// string methods charge their result before building it
+		{long(15) + `s.replace("", s)`, eval.Limits{MaxMemory: 1 << 20}, eval.ErrMemoryLimit},
+		{long(15) + `s.replace("a", s)`, eval.Limits{MaxMemory: 1 << 20}, eval.ErrMemoryLimit},
+		{long(15) + `let parts = s.split(""); parts.join(s)`, eval.Limits{MaxMemory: 1 << 22}, eval.ErrMemoryLimit},
+		{long(15) + `[s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s].join(s)`, eval.Limits{MaxMemory: 1 << 20}, eval.ErrMemoryLimit},
+		{long(15) + `s.split("")`, eval.Limits{MaxMemory: 1 << 20}, eval.ErrMemoryLimit},
+	}
+
+	for _, test := range tests {
+		k, _ := newTestSandbox()
+		k.SetLimits(test.limits)
+
+		_, err := k.Run(test.input)
+
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("%s: error is not matching expected. want=%v, got=%v", test.input, test.expected, err)
+		}
+	}
+}
+
+// doubled is a script binding a to an array of 2^n ones made of n arrays,
+// each holding the previous one twice
+func doubled(n int) string {
+	return fmt.Sprintf("let a = [1]; let i = 0; while i < %d { a = [a, a]; i = i + 1 } ", n)
+}
+
+// long is a script binding s to a string of 2^(n+1) bytes
+func long(n int) string {
+	return fmt.Sprintf("let s = \"ab\"; let i = 0; while i < %d { s = s + s; i = i + 1 } ", n)
+}
+
+func TestSandboxReturnsSharedValues(t *testing.T) {
+	k, _ := newTestSandbox()
+	result, err := k.Run(doubled(30) + `a`)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// every array is converted once, the halves are the same slice
+	halves := result.([]interface{})
+
+	if &halves[0].([]interface{})[0] != &halves[1].([]interface{})[0] {
+		t.Fatal("shared arrays were converted to distinct slices")
+	}
+}
+
+func TestSandboxDefaultLimits(t *testing.T) {
+	k, _ := newTestSandbox()
+	limits := k.Evaluator().Limits
+
+	if limits.MaxSteps == 0 || limits.MaxDepth == 0 || limits.MaxMemory == 0 || limits.MaxNesting == 0 || limits.Timeout == 0 {
+		t.Fatalf("sandbox must start with every limit enabled, got=%+v", limits)
+	}
+}
+
+func TestSandboxIsolatesInstances(t *testing.T) {
+	host := New(&bytes.Buffer{}, &bytes.Buffer{})
+	host.Register("secret", func(args ...interface{}) (interface{}, error) {
+		return "leaked", nil
+	})
+	host.SetGlobal("token", "leaked")
+
+	k, _ := newTestSandbox()
+
+	for _, input := range []string{`secret()`, `token`} {
+		result, err := k.Run(input)
+
+		if result == "leaked" {
+			t.Fatalf("%s: sandbox can see state of another interpreter", input)
+		}
+
+		if input == `secret()` && err == nil {
+			t.Fatalf("%s: expected a runtime error", input)
+		}
+	}
+
+	// registering on the sandbox must not change the shared defaults either
+	k.Register("print", func(args ...interface{}) (interface{}, error) { return nil, nil })
+	other, out := newTestSandbox()
+	other.Run(`print("still here")`)
+
+	if out.String() != "still here\n" {
+		t.Fatalf("builtins leaked between sandboxes, got=%q", out.String())
+	}
+}
+
+func TestSandboxSurvivesHostileInput(t *testing.T) {
+	tests := []string{
+		`1 + "a"`,
+		`{[1]: 2}`,
+		`"unterminated`,
+		`[1]["a"]`,
+		`-"a"`,
+		`(-) = 2`,
+		`a[!] = 2`,
+		`a[0+] = 2`,
+		`let [a, {b}!] = [1]`,
+	}
+
+	for _, input := range tests {
+		k, _ := newTestSandbox()
+
+		if _, err := k.Run(input); err == nil {
+			t.Fatalf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestSandboxCyclicValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1]; a[0] = a; print(a)`, "[[...]]\n"},
+		{`struct N { next } let a = N(1); a.next = a; print(a == N(a))`, "true\n"},
+	}
+
+	for _, test := range tests {
+		k, out := newTestSandbox()
+
+		if _, err := k.Run(test.input); err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.input, err)
+		}
+
+		if out.String() != test.expected {
+			t.Fatalf("%s: output is not matching expected. want=%q, got=%q", test.input, test.expected, out.String())
+		}
+	}
+}
+
+func TestSandboxReturnsCyclicValues(t *testing.T) {
+	k, _ := newTestSandbox()
+	result, err := k.Run(`let a = [1]; a[0] = a; a`)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	outer, ok := result.([]interface{})
+
+	if !ok || len(outer) != 1 {
+		t.Fatalf("expected a slice of one element, got=%#v", result)
+	}
+
+	if inner, ok := outer[0].([]interface{}); !ok || &inner[0] != &outer[0] {
+		t.Fatalf("expected the slice to hold itself, got=%T", outer[0])
+	}
+}
+
+func TestSandboxLimitsNesting(t *testing.T) {
+	deep := 6 << 20
+
+	tests := []string{
+		strings.Repeat("[", deep),
+		strings.Repeat("(", deep) + "1" + strings.Repeat(")", deep),
+		strings.Repeat("-", deep) + "1",
+		strings.Repeat("if true { ", deep),
+		strings.Repeat("fn f() { ", deep),
+		"let " + strings.Repeat("[", deep),
+		"let x: " + strings.Repeat("[", deep),
+		strings.Repeat("x = ", deep) + "1",
+	}
+
+	for _, input := range tests {
+		k, _ := newTestSandbox()
+		_, err := k.Run(input)
+
+		var parseErr *ParseError
+
+		if !errors.As(err, &parseErr) || len(parseErr.Errors) != 1 || !strings.Contains(parseErr.Errors[0].Message, "nesting too deep") {
+			t.Fatalf("%.20s...: expected a single nesting error, got=%v", input, err)
+		}
+	}
+
+	k, _ := newTestSandbox()
+
+	if _, err := k.Run(strings.Repeat("[", 100) + strings.Repeat("]", 100)); err != nil {
+		t.Fatalf("unexpected error for a shallow nesting: %s", err)
+	}
+
+	// an assignment missing its value used to crash the parser
+	var parseErr *ParseError
+
+	if _, err := k.Run(`x = ) = 1`); !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error, got=%v", err)
+	}
+}
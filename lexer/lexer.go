@@ -9,6 +9,11 @@ type Lexer struct {
 	currentChar     byte
 	readPosition    int
 	currentPosition int
+	line            int // position of currentChar
+	column          int
+	tokenLine       int // position of the first character of the token being read
+	tokenColumn     int
+	comments        []token.Token
 }
 
 func New(source string) *Lexer {
@@ -17,6 +22,8 @@ func New(source string) *Lexer {
 		currentPosition: 0,
 		readPosition:    0,
 		currentChar:     0,
+		line:            1,
+		column:          0,
 	}
 
 	lex.ReadChar()
@@ -25,6 +32,13 @@ func New(source string) *Lexer {
 }
 
 func (l *Lexer) ReadChar() {
+	if l.currentChar == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
 	if l.readPosition >= len(l.source) {
 		l.currentChar = 0
 	} else {
@@ -37,18 +51,33 @@ func (l *Lexer) ReadChar() {
 
 func (l *Lexer) NextToken() token.Token {
 	if l.readPosition > len(l.source) {
+		l.tokenLine = l.line
+		l.tokenColumn = l.column
 		return l.makeToken(token.EOF, "EOF")
 	}
 
 	var tok token.Token
 	l.skipWhitespaceChar()
 
+	for l.CurrentChar() == '/' && l.isPeekChar('/') {
+		l.readComment()
+		l.skipWhitespaceChar()
+	}
+
+	l.tokenLine = l.line
+	l.tokenColumn = l.column
+
 	switch l.CurrentChar() {
 	case '+':
 		tok = l.makeToken(token.PLUS, string(l.CurrentChar()))
 
 	case '-':
-		tok = l.makeToken(token.MINUS, string(l.CurrentChar()))
+		if l.isPeekChar('>') {
+			l.ReadChar()
+			tok = l.makeToken(token.ARROW, string(l.source[l.currentPosition-1:l.readPosition]))
+		} else {
+			tok = l.makeToken(token.MINUS, string(l.CurrentChar()))
+		}
 
 	case '/':
 		tok = l.makeToken(token.SLASH, string(l.CurrentChar()))
@@ -75,9 +104,15 @@ func (l *Lexer) NextToken() token.Token {
 		l.ReadChar()
 		pos := l.currentPosition
 
-		for l.CurrentChar() != '"' {
+		for l.CurrentChar() != '"' && l.CurrentChar() != 0 {
 			l.ReadChar()
 		}
+
+		// unterminated string, the source ended before the closing `"`
+		if l.CurrentChar() == 0 {
+			return l.makeToken(token.ILLEGAL, l.source[pos-1:l.currentPosition])
+		}
+
 		tok = l.makeToken(token.STRING, l.source[pos:l.currentPosition])
 
 	case '!':
@@ -92,6 +127,9 @@ func (l *Lexer) NextToken() token.Token {
 		if l.isPeekChar('=') {
 			l.ReadChar()
 			tok = l.makeToken(token.EQUAL, string(l.source[l.currentPosition-1:l.readPosition]))
+		} else if l.isPeekChar('>') {
+			l.ReadChar()
+			tok = l.makeToken(token.FAT_ARROW, string(l.source[l.currentPosition-1:l.readPosition]))
 		} else {
 			tok = l.makeToken(token.ASSIGN, string(l.CurrentChar()))
 		}
@@ -112,9 +150,21 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.makeToken(token.LESSER, string(l.CurrentChar()))
 		}
 
+	case '.':
+		if l.isPeekChar('.') && l.readPosition+1 < len(l.source) && l.source[l.readPosition+1] == '.' {
+			l.ReadChar()
+			l.ReadChar()
+			tok = l.makeToken(token.ELLIPSIS, string(l.source[l.currentPosition-2:l.readPosition]))
+		} else {
+			tok = l.makeToken(token.DOT, string(l.CurrentChar()))
+		}
+
 	case ':':
 		tok = l.makeToken(token.COLON, string(l.CurrentChar()))
 
+	case '|':
+		tok = l.makeToken(token.PIPE, string(l.CurrentChar()))
+
 	case ';':
 		tok = l.makeToken(token.SEMICOLON, string(l.CurrentChar()))
 
@@ -198,8 +248,26 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// readComment consumes a `//` comment, leaving the lexer at the end of the line
+func (l *Lexer) readComment() {
+	l.tokenLine = l.line
+	l.tokenColumn = l.column
+	pos := l.currentPosition
+
+	for l.CurrentChar() != '\n' && l.CurrentChar() != 0 {
+		l.ReadChar()
+	}
+
+	l.comments = append(l.comments, l.makeToken(token.COMMENT, l.source[pos:l.currentPosition]))
+}
+
 func (l *Lexer) makeToken(tokenType token.TokenType, literal string) token.Token {
-	return token.Token{Type: tokenType, Literal: literal}
+	return token.Token{Type: tokenType, Literal: literal, Line: l.tokenLine, Column: l.tokenColumn}
 }
 
 func (l *Lexer) isPeekChar(char byte) bool {
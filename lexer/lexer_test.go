@@ -26,6 +26,11 @@ func TestNextToken(t *testing.T) {
     >=
     <
     <=
+    ->
+    =>
+    |
+    ...
+    .
     :
     ;
     55
@@ -39,6 +44,8 @@ func TestNextToken(t *testing.T) {
     false
     if
     else
+    match
+    struct
     "foobar"
 
     let five = 5;
@@ -74,6 +81,11 @@ func TestNextToken(t *testing.T) {
 		{token.GREATER_EQUAL, ">="},
 		{token.LESSER, "<"},
 		{token.LESSER_EQUAL, "<="},
+		{token.ARROW, "->"},
+		{token.FAT_ARROW, "=>"},
+		{token.PIPE, "|"},
+		{token.ELLIPSIS, "..."},
+		{token.DOT, "."},
 		{token.COLON, ":"},
 		{token.SEMICOLON, ";"},
 		{token.INTEGER, "55"},
@@ -87,6 +99,8 @@ func TestNextToken(t *testing.T) {
 		{token.FALSE, "false"},
 		{token.IF, "if"},
 		{token.ELSE, "else"},
+		{token.MATCH, "match"},
+		{token.STRUCT, "struct"},
 		{token.STRING, "foobar"},
 
 		{token.LET, "let"},
@@ -132,3 +146,72 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 1;\n  foo(\"a\nb\") >= 2"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"1", 1, 9},
+		{";", 1, 10},
+		{"foo", 2, 3},
+		{"(", 2, 6},
+		{"a\nb", 2, 7},
+		{")", 3, 3},
+		{">=", 3, 5},
+		{"2", 3, 8},
+		{"EOF", 3, 9},
+	}
+
+	l := New(input)
+
+	for _, test := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != test.expectedLiteral {
+			t.Fatalf("Token literal is not matching expected. want=`%s`, got=`%s`", test.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != test.expectedLine || tok.Column != test.expectedColumn {
+			t.Fatalf("Token `%s` position is not matching expected. want=%d:%d, got=%d:%d",
+				tok.Literal, test.expectedLine, test.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1; // trailing
+x / 2 // last`
+
+	expected := []token.TokenType{
+		token.LET, token.IDENTIFIER, token.ASSIGN, token.INTEGER, token.SEMICOLON,
+		token.IDENTIFIER, token.SLASH, token.INTEGER, token.EOF,
+	}
+
+	l := New(input)
+
+	for _, tokType := range expected {
+		if tok := l.NextToken(); tok.Type != tokType {
+			t.Fatalf("Token type is not matching expected. want=`%q`, got=`%q`", tokType, tok.Type)
+		}
+	}
+
+	comments := []string{"// leading", "// trailing", "// last"}
+
+	if len(l.Comments()) != len(comments) {
+		t.Fatalf("Comments count is not matching expected. want=%d, got=%d", len(comments), len(l.Comments()))
+	}
+
+	for i, comment := range l.Comments() {
+		if comment.Literal != comments[i] {
+			t.Fatalf("Comment is not matching expected. want=`%s`, got=`%s`", comments[i], comment.Literal)
+		}
+	}
+}
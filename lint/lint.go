@@ -0,0 +1,243 @@
+// Package lint checks K programs for code that parses and runs but is most
+// likely wrong. Every check is a named rule that can be turned on or off;
+// the diagnostics of the resolve package are exposed as rules as well.
+package lint
+
+import (
+	"Klang/ast"
+	"Klang/resolve"
+	"Klang/token"
+	"fmt"
+	"sort"
+)
+
+// Rule is a single check, identified by its Name on the command line
+type Rule struct {
+	Name     string
+	Doc      string
+	Severity resolve.Severity
+	check    func(l *linter, node ast.Node) // nil for the rules of the resolver
+}
+
+// Issue is a problem found by a rule at Line and Column
+type Issue struct {
+	Line     int              `json:"line"`
+	Column   int              `json:"column"`
+	Rule     string           `json:"rule"`
+	Severity resolve.Severity `json:"severity"`
+	Message  string           `json:"message"`
+}
+
+func (i *Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", i.Line, i.Column, i.Message, i.Rule)
+}
+
+// Rules lists every rule, all of them enabled by default
+var Rules = []*Rule{
+	{Name: resolve.CodeUndefined, Doc: "use of a name that is never declared", Severity: resolve.Error},
+	{Name: resolve.CodeUnused, Doc: "binding or parameter whose value is never read", Severity: resolve.Warning},
+	{Name: resolve.CodeShadow, Doc: "declaration hiding a name of an enclosing scope", Severity: resolve.Warning},
+	{Name: resolve.CodeUnreachable, Doc: "statement following a return", Severity: resolve.Warning},
+	{Name: "assign-in-condition", Doc: "assignment used as the condition of if or while", Severity: resolve.Warning, check: checkAssignInCondition},
+	{Name: "constant-condition", Doc: "while condition that never changes", Severity: resolve.Warning, check: checkConstantCondition},
+	{Name: "literal-comparison", Doc: "comparison of literals of different types", Severity: resolve.Warning, check: checkLiteralComparison},
+	{Name: "duplicate-key", Doc: "key written twice in a hashmap literal", Severity: resolve.Warning, check: checkDuplicateKey},
+	{Name: "call-non-function", Doc: "call of a value that is not a function", Severity: resolve.Error, check: checkCallNonFunction},
+	{Name: "arity", Doc: "call of a function literal with the wrong number of arguments", Severity: resolve.Error, check: checkArity},
+	{Name: "builtin-misuse", Doc: "builtin called with the wrong number or type of arguments", Severity: resolve.Error, check: checkBuiltinMisuse},
+}
+
+// Lookup returns the rule called name, nil when there is none
+func Lookup(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// Select returns the rules to run: enable restricts them to the listed
+// names, disable removes names from the selection
+func Select(enable, disable []string) ([]*Rule, error) {
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown rule %s", name)
+		}
+	}
+
+	selected := []*Rule{}
+
+	for _, rule := range Rules {
+		if len(enable) > 0 && !contains(enable, rule.Name) {
+			continue
+		}
+
+		if contains(disable, rule.Name) {
+			continue
+		}
+
+		selected = append(selected, rule)
+	}
+
+	return selected, nil
+}
+
+// Run checks program with rules, predeclared are the names provided by the
+// host such as the builtins of the evaluator. Issues are sorted by position
+func Run(program *ast.Program, predeclared []string, rules []*Rule) []*Issue {
+	l := &linter{
+		info:     resolve.Resolve(program, predeclared),
+		assigned: make(map[*resolve.Symbol]bool),
+		issues:   []*Issue{},
+	}
+
+	for _, diag := range l.info.Diagnostics {
+		if rule := find(rules, diag.Code); rule != nil {
+			l.issues = append(l.issues, &Issue{
+				Line:     diag.Line,
+				Column:   diag.Column,
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Message:  diag.Message,
+			})
+		}
+	}
+
+	// symbols reassigned somewhere cannot be trusted to hold their initial value
+	ast.Inspect(program, func(node ast.Node) bool {
+		if assign, ok := node.(*ast.AssignmentExpression); ok {
+			if sym, ok := l.info.Symbols[assign.Ident]; ok {
+				l.assigned[sym] = true
+			}
+		}
+
+		return true
+	})
+
+	for _, rule := range rules {
+		if rule.check == nil {
+			continue
+		}
+
+		l.rule = rule
+
+		ast.Inspect(program, func(node ast.Node) bool {
+			rule.check(l, node)
+			return true
+		})
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+
+		return l.issues[i].Column < l.issues[j].Column
+	})
+
+	return l.issues
+}
+
+type linter struct {
+	info     *resolve.Info
+	assigned map[*resolve.Symbol]bool
+	rule     *Rule // rule being run
+	issues   []*Issue
+}
+
+func (l *linter) report(tok token.Token, format string, args ...interface{}) {
+	l.issues = append(l.issues, &Issue{
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Rule:     l.rule.Name,
+		Severity: l.rule.Severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// function returns the function literal an identifier is known to hold:
+// the value of a `let` or the function of a `fn` that is never reassigned
+func (l *linter) function(expr ast.Expression) *ast.FunctionLiteralExpression {
+	ident, ok := expr.(*ast.Identifier)
+
+	if !ok {
+		fn, _ := expr.(*ast.FunctionLiteralExpression)
+		return fn
+	}
+
+	sym, ok := l.info.Symbols[ident]
+
+	if !ok || !l.fixed(sym) {
+		return nil
+	}
+
+	fn, _ := sym.Value.(*ast.FunctionLiteralExpression)
+	return fn
+}
+
+// value returns the expression an identifier is known to hold, the
+// expression itself for anything else
+func (l *linter) value(expr ast.Expression) ast.Expression {
+	ident, ok := expr.(*ast.Identifier)
+
+	if !ok {
+		return expr
+	}
+
+	sym, ok := l.info.Symbols[ident]
+
+	if !ok || !l.fixed(sym) {
+		return nil
+	}
+
+	return sym.Value
+}
+
+// fixed reports whether sym keeps the value it is declared with
+func (l *linter) fixed(sym *resolve.Symbol) bool {
+	switch sym.Kind {
+	case resolve.Constant:
+		return true
+	case resolve.Variable, resolve.Function:
+		return !l.assigned[sym]
+	default:
+		return false
+	}
+}
+
+// builtin returns the name of the builtin expr refers to, "" when it is not one
+func (l *linter) builtin(expr ast.Expression) string {
+	ident, ok := expr.(*ast.Identifier)
+
+	if !ok {
+		return ""
+	}
+
+	if sym, ok := l.info.Symbols[ident]; ok && sym.Kind == resolve.Builtin {
+		return sym.Name
+	}
+
+	return ""
+}
+
+func find(rules []*Rule, name string) *Rule {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package lint
+
+import (
+	"Klang/lexer"
+	"Klang/parser"
+	"testing"
+)
+
+func runLint(t *testing.T, input string, rules []*Rule) []*Issue {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse error: %s", p.Errors()[0])
+	}
+
+	return Run(program, []string{"print", "len", "printf", "exit"}, rules)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		input    string
+		expected []string
+	}{
+		{"assign-in-condition", "let x = 1; if x = 2 { x }", []string{"1:15: assignment to x used as a condition, did you mean ==? (assign-in-condition)"}},
+		{"assign-in-condition", "let x = 1; if x == 2 { x }", nil},
+		{"constant-condition", "while 1 < 2 { print(1) }", []string{"1:7: while condition 1 < 2 is constant (constant-condition)"}},
+		{"constant-condition", "let i = 0; while i < 2 { i = i + 1 }", nil},
+		{"literal-comparison", "print(1 == \"1\", 1 == 1.5)", []string{"1:9: comparing number with string is always false (literal-comparison)"}},
+		{"literal-comparison", "print(true < [1])", []string{"1:12: cannot compare boolean with array (literal-comparison)"}},
+		{"duplicate-key", "print({1: 1, 2: 2, 1: 3})", []string{"1:20: duplicate key 1 in hashmap literal (duplicate-key)"}},
+		{"call-non-function", "let a = [1]; a(); 1()", []string{"1:14: a is an array, not a function (call-non-function)", "1:19: 1 is a number, not a function (call-non-function)"}},
+		{"call-non-function", "let a = 1; a = fn() {}; a()", nil},
+		{"arity", "let f = fn(a, b) { a + b }; f(1); f(1, 2); fn(x) { x }()", []string{"1:29: f takes 2 arguments, called with 1 (arity)", "1:44: function takes 1 argument, called with 0 (arity)"}},
+		{"arity", "let f = fn(a) { a }; f = fn() {}; f()", nil},
+		{"arity", "let f = fn(a, b = 1, ...c) { a }; f(); f(1); f(1, 2, 3, 4); f(...[1])", []string{"1:35: f takes at least 1 argument, called with 0 (arity)"}},
+		{"arity", "let f = fn(a, b = 1) { a }; f(1, 2, 3); f(...[1], 2, 3)", []string{"1:29: f takes 1 to 2 arguments, called with 3 (arity)"}},
+		{"builtin-misuse", "len(\"abc\"); len([1], [2]); exit(\"a\"); printf(\"%d%%\", 1)", []string{"1:5: argument 1 of len must be an array, not a string (builtin-misuse)", "1:13: len takes 1 argument, called with 2 (builtin-misuse)", "1:33: argument 1 of exit must be a number, not a string (builtin-misuse)"}},
+		{"builtin-misuse", "printf(\"%d %s\", 1)", []string{"1:8: printf format has 2 verbs for 1 value (builtin-misuse)"}},
+		{"builtin-misuse", "let len = fn(a, b) { a + b }; len(1, 2)", nil},
+		{"undefined", "print(nope)", []string{"1:7: undefined: nope (undefined)"}},
+	}
+
+	for _, tt := range tests {
+		rules, err := Select([]string{tt.rule}, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		issues := runLint(t, tt.input, rules)
+
+		if len(issues) != len(tt.expected) {
+			t.Errorf("%s %q: expected %v, got %v", tt.rule, tt.input, tt.expected, issues)
+			continue
+		}
+
+		for i, issue := range issues {
+			if issue.String() != tt.expected[i] {
+				t.Errorf("%s %q: expected %q, got %q", tt.rule, tt.input, tt.expected[i], issue.String())
+			}
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all, err := Select(nil, nil)
+
+	if err != nil || len(all) != len(Rules) {
+		t.Fatalf("expected every rule by default, got %d, %v", len(all), err)
+	}
+
+	rules, err := Select(nil, []string{"unused", "arity"})
+
+	if err != nil || len(rules) != len(Rules)-2 || find(rules, "unused") != nil || find(rules, "arity") != nil {
+		t.Fatalf("disabled rules are still selected: %v", err)
+	}
+
+	issues := runLint(t, "let x = 1; let f = fn(a) { a }; f();", rules)
+
+	if len(issues) != 0 {
+		t.Errorf("disabled rules reported %v", issues)
+	}
+
+	if _, err := Select([]string{"nope"}, nil); err == nil {
+		t.Errorf("expected an error for an unknown rule")
+	}
+}
@@ -0,0 +1,282 @@
+package lint
+
+import (
+	"Klang/ast"
+	"Klang/format"
+	"fmt"
+	"strings"
+)
+
+func checkAssignInCondition(l *linter, node ast.Node) {
+	var condition ast.Expression
+
+	switch node := node.(type) {
+	case *ast.IfExpression:
+		condition = node.Condition
+	case *ast.WhileStatement:
+		condition = node.Condition
+	default:
+		return
+	}
+
+	if assign, ok := condition.(*ast.AssignmentExpression); ok {
+		l.report(assign.Ident.Token, "assignment to %s used as a condition, did you mean ==?", assign.Ident.Value)
+	}
+}
+
+// checkConstantCondition reports loops whose condition is made of literals
+// only. K has no `break`, such a loop either never runs or only ends with
+// a `return`
+func checkConstantCondition(l *linter, node ast.Node) {
+	loop, ok := node.(*ast.WhileStatement)
+
+	if !ok || !isConstant(loop.Condition) {
+		return
+	}
+
+	l.report(ast.StartToken(loop.Condition), "while condition %s is constant", format.Node(loop.Condition))
+}
+
+func isConstant(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteralExpression, *ast.BooleanLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(expr.Right)
+	case *ast.InfixExpression:
+		return isConstant(expr.Left) && isConstant(expr.Right)
+	default:
+		return false
+	}
+}
+
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func checkLiteralComparison(l *linter, node ast.Node) {
+	infix, ok := node.(*ast.InfixExpression)
+
+	if !ok || !comparisons[infix.Operator] {
+		return
+	}
+
+	left, right := literalType(infix.Left), literalType(infix.Right)
+
+	if left == "" || right == "" || left == right {
+		return
+	}
+
+	if infix.Operator == "==" || infix.Operator == "!=" {
+		l.report(infix.Token, "comparing %s with %s is always %t", left, right, infix.Operator == "!=")
+		return
+	}
+
+	l.report(infix.Token, "cannot compare %s with %s", left, right)
+}
+
+// literalType is the type of the value written by a literal, numbers being
+// comparable with each other. It is "" for any other expression
+func literalType(expr ast.Expression) string {
+	switch expr.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		return "number"
+	case *ast.StringLiteralExpression:
+		return "string"
+	case *ast.BooleanLiteral:
+		return "boolean"
+	case *ast.ArrayLiteralExpression:
+		return "array"
+	case *ast.HashmapLiteralExpression:
+		return "hashmap"
+	case *ast.FunctionLiteralExpression:
+		return "function"
+	default:
+		return ""
+	}
+}
+
+func checkDuplicateKey(l *linter, node ast.Node) {
+	hash, ok := node.(*ast.HashmapLiteralExpression)
+
+	if !ok {
+		return
+	}
+
+	seen := make(map[string]bool)
+
+	for _, key := range hash.Keys {
+		switch key.(type) {
+		case *ast.IntegerLiteral, *ast.StringLiteralExpression, *ast.BooleanLiteral:
+		default:
+			continue
+		}
+
+		if seen[key.String()] {
+			l.report(ast.StartToken(key), "duplicate key %s in hashmap literal", format.Node(key))
+		}
+
+		seen[key.String()] = true
+	}
+}
+
+func checkCallNonFunction(l *linter, node ast.Node) {
+	call, ok := node.(*ast.FunctionCallExpression)
+
+	if !ok {
+		return
+	}
+
+	value := l.value(call.Function)
+	typ := literalType(value)
+
+	if typ == "" || typ == "function" {
+		return
+	}
+
+	l.report(ast.StartToken(call.Function), "%s is %s, not a function", format.Node(call.Function), article(typ))
+}
+
+func checkArity(l *linter, node ast.Node) {
+	call, ok := node.(*ast.FunctionCallExpression)
+
+	if !ok || call.Args == nil {
+		return
+	}
+
+	fn := l.function(call.Function)
+
+	if fn == nil {
+		return
+	}
+
+	min, max := 0, len(fn.Parameters)
+
+	for _, param := range fn.Parameters {
+		switch {
+		case param.Rest:
+			max = -1
+		case param.Default == nil:
+			min++
+		}
+	}
+
+	args := len(call.Args.List)
+
+	for _, arg := range call.Args.List {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			// a spread only gives a lower bound of the number of arguments
+			min, args = 0, args-1
+		}
+	}
+
+	if args >= min && (max < 0 || args <= max) {
+		return
+	}
+
+	name := "function"
+
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	l.report(ast.StartToken(call.Function), "%s takes %s, called with %d", name, arityRange(min, max), len(call.Args.List))
+}
+
+// builtinSpec is what a builtin accepts: between min and max arguments,
+// max being -1 when unbounded, with the literal types of the first ones
+type builtinSpec struct {
+	min, max int
+	types    []string
+}
+
+var builtinSpecs = map[string]builtinSpec{
+	"len":       {1, 1, []string{"array"}},
+	"printf":    {1, -1, []string{"string"}},
+	"readFile":  {1, 1, []string{"string"}},
+	"writeFile": {2, 2, []string{"string"}},
+	"getenv":    {1, 1, []string{"string"}},
+	"args":      {0, 0, nil},
+	"exit":      {0, 1, []string{"number"}},
+	"freeze":    {1, 1, nil},
+}
+
+func checkBuiltinMisuse(l *linter, node ast.Node) {
+	call, ok := node.(*ast.FunctionCallExpression)
+
+	if !ok || call.Args == nil {
+		return
+	}
+
+	name := l.builtin(call.Function)
+	spec, ok := builtinSpecs[name]
+
+	if !ok {
+		return
+	}
+
+	args := call.Args.List
+
+	if len(args) < spec.min || (spec.max >= 0 && len(args) > spec.max) {
+		l.report(ast.StartToken(call.Function), "%s takes %s, called with %d", name, arityRange(spec.min, spec.max), len(args))
+		return
+	}
+
+	for i, typ := range spec.types {
+		if i >= len(args) {
+			break
+		}
+
+		if got := literalType(args[i]); got != "" && got != typ {
+			l.report(ast.StartToken(args[i]), "argument %d of %s must be %s, not %s", i+1, name, article(typ), article(got))
+		}
+	}
+
+	if name == "printf" {
+		checkPrintfVerbs(l, call)
+	}
+}
+
+// checkPrintfVerbs compares the verbs of a literal printf format with the
+// number of values given
+func checkPrintfVerbs(l *linter, call *ast.FunctionCallExpression) {
+	layout, ok := call.Args.List[0].(*ast.StringLiteralExpression)
+
+	if !ok {
+		return
+	}
+
+	verbs := strings.Count(layout.Value, "%") - 2*strings.Count(layout.Value, "%%")
+	values := len(call.Args.List) - 1
+
+	if verbs != values {
+		l.report(layout.Token, "printf format has %s for %s", plural(verbs, "verb"), plural(values, "value"))
+	}
+}
+
+// arityRange describes between min and max arguments, max being -1 when unbounded
+func arityRange(min, max int) string {
+	switch {
+	case max < 0:
+		return "at least " + plural(min, "argument")
+	case min == max:
+		return plural(min, "argument")
+	default:
+		return fmt.Sprintf("%d to %d arguments", min, max)
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// article prefixes a type name with its indefinite article
+func article(typ string) string {
+	if strings.ContainsAny(typ[:1], "aeiou") {
+		return "an " + typ
+	}
+
+	return "a " + typ
+}
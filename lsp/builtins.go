@@ -0,0 +1,21 @@
+package lsp
+
+type builtinDoc struct {
+	signature string
+	doc       string
+}
+
+// builtinDocs describes the builtins of the evaluator for hover and completion
+var builtinDocs = map[string]builtinDoc{
+	"len":       {"len(array)", "Returns the number of elements of an array."},
+	"print":     {"print(values...)", "Writes the values separated by spaces, followed by a newline."},
+	"println":   {"println(values...)", "Writes the values next to each other, followed by a newline."},
+	"eprint":    {"eprint(values...)", "Same as `print`, on the standard error."},
+	"printf":    {"printf(format, values...)", "Writes the values formatted by the Go verbs of `format`."},
+	"freeze":    {"freeze(value)", "Makes an array or a hashmap, and everything it holds, immutable. Returns `value`."},
+	"readFile":  {"readFile(path)", "Returns the content of the file at `path` as a string."},
+	"writeFile": {"writeFile(path, content)", "Writes `content` to the file at `path`, replacing it."},
+	"getenv":    {"getenv(name)", "Returns the value of the environment variable `name`, nil when it is not set."},
+	"args":      {"args()", "Returns the command line arguments given after the script name."},
+	"exit":      {"exit(code)", "Stops the program with the status `code`, 0 when omitted."},
+}
@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"Klang/ast"
+	"Klang/lexer"
+	"Klang/parser"
+	"Klang/resolve"
+	"Klang/token"
+	"Klang/typecheck"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open file, parsed and resolved again on every change
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	program *ast.Program
+	errors  []*parser.Error
+	info    *resolve.Info
+	types   []*typecheck.Error
+}
+
+// newDocument analyses text. Should the analysis panic, the document is
+// kept empty with the panic as its only error, so that it stays open
+func newDocument(uri, text string, predeclared []string) (doc *document) {
+	doc = &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	defer func() {
+		if r := recover(); r != nil {
+			doc.program = &ast.Program{}
+			doc.errors = []*parser.Error{{Line: 1, Column: 1, Message: fmt.Sprintf("internal error: %v", r)}}
+			doc.info = resolve.Resolve(doc.program, predeclared)
+			doc.types = nil
+		}
+	}()
+
+	p := parser.New(lexer.New(text))
+	doc.program = p.ParseProgram()
+	doc.errors = p.Errors()
+	doc.info = resolve.Resolve(doc.program, predeclared)
+	doc.types = typecheck.Check(doc.program, predeclared)
+	return doc
+}
+
+// position converts a 1-based line and byte column of the source to an LSP position
+func (d *document) position(line, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+
+	if line > len(d.lines) {
+		return Position{Line: line - 1}
+	}
+
+	text := d.lines[line-1]
+	offset := column - 1
+
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return Position{Line: line - 1, Character: utf16Len(text[:offset])}
+}
+
+// location converts an LSP position to the 1-based line and byte column of the source
+func (d *document) location(pos Position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, pos.Character + 1
+	}
+
+	text := d.lines[pos.Line]
+	units := 0
+	offset := 0
+
+	for offset < len(text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+
+	return pos.Line + 1, offset + 1
+}
+
+// tokenRange is the range covered by the first length bytes at tok
+func (d *document) tokenRange(tok token.Token, length int) Range {
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+length),
+	}
+}
+
+// wordRange covers the identifier or keyword starting at line:column, a
+// single character when there is none
+func (d *document) wordRange(line, column int) Range {
+	length := 1
+
+	if line >= 1 && line <= len(d.lines) && column >= 1 {
+		text := d.lines[line-1]
+		end := column - 1
+
+		for end < len(text) && isWordByte(text[end]) {
+			end++
+		}
+
+		if end > column-1 {
+			length = end - (column - 1)
+		}
+	}
+
+	return d.tokenRange(token.Token{Line: line, Column: column}, length)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.tokenRange(ident.Token, len(ident.Value))
+}
+
+// fullRange covers the whole document
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+
+	return n
+}
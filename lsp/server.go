@@ -0,0 +1,560 @@
+// Package lsp implements a Language Server Protocol server for K over a
+// pair of streams, usually the standard input and output of `klang lsp`.
+// Documents are kept in memory, synchronized in full on every change.
+package lsp
+
+import (
+	"Klang/ast"
+	"Klang/eval"
+	"Klang/format"
+	"Klang/resolve"
+	"Klang/token"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	mu          sync.Mutex // serializes writes to out
+	docs        map[string]*document
+	predeclared []string
+	shutdown    bool
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+type notificationHandler func(s *Server, params json.RawMessage) error
+
+var notificationHandlers = map[string]notificationHandler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		docs:        make(map[string]*document),
+		predeclared: eval.New(io.Discard, io.Discard).BuiltinNames(),
+	}
+}
+
+// Serve handles messages until the client sends `exit` or closes the input
+func (s *Server) Serve() error {
+	for {
+		msg, err := readMessage(s.in)
+
+		if err == io.EOF {
+			return nil
+		}
+
+		var rpcErr *responseError
+
+		if errors.As(err, &rpcErr) {
+			s.reply(nil, nil, rpcErr)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		s.handle(msg)
+	}
+}
+
+// handle answers a single message. A panic is answered with an internal
+// error, or dropped for a notification, and the server goes on with the
+// next message
+func (s *Server) handle(msg *message) {
+	defer func() {
+		if r := recover(); r != nil && msg.ID != nil {
+			s.reply(msg.ID, nil, &responseError{Code: codeInternalError, Message: fmt.Sprintf("internal error: %v", r)})
+		}
+	}()
+
+	if msg.ID == nil {
+		if handle, ok := notificationHandlers[msg.Method]; ok {
+			// notifications have no reply to carry an error
+			_ = handle(s, msg.Params)
+		}
+
+		return
+	}
+
+	handle, ok := handlers[msg.Method]
+
+	if !ok {
+		s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+		return
+	}
+
+	if s.shutdown {
+		s.reply(msg.ID, nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+		return
+	}
+
+	result, err := handle(s, msg.Params)
+
+	if err != nil {
+		rpcErr, ok := err.(*responseError)
+
+		if !ok {
+			rpcErr = &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+
+		s.reply(msg.ID, nil, rpcErr)
+		return
+	}
+
+	s.reply(msg.ID, result, nil)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *responseError) {
+	msg := &message{ID: id, Error: rpcErr}
+
+	if id == nil {
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+
+	if rpcErr == nil {
+		msg.Result, _ = json.Marshal(result)
+	}
+
+	s.write(msg)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	raw, _ := json.Marshal(params)
+	s.write(&message{Method: method, Params: raw})
+}
+
+func (s *Server) write(msg *message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeMessage(s.out, msg)
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", uri)
+	}
+
+	return doc, nil
+}
+
+// documentAt decodes text document position params and returns the
+// document along with the 1-based line and column they point at
+func (s *Server) documentAt(params json.RawMessage, v *textDocumentPositionParams) (*document, int, int, error) {
+	if err := json.Unmarshal(params, v); err != nil {
+		return nil, 0, 0, err
+	}
+
+	doc, err := s.document(v.TextDocument.URI)
+
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	line, column := doc.location(v.Position)
+	return doc, line, column, nil
+}
+
+// -----------------------------
+// Lifecycle
+// -----------------------------
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           syncFull,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"hoverProvider":              true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+			"completionProvider":         map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{"name": "klang"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+// -----------------------------
+// Document synchronization
+// -----------------------------
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p didOpenParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	s.open(p.TextDocument.URI, p.TextDocument.Text)
+	return nil
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p didChangeParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	// with full synchronization the last change holds the whole text
+	if len(p.ContentChanges) > 0 {
+		s.open(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	}
+
+	return nil
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p didCloseParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	delete(s.docs, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil
+}
+
+func (s *Server) open(uri, text string) {
+	doc := newDocument(uri, text, s.predeclared)
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics(doc)})
+}
+
+func diagnostics(doc *document) []Diagnostic {
+	diags := []Diagnostic{}
+
+	for _, err := range doc.errors {
+		diags = append(diags, Diagnostic{
+			Range:    doc.tokenRange(token.Token{Line: err.Line, Column: err.Column}, 1),
+			Severity: severityError,
+			Source:   "klang",
+			Message:  err.Message,
+		})
+	}
+
+	// names in a tree broken by syntax errors are not worth reporting
+	if len(doc.errors) > 0 {
+		return diags
+	}
+
+	for _, diag := range doc.info.Diagnostics {
+		severity := severityWarning
+
+		if diag.Severity == resolve.Error {
+			severity = severityError
+		}
+
+		diags = append(diags, Diagnostic{
+			Range:    doc.wordRange(diag.Line, diag.Column),
+			Severity: severity,
+			Source:   "klang",
+			Code:     diag.Code,
+			Message:  diag.Message,
+		})
+	}
+
+	for _, err := range doc.types {
+		diags = append(diags, Diagnostic{
+			Range:    doc.tokenRange(token.Token{Line: err.Line, Column: err.Column}, 1),
+			Severity: severityError,
+			Source:   "klang",
+			Code:     "type",
+			Message:  err.Message,
+		})
+	}
+
+	return diags
+}
+
+// -----------------------------
+// Navigation
+// -----------------------------
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPositionParams
+	doc, line, column, err := s.documentAt(params, &p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, sym := doc.info.Lookup(line, column)
+
+	if sym == nil || sym.Decl == nil {
+		return nil, nil
+	}
+
+	return Location{URI: doc.uri, Range: doc.identRange(sym.Decl)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p referenceParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, line, column, err := s.documentAt(params, &p.textDocumentPositionParams)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, sym := doc.info.Lookup(line, column)
+	locations := []Location{}
+
+	if sym == nil {
+		return locations, nil
+	}
+
+	if p.Context.IncludeDeclaration && sym.Decl != nil {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.identRange(sym.Decl)})
+	}
+
+	for _, ref := range sym.Refs {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.identRange(ref)})
+	}
+
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPositionParams
+	doc, line, column, err := s.documentAt(params, &p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ident, sym := doc.info.Lookup(line, column)
+
+	if sym == nil {
+		return nil, nil
+	}
+
+	text := "```klang\n" + signature(sym) + "\n```"
+
+	if sym.Kind == resolve.Builtin {
+		if builtin, ok := builtinDocs[sym.Name]; ok {
+			text += "\n\n" + builtin.doc
+		}
+	}
+
+	r := doc.identRange(ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+// signature describes sym the way it is declared
+func signature(sym *resolve.Symbol) string {
+	switch sym.Kind {
+	case resolve.Builtin:
+		if builtin, ok := builtinDocs[sym.Name]; ok {
+			return builtin.signature
+		}
+
+		return sym.Name + "(...)"
+
+	case resolve.Parameter:
+		return "(parameter) " + sym.Name
+
+	case resolve.Function:
+		fn, _ := sym.Value.(*ast.FunctionLiteralExpression)
+		return "fn " + sym.Name + strings.TrimPrefix(functionHeader(fn), "fn")
+
+	case resolve.Constant:
+		if fn, ok := sym.Value.(*ast.FunctionLiteralExpression); ok {
+			return "const " + sym.Name + " = " + functionHeader(fn)
+		}
+
+		return "const " + sym.Name
+
+	case resolve.Struct:
+		return sym.Struct.String()
+
+	case resolve.Trait:
+		return "trait " + sym.Name
+
+	default:
+		if fn, ok := sym.Value.(*ast.FunctionLiteralExpression); ok {
+			return "let " + sym.Name + " = " + functionHeader(fn)
+		}
+
+		return "let " + sym.Name
+	}
+}
+
+func functionHeader(fn *ast.FunctionLiteralExpression) string {
+	params := []string{}
+
+	for _, param := range fn.Parameters {
+		params = append(params, format.Node(param))
+	}
+
+	header := "fn(" + strings.Join(params, ", ") + ")"
+
+	if fn.ReturnType != nil {
+		header += " -> " + fn.ReturnType.String()
+	}
+
+	return header
+}
+
+// -----------------------------
+// Symbols and completion
+// -----------------------------
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p documentParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return documentSymbols(doc, doc.info.Global), nil
+}
+
+// documentSymbols lists the symbols declared in scope, the locals of a
+// function bound by `let` being children of its symbol
+func documentSymbols(doc *document, scope *resolve.Scope) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, sym := range scope.Symbols {
+		r := doc.identRange(sym.Decl)
+		symbol := DocumentSymbol{Name: sym.Name, Kind: symbolVariable, Range: r, SelectionRange: r}
+
+		switch sym.Kind {
+		case resolve.Constant:
+			symbol.Kind = symbolConstant
+		case resolve.Trait:
+			symbol.Kind = symbolInterface
+		}
+
+		if sym.Struct != nil {
+			symbol.Kind = symbolStruct
+			symbol.Children = []DocumentSymbol{}
+
+			for _, field := range sym.Struct.Fields {
+				fieldRange := doc.identRange(field)
+				symbol.Children = append(symbol.Children, DocumentSymbol{Name: field.Value, Kind: symbolField, Range: fieldRange, SelectionRange: fieldRange})
+			}
+		}
+
+		if fn, ok := sym.Value.(*ast.FunctionLiteralExpression); ok {
+			symbol.Kind = symbolFunction
+			symbol.Detail = functionHeader(fn)
+
+			if inner, ok := doc.info.Scopes[fn]; ok {
+				symbol.Children = documentSymbols(doc, inner)
+			}
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPositionParams
+	doc, line, column, err := s.documentAt(params, &p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+
+	for _, sym := range doc.info.ScopeAt(line, column).Visible() {
+		item := CompletionItem{Label: sym.Name, Kind: completionVariable, Detail: signature(sym)}
+
+		if _, ok := sym.Value.(*ast.FunctionLiteralExpression); ok || sym.Kind == resolve.Builtin {
+			item.Kind = completionFunction
+		}
+
+		switch sym.Kind {
+		case resolve.Struct:
+			item.Kind = completionStruct
+		case resolve.Trait:
+			item.Kind = completionInterface
+		}
+
+		items = append(items, item)
+	}
+
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+
+	return items, nil
+}
+
+// -----------------------------
+// Formatting
+// -----------------------------
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p documentParams
+
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source(doc.text)
+
+	// a document that does not parse is left alone, its diagnostics explain why
+	if err != nil || formatted == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	return []TextEdit{{Range: doc.fullRange(), NewText: formatted}}, nil
+}
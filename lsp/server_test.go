@@ -0,0 +1,426 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client talks JSON-RPC to a server running in the same process
+type client struct {
+	t             *testing.T
+	in            io.WriteCloser
+	messages      chan *message
+	notifications []*message
+	nextID        int
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, in: clientOut, messages: make(chan *message, 64)}
+
+	go func() {
+		NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := bufio.NewReader(clientIn)
+
+		for {
+			msg, err := readMessage(r)
+
+			if err != nil {
+				close(c.messages)
+				return
+			}
+
+			c.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() {
+		c.notify("exit", nil)
+		clientOut.Close()
+	})
+
+	c.call("initialize", map[string]interface{}{}, nil)
+	return c
+}
+
+func (c *client) send(msg *message) {
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatalf("write: %s", err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	raw, _ := json.Marshal(params)
+	c.send(&message{Method: method, Params: raw})
+}
+
+// call sends a request and decodes its result into result, the
+// notifications received in the meantime are kept aside
+func (c *client) call(method string, params interface{}, result interface{}) {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.nextID))))
+	raw, _ := json.Marshal(params)
+	c.send(&message{ID: &id, Method: method, Params: raw})
+
+	for {
+		msg := c.receive()
+
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: reply to request %s, expected %s", method, *msg.ID, id)
+		}
+
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %s", method, msg.Error)
+		}
+
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: decoding %s: %s", method, msg.Result, err)
+			}
+		}
+
+		return
+	}
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+
+		return msg
+
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+		return nil
+	}
+}
+
+// diagnostics waits for the next diagnostics published for uri
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+
+	for {
+		var msg *message
+
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.receive()
+		}
+
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params publishDiagnosticsParams
+		json.Unmarshal(msg.Params, &params)
+
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri, text string) {
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, Text: text}})
+}
+
+func mustMarshal(v interface{}) []byte {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+func at(uri string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+const source = `let add = fn(a, b) {
+	let sum = a + b;
+	return sum;
+};
+
+let total = add(1, 2);
+print(total);
+`
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	c.open("file:///ok.mk", source)
+
+	if diags := c.diagnostics("file:///ok.mk"); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diags)
+	}
+
+	c.open("file:///bad.mk", "let x = 1;\nlet = 2;")
+	diags := c.diagnostics("file:///bad.mk")
+
+	if len(diags) == 0 {
+		t.Fatalf("expected diagnostics, got %v", diags)
+	}
+
+	if diags[0].Range.Start != (Position{Line: 1, Character: 4}) || diags[0].Severity != severityError {
+		t.Errorf("wrong first diagnostic: %+v", diags[0])
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   textDocumentIdentifier{URI: "file:///bad.mk"},
+		"contentChanges": []map[string]string{{"text": "let x = 1;\nprint(x);"}},
+	})
+
+	if diags := c.diagnostics("file:///bad.mk"); len(diags) != 0 {
+		t.Fatalf("expected the change to fix the diagnostics, got %v", diags)
+	}
+}
+
+func TestResolverDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", "let unused = 1;\nprint(missing);")
+
+	diags := c.diagnostics("file:///a.mk")
+
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diags)
+	}
+
+	if diags[0].Code != "unused" || diags[0].Severity != severityWarning || diags[0].Range.End != (Position{Line: 0, Character: 10}) {
+		t.Errorf("wrong unused diagnostic: %+v", diags[0])
+	}
+
+	if diags[1].Code != "undefined" || diags[1].Severity != severityError || diags[1].Range.Start != (Position{Line: 1, Character: 6}) {
+		t.Errorf("wrong undefined diagnostic: %+v", diags[1])
+	}
+}
+
+func TestTypeDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", "let s: string = 1;\nprint(s);")
+
+	diags := c.diagnostics("file:///a.mk")
+
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diags)
+	}
+
+	if diags[0].Code != "type" || diags[0].Severity != severityError || diags[0].Range.Start != (Position{Line: 0, Character: 16}) {
+		t.Errorf("wrong type diagnostic: %+v", diags[0])
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", source)
+
+	tests := []struct {
+		line, character int
+		expected        Position
+	}{
+		{5, 13, Position{Line: 0, Character: 4}},  // add
+		{6, 7, Position{Line: 5, Character: 4}},   // total
+		{1, 11, Position{Line: 0, Character: 13}}, // a
+		{2, 9, Position{Line: 1, Character: 5}},   // sum
+	}
+
+	for _, tt := range tests {
+		var loc Location
+		c.call("textDocument/definition", at("file:///a.mk", tt.line, tt.character), &loc)
+
+		if loc.URI != "file:///a.mk" || loc.Range.Start != tt.expected {
+			t.Errorf("definition at %d:%d: expected %v, got %+v", tt.line, tt.character, tt.expected, loc)
+		}
+	}
+
+	var loc *Location
+	c.call("textDocument/definition", at("file:///a.mk", 6, 1), &loc)
+
+	if loc != nil {
+		t.Errorf("expected no definition for a builtin, got %+v", loc)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", source)
+
+	params := referenceParams{textDocumentPositionParams: at("file:///a.mk", 0, 14)}
+	params.Context.IncludeDeclaration = true
+
+	var locs []Location
+	c.call("textDocument/references", params, &locs)
+
+	if len(locs) != 2 {
+		t.Fatalf("expected 2 references of `a`, got %+v", locs)
+	}
+
+	if locs[1].Range.Start != (Position{Line: 1, Character: 11}) {
+		t.Errorf("wrong reference: %+v", locs[1])
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", source)
+
+	var hover Hover
+	c.call("textDocument/hover", at("file:///a.mk", 6, 2), &hover)
+
+	if !strings.Contains(hover.Contents.Value, "print(values...)") {
+		t.Errorf("expected the signature of print, got %q", hover.Contents.Value)
+	}
+
+	c.call("textDocument/hover", at("file:///a.mk", 5, 13), &hover)
+
+	if !strings.Contains(hover.Contents.Value, "let add = fn(a, b)") {
+		t.Errorf("expected the declaration of add, got %q", hover.Contents.Value)
+	}
+
+	c.open("file:///b.mk", "twice(1);\nfn twice(n: int) -> int { n * 2 }")
+	c.call("textDocument/hover", at("file:///b.mk", 0, 1), &hover)
+
+	if !strings.Contains(hover.Contents.Value, "fn twice(n: int) -> int") {
+		t.Errorf("expected the declaration of twice, got %q", hover.Contents.Value)
+	}
+
+	c.open("file:///c.mk", "struct Point { x, y }\nPoint(1, 2);")
+	c.call("textDocument/hover", at("file:///c.mk", 1, 1), &hover)
+
+	if !strings.Contains(hover.Contents.Value, "struct Point { x, y }") {
+		t.Errorf("expected the declaration of Point, got %q", hover.Contents.Value)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", source)
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", documentParams{TextDocument: textDocumentIdentifier{URI: "file:///a.mk"}}, &symbols)
+
+	if len(symbols) != 2 || symbols[0].Name != "add" || symbols[1].Name != "total" {
+		t.Fatalf("wrong symbols: %+v", symbols)
+	}
+
+	if symbols[0].Kind != symbolFunction || len(symbols[0].Children) != 3 {
+		t.Errorf("expected add to be a function with 3 locals, got %+v", symbols[0])
+	}
+
+	c.open("file:///b.mk", "struct Point { x, y }\nPoint(1, 2);")
+	c.call("textDocument/documentSymbol", documentParams{TextDocument: textDocumentIdentifier{URI: "file:///b.mk"}}, &symbols)
+
+	if len(symbols) != 1 || symbols[0].Kind != symbolStruct || len(symbols[0].Children) != 2 || symbols[0].Children[1].Name != "y" {
+		t.Errorf("expected Point to be a struct with 2 fields, got %+v", symbols)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", source)
+
+	var items []CompletionItem
+	c.call("textDocument/completion", at("file:///a.mk", 2, 1), &items)
+
+	labels := map[string]bool{}
+
+	for _, item := range items {
+		labels[item.Label] = true
+	}
+
+	for _, expected := range []string{"a", "b", "sum", "add", "total", "print", "let", "while"} {
+		if !labels[expected] {
+			t.Errorf("expected %q among the completions", expected)
+		}
+	}
+
+	c.call("textDocument/completion", at("file:///a.mk", 6, 0), &items)
+
+	for _, item := range items {
+		if item.Label == "sum" {
+			t.Errorf("function local `sum` completed outside of the function")
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.open("file:///a.mk", "let x=1\nprint( x )")
+
+	var edits []TextEdit
+	c.call("textDocument/formatting", documentParams{TextDocument: textDocumentIdentifier{URI: "file:///a.mk"}}, &edits)
+
+	if len(edits) != 1 || edits[0].NewText != "let x = 1;\nprint(x);\n" {
+		t.Fatalf("wrong edits: %+v", edits)
+	}
+
+	if edits[0].Range.End != (Position{Line: 1, Character: 10}) {
+		t.Errorf("expected the edit to cover the document, got %+v", edits[0].Range)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := newClient(t)
+
+	id := json.RawMessage("99")
+	c.send(&message{ID: &id, Method: "workspace/unknown"})
+
+	msg := c.receive()
+
+	for msg.ID == nil {
+		msg = c.receive()
+	}
+
+	if msg.Error == nil || msg.Error.Code != codeMethodNotFound {
+		t.Fatalf("expected a method not found error, got %+v", msg)
+	}
+}
+
+func TestRecoversFromPanics(t *testing.T) {
+	handlers["test/panic"] = func(s *Server, params json.RawMessage) (interface{}, error) { panic("boom") }
+	notificationHandlers["test/panic"] = func(s *Server, params json.RawMessage) error { panic("boom") }
+
+	defer delete(handlers, "test/panic")
+	defer delete(notificationHandlers, "test/panic")
+
+	c := newClient(t)
+	c.notify("test/panic", nil)
+
+	id := json.RawMessage("99")
+	c.send(&message{ID: &id, Method: "test/panic"})
+
+	msg := c.receive()
+
+	for msg.ID == nil {
+		msg = c.receive()
+	}
+
+	if msg.Error == nil || msg.Error.Code != codeInternalError || msg.Error.Message != "internal error: boom" {
+		t.Fatalf("expected an internal error, got %+v", msg)
+	}
+
+	// the server still answers
+	c.call("shutdown", nil, nil)
+}
@@ -1,33 +1,98 @@
 package main
 
 import (
-	"Klang/eval"
-	"Klang/lexer"
-	"Klang/object"
-	"Klang/parser"
-	"Klang/repl"
-	"io/ioutil"
-	"log"
+	"fmt"
+	"io"
 	"os"
 )
 
+const VERSION = "0.2.0"
+
+const USAGE = `K Programming Language
+
+usage:
+  klang                          start the repl
+  klang <command> [arguments]
+  klang -e <expr>                evaluate expr and print its value
+
+commands:
+  run <file.mk> [args...]        run a script, args are returned by args()
+  repl                           start the interactive prompt
+  check <file.mk>...             report syntax and type errors, undefined and
+                                 unused names, shadowing and unreachable code
+  tokens <file.mk>               print the tokens of a file
+  ast <file.mk>                  print the syntax tree of a file
+  fmt [-w] [-l] <file.mk>...     print files in the canonical layout
+                                   -w  rewrite the files in place
+                                   -l  only list files whose layout differs,
+                                       exiting with 1 when there is any
+  lint [flags] <file.mk>...      check files against the lint rules
+                                   -json     print the issues as JSON
+                                   -enable   comma separated rules to run
+                                   -disable  comma separated rules to skip
+                                   -rules    list the rules
+  lsp                            serve the language server protocol on stdio
+  version                        print the version
+  help                           show this help
+
+A file named - is read from the standard input.
+`
+
 func main() {
-	if len(os.Args) > 1 {
+	os.Exit(runCli(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-		contentBuff, err := ioutil.ReadFile(os.Args[1])
-		content := string(contentBuff)
+// runCli dispatches the command line and returns the exit code
+func runCli(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
 
-		if err != nil {
-			log.Fatal(err)
-		}
+	if len(args) == 0 {
+		return c.cmdRepl(nil)
+	}
+
+	switch args[0] {
+	case "run":
+		return c.cmdRun(args[1:])
+
+	case "repl":
+		return c.cmdRepl(args[1:])
 
-		env := object.NewEnvironment()
-		l := lexer.New(content)
-		p := parser.New(l)
-		program := p.ParseProgram()
+	case "check":
+		return c.cmdCheck(args[1:])
+
+	case "tokens":
+		return c.cmdTokens(args[1:])
+
+	case "ast":
+		return c.cmdAst(args[1:])
+
+	case "fmt":
+		return c.cmdFmt(args[1:])
+
+	case "lint":
+		return c.cmdLint(args[1:])
+
+	case "lsp":
+		return c.cmdLsp(args[1:])
+
+	case "-e":
+		return c.cmdExpr(args[1:])
+
+	case "version", "-v", "--version":
+		fmt.Fprintf(stdout, "klang %s\n", VERSION)
+		return 0
+
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, USAGE)
+		return 0
+
+	default:
+		// `klang file.mk` keeps working as a shorthand for `klang run file.mk`
+		if _, err := os.Stat(args[0]); err == nil || args[0] == "-" {
+			return c.cmdRun(args)
+		}
 
-		eval.Eval(program, env)
-	} else {
-		repl.Start()
+		fmt.Fprintf(stderr, "klang: unknown command %s\n\n%s", args[0], USAGE)
+		return 2
 	}
 }
@@ -1,16 +1,35 @@
 package object
 
+import (
+	"Klang/ast"
+	"fmt"
+	"sort"
+)
+
+// Environment holds the bindings of the program or of a function call.
+// Bindings live in slots in the order they are declared, the resolver
+// predicting that order lets reads skip the lookup by name
 type Environment struct {
-	Vars   map[string]Object
 	Parent *Environment
+
+	// Redeclarable lets a declaration replace a binding of the same name,
+	// as the repl wants when a definition is typed again
+	Redeclarable bool
+
+	slots []binding
+	names map[string]int // name to its slot
 }
 
-func NewEnvironment() *Environment {
-	env := Environment{}
-	env.Vars = make(map[string]Object)
-	env.Parent = nil
+// binding is a name declared in an environment
+type binding struct {
+	name     string // "" for a slot reserved by the resolver but not declared yet
+	value    Object
+	constant bool
+	decl     *ast.Identifier // nil for bindings made by the host
+}
 
-	return &env
+func NewEnvironment() *Environment {
+	return &Environment{names: make(map[string]int)}
 }
 
 func NewEnvironmentWithParent(parent *Environment) *Environment {
@@ -19,21 +38,159 @@ func NewEnvironmentWithParent(parent *Environment) *Environment {
 	return env
 }
 
-func (env *Environment) Set(key string, val Object) Object {
-	env.Vars[key] = val
-	return val
+// Declare binds name to val in env. Declaring a name env already holds is an
+// error, unless decl is the declaration that bound it running again, like a
+// `let` in a loop. decl is nil for bindings made by the host
+func (env *Environment) Declare(name string, val Object, decl *ast.Identifier) error {
+	return env.declare(binding{name: name, value: val, decl: decl})
+}
+
+// DeclareConst is Declare for a binding that Assign refuses to change
+func (env *Environment) DeclareConst(name string, val Object, decl *ast.Identifier) error {
+	return env.declare(binding{name: name, value: val, constant: true, decl: decl})
+}
+
+func (env *Environment) declare(b binding) error {
+	if slot, ok := env.names[b.name]; ok {
+		old := &env.slots[slot]
+
+		// the same declaration running again replaces its binding
+		if b.decl == nil || b.decl != old.decl {
+			if old.constant {
+				return fmt.Errorf("cannot redeclare constant %s", b.name)
+			}
+
+			if !env.Redeclarable {
+				return fmt.Errorf("%s is already declared in this scope", b.name)
+			}
+		}
+
+		*old = b
+		return nil
+	}
+
+	slot := len(env.slots)
+
+	// take the slot the resolver gave the declaration when it is free, the
+	// ones before it are reserved for the declarations it expects first
+	if b.decl != nil && b.decl.Resolved {
+		if want := b.decl.Slot; want >= slot {
+			for len(env.slots) <= want {
+				env.slots = append(env.slots, binding{})
+			}
+
+			slot = want
+		} else if env.slots[want].name == "" {
+			slot = want
+		}
+	}
+
+	if slot == len(env.slots) {
+		env.slots = append(env.slots, b)
+	} else {
+		env.slots[slot] = b
+	}
+
+	env.names[b.name] = slot
+	return nil
+}
+
+// Assign stores val in the binding of name visible from env. It fails when
+// name is not declared or is a constant
+func (env *Environment) Assign(name string, val Object) error {
+	return assign(env.find(name), name, val)
+}
+
+// AssignAt is Assign for a name the resolver located depth scopes up at slot
+func (env *Environment) AssignAt(depth, slot int, name string, val Object) error {
+	if b := env.at(depth, slot, name); b != nil {
+		return assign(b, name, val)
+	}
+
+	return env.Assign(name, val)
+}
+
+func assign(b *binding, name string, val Object) error {
+	if b == nil {
+		return fmt.Errorf("cannot assign to undeclared %s", name)
+	}
+
+	if b.constant {
+		return fmt.Errorf("cannot assign to constant %s", name)
+	}
+
+	b.value = val
+	return nil
 }
 
-func (env *Environment) Get(key string) Object {
-	if val, ok := env.Vars[key]; ok {
-		return val
+// Lookup returns the value of the binding of name visible from env
+func (env *Environment) Lookup(name string) (Object, bool) {
+	if b := env.find(name); b != nil {
+		return b.value, true
 	}
 
-	if env.Parent != nil {
-		if val := env.Parent.Get(key); val != nil {
-			return val
+	return nil, false
+}
+
+// LookupAt is Lookup for a name the resolver located depth scopes up at slot.
+// It falls back to Lookup when the slot does not hold name, as happens when
+// a declaration was skipped or the environment holds bindings the resolver
+// did not see
+func (env *Environment) LookupAt(depth, slot int, name string) (Object, bool) {
+	if b := env.at(depth, slot, name); b != nil {
+		return b.value, true
+	}
+
+	return env.Lookup(name)
+}
+
+// IsConst reports whether the binding of name visible from env is a constant
+func (env *Environment) IsConst(name string) bool {
+	b := env.find(name)
+	return b != nil && b.constant
+}
+
+// find returns the binding of name visible from env, nil when there is none
+func (env *Environment) find(name string) *binding {
+	for scope := env; scope != nil; scope = scope.Parent {
+		if slot, ok := scope.names[name]; ok {
+			return &scope.slots[slot]
 		}
 	}
 
 	return nil
 }
+
+// at returns the binding at slot of the environment depth levels above env
+// when it holds name, nil otherwise
+func (env *Environment) at(depth, slot int, name string) *binding {
+	scope := env
+
+	for ; depth > 0 && scope != nil; depth-- {
+		scope = scope.Parent
+	}
+
+	if scope == nil || slot < 0 || slot >= len(scope.slots) || scope.slots[slot].name != name {
+		return nil
+	}
+
+	return &scope.slots[slot]
+}
+
+// Names returns every name visible from env, including the ones of its parents
+func (env *Environment) Names() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for scope := env; scope != nil; scope = scope.Parent {
+		for name := range scope.names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
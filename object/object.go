@@ -4,6 +4,7 @@ import (
 	"Klang/ast"
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -20,6 +21,11 @@ const (
 	OBJECT_FUNCTION = "OBJECT_FUNCTION"
 	OBJECT_RETURN   = "OBJECT_RETURN"
 	OBJECT_BUILTIN  = "OBJECT_BUILTIN"
+	OBJECT_ERROR    = "OBJECT_ERROR"
+	OBJECT_HOST     = "OBJECT_HOST"
+	OBJECT_STRUCT   = "OBJECT_STRUCT"
+	OBJECT_TYPE     = "OBJECT_TYPE"
+	OBJECT_TRAIT    = "OBJECT_TRAIT"
 )
 
 type Object interface {
@@ -36,6 +42,18 @@ type Hashable interface {
 	Hashkey() Hash
 }
 
+// Indexer is implemented by objects that handle `obj[key]` themselves,
+// such as values bound from a Go host
+type Indexer interface {
+	Index(key Object) Object
+}
+
+// IndexAssigner is implemented by objects that handle `obj[key] = value`
+// themselves, returning an error or nil
+type IndexAssigner interface {
+	SetIndex(key, value Object) Object
+}
+
 // ------------------------------
 // String Object
 // ------------------------------
@@ -122,22 +140,12 @@ func (b *Boolean) Type() ObjectType {
 // Array Object
 // ------------------------------
 type Array struct {
-	Value []Object
+	Value  []Object
+	Frozen bool // set by `freeze`, the elements cannot be assigned
 }
 
 func (a *Array) Inspect() string {
-	var out bytes.Buffer
-
-	arrStr := []string{}
-
-	for _, elem := range a.Value {
-		arrStr = append(arrStr, elem.Inspect())
-	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(arrStr, ", "))
-	out.WriteString("]")
-	return out.String()
+	return inspectString(a)
 }
 
 func (a *Array) Type() ObjectType {
@@ -148,33 +156,213 @@ func (a *Array) Type() ObjectType {
 // HashMap Object
 // ------------------------------
 type HashMap struct {
-	Value map[Hash]Object
+	Value  map[Hash]Object
+	Frozen bool // set by `freeze`, the pairs cannot be assigned
 }
 
 func (h *HashMap) Inspect() string {
-	var out bytes.Buffer
+	return inspectString(h)
+}
+
+func (h *HashMap) Type() ObjectType {
+	return OBJECT_HASHMAP
+}
 
-	arrStr := []string{}
+// ------------------------------
+// Struct Object
+// ------------------------------
+
+// StructType is the type declared by `struct Name { fields }`. Calling it
+// builds a Struct from its fields in order
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function // added by `impl` blocks, nil until the first one
+}
+
+func (st *StructType) Inspect() string {
+	if len(st.Fields) == 0 {
+		return "struct " + st.Name + " {}"
+	}
+
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+func (st *StructType) Type() ObjectType {
+	return OBJECT_TYPE
+}
 
-	for key, val := range h.Value {
-		arrStr = append(arrStr, key.Value+":"+val.Inspect())
+// Field returns the position of the field name, -1 when there is none
+func (st *StructType) Field(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(arrStr, ", "))
-	out.WriteString("}")
+	return -1
+}
+
+// OperatorMethods are the methods overloading the operators for the values
+// of a struct, called on the left operand with the right one. `!=` negates
+// `__eq__` and `[]` calls `__index__` with the index
+var OperatorMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"==": "__eq__",
+	"<":  "__lt__",
+	"<=": "__le__",
+	">":  "__gt__",
+	">=": "__ge__",
+}
+
+// Method returns the method name added to st by an impl block
+func (st *StructType) Method(name string) (*Function, bool) {
+	method, ok := st.Methods[name]
+	return method, ok
+}
+
+// Struct is a value of a StructType, holding its fields in the order of the
+// declaration
+type Struct struct {
+	Def    *StructType
+	Fields []Object
+	Frozen bool // set by `freeze`, the fields cannot be assigned
+}
+
+func (s *Struct) Inspect() string {
+	return inspectString(s)
+}
+
+func (s *Struct) Type() ObjectType {
+	return OBJECT_STRUCT
+}
+
+// Fprint writes the Inspect form of obj to w piece by piece, stopping at
+// the first error of w. A writer that fails once it has seen enough keeps
+// a value whose elements are shared many times, as after `a = [a, a]` in
+// a loop, from being expanded in full
+func Fprint(w io.Writer, obj Object) error {
+	in := &inspector{w: w, seen: make(map[Object]bool)}
+	in.inspect(obj)
+	return in.err
+}
+
+func inspectString(obj Object) string {
+	var out strings.Builder
+	Fprint(&out, obj)
 	return out.String()
 }
 
-func (h *HashMap) Type() ObjectType {
-	return OBJECT_HASHMAP
+// inspector renders values, the arrays, hashmaps and structs in seen being
+// the ones it is nested in. A value nested in itself, as after `a[0] = a`,
+// is printed as `[...]`, `{...}` or `Name{...}` the second time
+type inspector struct {
+	w    io.Writer
+	seen map[Object]bool
+	err  error
+}
+
+func (in *inspector) write(s string) {
+	if in.err == nil {
+		_, in.err = io.WriteString(in.w, s)
+	}
+}
+
+func (in *inspector) inspect(obj Object) {
+	switch obj := obj.(type) {
+	case *Array:
+		if in.seen[obj] {
+			in.write("[...]")
+			return
+		}
+
+		in.seen[obj] = true
+		defer delete(in.seen, obj)
+
+		in.write("[")
+
+		for i, elem := range obj.Value {
+			if in.err != nil {
+				return
+			}
+
+			if i > 0 {
+				in.write(", ")
+			}
+
+			in.inspect(elem)
+		}
+
+		in.write("]")
+
+	case *HashMap:
+		if in.seen[obj] {
+			in.write("{...}")
+			return
+		}
+
+		in.seen[obj] = true
+		defer delete(in.seen, obj)
+
+		in.write("{")
+		first := true
+
+		for key, val := range obj.Value {
+			if in.err != nil {
+				return
+			}
+
+			if !first {
+				in.write(", ")
+			}
+
+			first = false
+			in.write(key.Value + ":")
+			in.inspect(val)
+		}
+
+		in.write("}")
+
+	case *Struct:
+		if in.seen[obj] {
+			in.write(obj.Def.Name + "{...}")
+			return
+		}
+
+		in.seen[obj] = true
+		defer delete(in.seen, obj)
+
+		in.write(obj.Def.Name + "{")
+
+		for i, field := range obj.Def.Fields {
+			if in.err != nil {
+				return
+			}
+
+			if i > 0 {
+				in.write(", ")
+			}
+
+			in.write(field + ": ")
+			in.inspect(obj.Fields[i])
+		}
+
+		in.write("}")
+
+	default:
+		in.write(obj.Inspect())
+	}
 }
 
 // ------------------------------
 // Function Object
 // ------------------------------
 type Function struct {
-	Parameters  []*ast.Identifier
+	Name        string // set by `fn name() {}` and `let name = fn() {}`, empty for anonymous functions
+	Parameters  []*ast.Parameter
 	Body        *ast.BlockStatement
 	Environment *Environment
 }
@@ -187,7 +375,13 @@ func (f *Function) Inspect() string {
 		params = append(params, val.String())
 	}
 
-	out.WriteString("fn(")
+	out.WriteString("fn")
+
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+
+	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
 	out.WriteString(f.Body.String())
@@ -199,6 +393,42 @@ func (f *Function) Type() ObjectType {
 	return OBJECT_FUNCTION
 }
 
+// Method is a method of a struct read from a value, as `p.dist`. Calling it
+// passes the value before the arguments, binding it to the first parameter
+type Method struct {
+	Receiver Object
+	Function *Function
+}
+
+func (m *Method) Inspect() string {
+	return m.Function.Inspect()
+}
+
+func (m *Method) Type() ObjectType {
+	return OBJECT_FUNCTION
+}
+
+// ------------------------------
+// Trait Object
+// ------------------------------
+
+// Trait is declared by `trait Name { methods }`. Required holds the methods
+// an implementation must provide, in order, and Defaults the methods it
+// gets when it leaves them out
+type Trait struct {
+	Name     string
+	Required []string
+	Defaults map[string]*Function
+}
+
+func (t *Trait) Inspect() string {
+	return "trait " + t.Name
+}
+
+func (t *Trait) Type() ObjectType {
+	return OBJECT_TRAIT
+}
+
 // ------------------------------
 // Return Object
 // ------------------------------
@@ -213,3 +443,28 @@ func (r *Return) Inspect() string {
 func (r *Return) Type() ObjectType {
 	return OBJECT_RETURN
 }
+
+// ------------------------------
+// Error Object
+// ------------------------------
+type Error struct {
+	Message string
+	Err     error    // the Go error behind Message, if any
+	Trace   []string // the calls the error went through, innermost first
+}
+
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("error: " + e.Message)
+
+	for _, frame := range e.Trace {
+		out.WriteString("\n\tin " + frame)
+	}
+
+	return out.String()
+}
+
+func (e *Error) Type() ObjectType {
+	return OBJECT_ERROR
+}
@@ -5,6 +5,7 @@ import (
 	"Klang/ast"
 	"Klang/lexer"
 	"Klang/token"
+	"fmt"
 	"strconv"
 )
 
@@ -36,6 +37,7 @@ var precedence = map[token.TokenType]int{
 	token.EQUAL_NOT:     COMPARE,
 	token.LPAREN:        CALL,
 	token.LBRACKET:      INDEX,
+	token.DOT:           INDEX,
 	token.ASSIGN:        ASSIGN,
 }
 
@@ -50,6 +52,16 @@ type Parser struct {
 	PeekToken    token.Token
 	prefixFunc   map[token.TokenType]prefixFunc
 	infixFunc    map[token.TokenType]infixFunc
+	errors       []*Error
+	incomplete   bool
+
+	// MaxNesting bounds how deeply statements, expressions, patterns and
+	// types may nest, 0 disabling the limit. The parser and the evaluator
+	// recurse on nesting, so untrusted sources set it to keep them off the
+	// end of the Go stack
+	MaxNesting int
+	depth      int
+	tooDeep    bool // MaxNesting was reached, the rest of the source is skipped
 }
 
 func New(lex *lexer.Lexer) *Parser {
@@ -66,6 +78,7 @@ func New(lex *lexer.Lexer) *Parser {
 	p.registerPrefixFunction(token.TRUE, p.parseBoolean)
 	p.registerPrefixFunction(token.FALSE, p.parseBoolean)
 	p.registerPrefixFunction(token.IF, p.parseIfExpression)
+	p.registerPrefixFunction(token.MATCH, p.parseMatchExpression)
 	p.registerPrefixFunction(token.IDENTIFIER, p.parseIdentifier)
 	p.registerPrefixFunction(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefixFunction(token.BANG, p.parsePrefixExpression)
@@ -88,6 +101,7 @@ func New(lex *lexer.Lexer) *Parser {
 	p.registerInfixFunction(token.EQUAL_NOT, p.parseInfixExpression)
 	p.registerInfixFunction(token.LPAREN, p.parseFunctionCall)
 	p.registerInfixFunction(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfixFunction(token.DOT, p.parseMemberExpression)
 	p.registerInfixFunction(token.ASSIGN, p.parseAssignmentExpression)
 
 	// prime the tokens
@@ -101,12 +115,79 @@ func (p *Parser) NextToken() {
 	p.PeekToken = p.Lexer.NextToken()
 }
 
+// Error is a syntax error found at Line and Column
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Errors returns the syntax errors collected while parsing
+func (p *Parser) Errors() []*Error {
+	return p.errors
+}
+
+// Incomplete reports whether parsing failed only because the source ended
+// too early, like an unclosed `{` or string. The repl uses it to ask for
+// more input instead of reporting an error
+func (p *Parser) Incomplete() bool {
+	return p.incomplete
+}
+
+// addError records a syntax error located at tok
+func (p *Parser) addError(tok token.Token, format string, args ...interface{}) {
+	// what follows a nesting error is only noise
+	if p.tooDeep {
+		return
+	}
+
+	p.errors = append(p.errors, &Error{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// nest enters one more level of nesting, failing once MaxNesting is
+// exceeded. Every successful call is paired with unnest
+func (p *Parser) nest() bool {
+	if p.tooDeep {
+		return false
+	}
+
+	if p.MaxNesting > 0 && p.depth >= p.MaxNesting {
+		p.addError(p.CurrentToken, "nesting too deep: more than %d levels", p.MaxNesting)
+		p.tooDeep = true
+		return false
+	}
+
+	p.depth++
+	return true
+}
+
+func (p *Parser) unnest() {
+	p.depth--
+}
+
+// markIncomplete flags the input as incomplete when tok shows the source ran out
+func (p *Parser) markIncomplete(tok token.Token) {
+	if tok.Type == token.EOF || isUnterminatedString(tok) {
+		p.incomplete = true
+	}
+}
+
+func isUnterminatedString(tok token.Token) bool {
+	return tok.Type == token.ILLEGAL && len(tok.Literal) > 0 && tok.Literal[0] == '"'
+}
+
 func (p *Parser) expectPeek(tokType token.TokenType) bool {
 	if p.PeekToken.Type == tokType {
 		p.NextToken()
 		return true
 	}
 
+	p.markIncomplete(p.PeekToken)
+	p.addError(p.PeekToken, "expected next token to be %s, got %s instead", tokType, p.PeekToken.Type)
 	return false
 }
 
@@ -122,7 +203,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for p.CurrentToken.Type != token.EOF {
+	for p.CurrentToken.Type != token.EOF && !p.tooDeep {
 		statement := p.parseStatement()
 
 		if statement != nil {
@@ -132,12 +213,18 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.NextToken()
 	}
 
+	program.Comments = p.Lexer.Comments()
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	if !p.nest() {
+		return nil
+	}
+	defer p.unnest()
+
 	switch p.CurrentToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 
 	case token.WHILE:
@@ -146,6 +233,23 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.RETURN:
 		return p.parseReturnStatement()
 
+	case token.STRUCT:
+		return p.parseStructStatement()
+
+	case token.IMPL:
+		return p.parseImplStatement()
+
+	case token.TRAIT:
+		return p.parseTraitStatement()
+
+	case token.FUNCTION:
+		// `fn(` starts a function literal, `fn name(` a declaration
+		if p.peekTokenIs(token.IDENTIFIER) {
+			return p.parseFunctionStatement()
+		}
+
+		return p.parseExpressionStatement()
+
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -154,11 +258,35 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseLetStatement() ast.Statement {
 	letStmt := &ast.LetStatement{Token: p.CurrentToken}
 
-	if !p.expectPeek(token.IDENTIFIER) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.NextToken() // advance to the pattern
+
+		if letStmt.Pattern = p.parseBindingPattern(); letStmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		letStmt.Name = p.parseIdentifier().(*ast.Identifier)
 	}
 
-	letStmt.Name = p.parseIdentifier().(*ast.Identifier)
+	if p.peekTokenIs(token.COLON) {
+		p.NextToken() // consume the `:`
+		p.NextToken() // advance to the type
+
+		if letStmt.Type = p.parseType(); letStmt.Type == nil {
+			return nil
+		}
+	}
+
+	// a constant can never be given a value later
+	if letStmt.Constant() && !p.peekTokenIs(token.ASSIGN) {
+		p.markIncomplete(p.PeekToken)
+		p.addError(p.PeekToken, "missing value in const declaration of %s", letStmt.Target())
+		return nil
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -174,6 +302,193 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	return letStmt
 }
 
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	fnStmt := &ast.FunctionStatement{Token: p.CurrentToken}
+
+	p.NextToken() // advance to the name
+	fnStmt.Name = p.parseIdentifier().(*ast.Identifier)
+
+	fnLit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteralExpression)
+
+	if !ok {
+		return nil
+	}
+
+	fnLit.Token = fnStmt.Token
+	fnStmt.Function = fnLit
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+	}
+
+	return fnStmt
+}
+
+// parseStructStatement parses `struct Name { field, ... }`, a comma being
+// allowed after the last field
+func (p *Parser) parseStructStatement() ast.Statement {
+	structStmt := &ast.StructStatement{Token: p.CurrentToken, Fields: []*ast.Identifier{}}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	structStmt.Name = p.parseIdentifier().(*ast.Identifier)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		field := p.parseIdentifier().(*ast.Identifier)
+
+		if seen[field.Value] {
+			p.addError(field.Token, "duplicate field %s in struct %s", field.Value, structStmt.Name.Value)
+		}
+
+		seen[field.Value] = true
+		structStmt.Fields = append(structStmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.NextToken() // consume the `}`
+	structStmt.Rbrace = p.CurrentToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+	}
+
+	return structStmt
+}
+
+// parseImplStatement parses `impl Type { methods }` and
+// `impl Trait for Type { methods }`, `for` only being a keyword here
+func (p *Parser) parseImplStatement() ast.Statement {
+	implStmt := &ast.ImplStatement{Token: p.CurrentToken}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	implStmt.Type = p.parseIdentifier().(*ast.Identifier)
+
+	if p.peekTokenIs(token.IDENTIFIER) && p.PeekToken.Literal == "for" {
+		p.NextToken() // consume the `for`
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		implStmt.Trait = implStmt.Type
+		implStmt.Type = p.parseIdentifier().(*ast.Identifier)
+	}
+
+	if implStmt.Methods = p.parseMethods(false); implStmt.Methods == nil {
+		return nil
+	}
+
+	implStmt.Rbrace = p.CurrentToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+	}
+
+	return implStmt
+}
+
+// parseTraitStatement parses `trait Name { methods }`, where a method
+// without a body, `fn name(self);`, is required from every implementation
+func (p *Parser) parseTraitStatement() ast.Statement {
+	traitStmt := &ast.TraitStatement{Token: p.CurrentToken}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	traitStmt.Name = p.parseIdentifier().(*ast.Identifier)
+
+	if traitStmt.Methods = p.parseMethods(true); traitStmt.Methods == nil {
+		return nil
+	}
+
+	traitStmt.Rbrace = p.CurrentToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+	}
+
+	return traitStmt
+}
+
+// parseMethods parses the `{ fn name(params) { body } ... }` of an impl or
+// trait, leaving the current token on the `}`. Bodies may be left out when
+// bodiless is set
+func (p *Parser) parseMethods(bodiless bool) []*ast.FunctionStatement {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	methods := []*ast.FunctionStatement{}
+	seen := make(map[string]bool)
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.EOF) {
+			p.markIncomplete(p.PeekToken)
+		}
+
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+
+		method := &ast.FunctionStatement{Token: p.CurrentToken}
+
+		if !p.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		method.Name = p.parseIdentifier().(*ast.Identifier)
+
+		fnLit := p.parseFunctionSignature()
+
+		if fnLit == nil {
+			return nil
+		}
+
+		if !bodiless || p.peekTokenIs(token.LBRACE) {
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+
+			fnLit.Body = p.parseBlockStatement().(*ast.BlockStatement)
+		}
+
+		if seen[method.Name.Value] {
+			p.addError(method.Name.Token, "duplicate method %s", method.Name.Value)
+		}
+
+		seen[method.Name.Value] = true
+		fnLit.Token = method.Token
+		method.Function = fnLit
+		methods = append(methods, method)
+
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.NextToken()
+		}
+	}
+
+	p.NextToken() // consume the `}`
+	return methods
+}
+
 func (p *Parser) parseReturnStatement() ast.Statement {
 	returnStmt := &ast.ReturnStatement{Token: p.CurrentToken}
 
@@ -204,9 +519,16 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	if !p.nest() {
+		return nil
+	}
+	defer p.unnest()
+
 	prefix := p.getPrefixFunction(p.CurrentToken)
 
 	if prefix == nil {
+		p.markIncomplete(p.CurrentToken)
+		p.addError(p.CurrentToken, "unexpected token %s `%s`", p.CurrentToken.Type, p.CurrentToken.Literal)
 		return nil
 	}
 
@@ -219,7 +541,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// pratt parser
 	// keep parsing, if next token is more important than the current one
 	// else process what we have so far
-	for p.peekTokenPrecedence() > precedence {
+	for p.peekTokenPrecedence() > precedence && !p.tooDeep {
 		infix := p.getInfixFunction(p.PeekToken)
 
 		if infix == nil {
@@ -279,6 +601,15 @@ func (p *Parser) registerInfixFunction(tokType token.TokenType, fn infixFunc) {
 	p.infixFunc[tokType] = fn
 }
 
+// Precedence returns the binding power of an infix operator, LOWEST for any other token
+func Precedence(tokType token.TokenType) int {
+	if prec, ok := precedence[tokType]; ok {
+		return prec
+	}
+
+	return LOWEST
+}
+
 func (p *Parser) peekTokenPrecedence() int {
 	if prec, ok := precedence[p.PeekToken.Type]; ok {
 		return prec
@@ -333,6 +664,31 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.NextToken() // consume the `else` token
 
+		// `else if` makes the next if the only statement of the else arm
+		if p.peekTokenIs(token.IF) {
+			p.NextToken() // advance to the `if`
+			tok := p.CurrentToken
+			next, ok := p.parseIfExpression().(*ast.IfExpression)
+
+			if !ok {
+				return nil
+			}
+
+			rbrace := next.IfArm.Rbrace
+
+			if next.ElseArm != nil {
+				rbrace = next.ElseArm.Rbrace
+			}
+
+			ifExpr.ElseArm = &ast.BlockStatement{
+				Token:      tok,
+				Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: next}},
+				Rbrace:     rbrace,
+			}
+
+			return ifExpr
+		}
+
 		// consume the `{`
 		if !p.expectPeek(token.LBRACE) {
 			return nil
@@ -344,6 +700,257 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return ifExpr
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	match := &ast.MatchExpression{Token: p.CurrentToken}
+
+	p.NextToken() // advance to the value
+	match.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.NextToken() // advance to the pattern
+		arm := p.parseMatchArm()
+
+		if arm == nil {
+			return nil
+		}
+
+		match.Arms = append(match.Arms, arm)
+
+		// arms are separated by commas, which a body in braces may leave out
+		if p.peekTokenIs(token.COMMA) {
+			p.NextToken()
+		} else if _, ok := arm.Body.(*ast.BlockStatement); !ok && !p.peekTokenIs(token.RBRACE) {
+			p.markIncomplete(p.PeekToken)
+			p.addError(p.PeekToken, "expected `,` or `}` after a match arm, got %s instead", p.PeekToken.Type)
+			return nil
+		}
+	}
+
+	p.NextToken() // consume the `}`
+	match.Rbrace = p.CurrentToken
+	return match
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.NextToken() // consume the `if`
+		p.NextToken() // advance to the guard
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.FAT_ARROW) {
+		return nil
+	}
+
+	arm.Token = p.CurrentToken
+	p.NextToken() // advance to the body
+
+	// a `{` starts a block, a hashmap result goes in parentheses
+	if p.currentTokenIs(token.LBRACE) {
+		arm.Body = p.parseBlockStatement()
+	} else {
+		arm.Body = p.parseExpression(LOWEST)
+	}
+
+	if arm.Body == nil {
+		return nil
+	}
+
+	arm.End = p.CurrentToken
+	return arm
+}
+
+// parsePattern parses the pattern starting at the current token, with its
+// alternatives
+func (p *Parser) parsePattern() ast.Pattern {
+	pattern := p.parseSinglePattern()
+
+	if pattern == nil || !p.peekTokenIs(token.PIPE) {
+		return pattern
+	}
+
+	alternative := &ast.AlternativePattern{Alternatives: []ast.Pattern{pattern}}
+
+	for p.peekTokenIs(token.PIPE) {
+		p.NextToken() // consume the `|`
+		p.NextToken() // advance to the next alternative
+
+		if pattern = p.parseSinglePattern(); pattern == nil {
+			return nil
+		}
+
+		alternative.Alternatives = append(alternative.Alternatives, pattern)
+	}
+
+	// no alternative could tell which of its names are bound
+	for _, alt := range alternative.Alternatives {
+		ast.Inspect(alt, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Identifier); ok && ident.Value != "_" {
+				p.addError(ident.Token, "alternative patterns cannot bind names, got %s", ident.Value)
+			}
+
+			return true
+		})
+	}
+
+	return alternative
+}
+
+// parseBindingPattern parses the pattern of a destructuring `let` or
+// parameter. It only holds names, arrays and hashmaps: a value failing to
+// match anything else would have nowhere to go
+func (p *Parser) parseBindingPattern() ast.Pattern {
+	pattern := p.parseSinglePattern()
+
+	if pattern == nil {
+		return nil
+	}
+
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LiteralPattern, *ast.AlternativePattern:
+			p.addError(ast.StartToken(node), "a destructuring pattern can only hold names, arrays and hashmaps, got %s", node)
+			return false
+		}
+
+		return true
+	})
+
+	return pattern
+}
+
+func (p *Parser) parseSinglePattern() ast.Pattern {
+	if !p.nest() {
+		return nil
+	}
+	defer p.unnest()
+
+	switch p.CurrentToken.Type {
+	case token.IDENTIFIER:
+		return p.parseIdentifier().(*ast.Identifier)
+
+	case token.INTEGER, token.FLOATING, token.STRING, token.TRUE, token.FALSE:
+		return &ast.LiteralPattern{Value: p.getPrefixFunction(p.CurrentToken)()}
+
+	case token.MINUS:
+		minus := p.CurrentToken
+
+		if !p.peekTokenIs(token.INTEGER) && !p.peekTokenIs(token.FLOATING) {
+			p.markIncomplete(p.PeekToken)
+			p.addError(p.PeekToken, "expected a number after `-` in a pattern, got %s instead", p.PeekToken.Type)
+			return nil
+		}
+
+		p.NextToken() // advance to the number
+		number := p.getPrefixFunction(p.CurrentToken)()
+		return &ast.LiteralPattern{Value: &ast.PrefixExpression{Token: minus, Operator: minus.Literal, Right: number}}
+
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+
+	case token.LBRACE:
+		return p.parseHashPattern()
+
+	default:
+		p.markIncomplete(p.CurrentToken)
+		p.addError(p.CurrentToken, "expected a pattern, got %s instead", p.CurrentToken.Type)
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	array := &ast.ArrayPattern{Token: p.CurrentToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.NextToken() // advance to the element
+
+		if p.currentTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENTIFIER) {
+				return nil
+			}
+
+			array.Rest = p.parseIdentifier().(*ast.Identifier)
+
+			if !p.peekTokenIs(token.RBRACKET) {
+				p.markIncomplete(p.PeekToken)
+				p.addError(p.PeekToken, "the rest of an array pattern must come last, got %s after it", p.PeekToken.Type)
+				return nil
+			}
+
+			break
+		}
+
+		elem := p.parsePattern()
+
+		if elem == nil {
+			return nil
+		}
+
+		array.Elements = append(array.Elements, elem)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.NextToken() // consume the `]`
+	return array
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	hash := &ast.HashPattern{Token: p.CurrentToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.NextToken() // advance to the key
+
+		switch p.CurrentToken.Type {
+		case token.IDENTIFIER:
+			// a lone name matches the key spelled like it
+			name := p.parseIdentifier().(*ast.Identifier)
+			hash.Keys = append(hash.Keys, &ast.StringLiteralExpression{Token: name.Token, Value: name.Value})
+			hash.Values = append(hash.Values, name)
+
+		case token.STRING, token.INTEGER, token.TRUE, token.FALSE:
+			hash.Keys = append(hash.Keys, p.getPrefixFunction(p.CurrentToken)())
+
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			p.NextToken() // advance to the pattern
+			value := p.parsePattern()
+
+			if value == nil {
+				return nil
+			}
+
+			hash.Values = append(hash.Values, value)
+
+		default:
+			p.markIncomplete(p.CurrentToken)
+			p.addError(p.CurrentToken, "expected a key or a name in a hashmap pattern, got %s instead", p.CurrentToken.Type)
+			return nil
+		}
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.NextToken() // consume the `}`
+	return hash
+}
+
 func (p *Parser) parseBlockStatement() ast.Expression {
 	block := &ast.BlockStatement{Token: p.CurrentToken}
 	block.Statements = []ast.Statement{}
@@ -360,6 +967,12 @@ func (p *Parser) parseBlockStatement() ast.Expression {
 		p.NextToken()
 	}
 
+	if p.currentTokenIs(token.EOF) {
+		p.markIncomplete(p.CurrentToken)
+		p.addError(p.CurrentToken, "expected `}` to close the block, got EOF instead")
+	}
+
+	block.Rbrace = p.CurrentToken
 	return block
 }
 
@@ -380,6 +993,24 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
+	fnLit := p.parseFunctionSignature()
+
+	if fnLit == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	fnLit.Body = p.parseBlockStatement().(*ast.BlockStatement)
+
+	return fnLit
+}
+
+// parseFunctionSignature parses the parameters and return type of a
+// function, stopping before its body
+func (p *Parser) parseFunctionSignature() *ast.FunctionLiteralExpression {
 	fnLit := &ast.FunctionLiteralExpression{Token: p.CurrentToken}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -390,30 +1021,45 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 
 	fnLit.Parameters = p.parseFunctionParameters()
 
-	if !p.expectPeek(token.LBRACE) {
+	if fnLit.Parameters == nil {
 		return nil
 	}
 
-	fnLit.Body = p.parseBlockStatement().(*ast.BlockStatement)
+	if p.peekTokenIs(token.ARROW) {
+		p.NextToken() // consume the `->`
+		p.NextToken() // advance to the type
+
+		if fnLit.ReturnType = p.parseType(); fnLit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	return fnLit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	params := []*ast.Identifier{}
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
 
 	if p.currentTokenIs(token.RPAREN) {
 		return params
 	}
 
-	param := &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+	param := p.parseParameter()
+
+	if param == nil {
+		return nil
+	}
+
 	params = append(params, param)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.NextToken() // consume the `,` token
 		p.NextToken() // advance to next expression in the list of expression
 
-		param = &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+		if param = p.parseParameter(); param == nil {
+			return nil
+		}
+
 		params = append(params, param)
 	}
 
@@ -421,35 +1067,209 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return nil
 	}
 
+	// parameters with a default follow the required ones, the rest parameter comes last
+	for i, param := range params {
+		switch {
+		case param.Rest && i != len(params)-1:
+			p.addError(param.Name.Token, "rest parameter %s must be the last parameter", param.Name.Value)
+
+		case param.Default == nil && !param.Rest && i > 0 && params[i-1].Default != nil:
+			p.addError(ast.StartToken(param), "parameter %s without a default value follows one with a default value", param.Target())
+		}
+	}
+
 	return params
 }
 
+// parseParameter parses `name`, `name: type`, `name = default` or `...name`,
+// the name being an array or hashmap pattern for a destructured argument
+func (p *Parser) parseParameter() *ast.Parameter {
+	param := &ast.Parameter{}
+
+	if p.currentTokenIs(token.ELLIPSIS) {
+		param.Rest = true
+		p.NextToken() // advance to the name
+	}
+
+	switch {
+	case p.currentTokenIs(token.IDENTIFIER):
+		param.Name = &ast.Identifier{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
+
+	case !param.Rest && (p.currentTokenIs(token.LBRACKET) || p.currentTokenIs(token.LBRACE)):
+		if param.Pattern = p.parseBindingPattern(); param.Pattern == nil {
+			return nil
+		}
+
+	default:
+		p.markIncomplete(p.CurrentToken)
+		p.addError(p.CurrentToken, "expected a parameter name, got %s `%s` instead", p.CurrentToken.Type, p.CurrentToken.Literal)
+		return nil
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.NextToken() // consume the `:`
+		p.NextToken() // advance to the type
+
+		if param.Type = p.parseType(); param.Type == nil {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(token.ASSIGN) {
+		p.NextToken() // consume the `=`
+
+		if param.Rest {
+			p.addError(p.CurrentToken, "rest parameter %s cannot have a default value", param.Name.Value)
+			return nil
+		}
+
+		p.NextToken() // advance to the default value
+
+		// ASSIGN so that `=` is not parsed as an assignment inside the default
+		if param.Default = p.parseExpression(ASSIGN); param.Default == nil {
+			return nil
+		}
+	}
+
+	return param
+}
+
+// parseType parses a type annotation starting at the current token:
+// `name`, `[T]`, `{K: V}` or `fn(T, U) -> R`
+func (p *Parser) parseType() *ast.TypeAnnotation {
+	if !p.nest() {
+		return nil
+	}
+	defer p.unnest()
+
+	typ := &ast.TypeAnnotation{Token: p.CurrentToken}
+
+	switch p.CurrentToken.Type {
+	case token.IDENTIFIER:
+		typ.Name = p.CurrentToken.Literal
+
+	case token.LBRACKET:
+		p.NextToken() // advance to the element type
+
+		if typ.Elem = p.parseType(); typ.Elem == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+
+	case token.LBRACE:
+		p.NextToken() // advance to the key type
+
+		if typ.Key = p.parseType(); typ.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.NextToken() // advance to the value type
+
+		if typ.Elem = p.parseType(); typ.Elem == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+
+	case token.FUNCTION:
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		typ.Params = []*ast.TypeAnnotation{}
+
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(typ.Params) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+
+			if typ.Variadic {
+				p.addError(p.PeekToken, "the variadic parameter must be the last parameter")
+				return nil
+			}
+
+			p.NextToken() // advance to the parameter type
+
+			if p.currentTokenIs(token.ELLIPSIS) {
+				typ.Variadic = true
+				p.NextToken() // advance to the element type
+			}
+
+			param := p.parseType()
+
+			if param == nil {
+				return nil
+			}
+
+			typ.Params = append(typ.Params, param)
+		}
+
+		p.NextToken() // consume the `)`
+
+		if p.peekTokenIs(token.ARROW) {
+			p.NextToken() // consume the `->`
+			p.NextToken() // advance to the result type
+
+			if typ.Return = p.parseType(); typ.Return == nil {
+				return nil
+			}
+		}
+
+	default:
+		p.markIncomplete(p.CurrentToken)
+		p.addError(p.CurrentToken, "expected a type, got %s `%s` instead", p.CurrentToken.Type, p.CurrentToken.Literal)
+		return nil
+	}
+
+	return typ
+}
+
 func (p *Parser) parseFunctionCall(left ast.Expression) ast.Expression {
 	fnCall := &ast.FunctionCallExpression{Token: p.CurrentToken, Function: left}
 
 	p.NextToken() // advance to args list
 
-	fnCall.Args = p.parseExpressionList(token.RPAREN)
+	fnCall.Args = p.parseList(token.RPAREN, p.parseArgument)
 
 	return fnCall
 }
 
+// parseArgument parses an argument of a call, `...array` spreading the
+// elements of array as arguments
+func (p *Parser) parseArgument(precedence int) ast.Expression {
+	if !p.currentTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(precedence)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.CurrentToken}
+	p.NextToken() // advance to the array
+
+	if spread.Value = p.parseExpression(precedence); spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) *ast.ExpressionList {
+	return p.parseList(end, p.parseExpression)
+}
+
+// parseList parses the comma separated elements up to end with parseElement
+func (p *Parser) parseList(end token.TokenType, parseElement func(int) ast.Expression) *ast.ExpressionList {
 	exprList := &ast.ExpressionList{Token: p.CurrentToken}
 	exprList.List = []ast.Expression{}
 
 	if p.currentTokenIs(end) {
+		exprList.End = p.CurrentToken
 		return exprList
 	}
 
-	args := p.parseExpression(LOWEST)
+	args := parseElement(LOWEST)
 	exprList.List = append(exprList.List, args)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.NextToken() // consume the `,` token
 		p.NextToken() // advance to next expression in the list of expression
 
-		args := p.parseExpression(LOWEST)
+		args := parseElement(LOWEST)
 		exprList.List = append(exprList.List, args)
 	}
 
@@ -457,6 +1277,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) *ast.ExpressionList {
 		return nil
 	}
 
+	exprList.End = p.CurrentToken
 	return exprList
 }
 
@@ -481,6 +1302,18 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return arrIndex
 }
 
+// parseMemberExpression parses `left.member`
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	member := &ast.MemberExpression{Token: p.CurrentToken, Object: left}
+
+	if !p.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	member.Member = p.parseIdentifier().(*ast.Identifier)
+	return member
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteralExpression{Token: p.CurrentToken, Value: p.CurrentToken.Literal}
 }
@@ -490,27 +1323,31 @@ func (p *Parser) parseHashMap() ast.Expression {
 
 	p.NextToken() // advance to expression
 
-	hashMap.Map = p.parseHashmapExpressionList()
+	hashMap.Map, hashMap.Keys = p.parseHashmapExpressionList()
+	hashMap.Rbrace = p.CurrentToken
 	return hashMap
 }
 
-func (p *Parser) parseHashmapExpressionList() map[ast.Expression]ast.Expression {
+// parseHashmapExpressionList returns the pairs of the hashmap along with its keys in source order
+func (p *Parser) parseHashmapExpressionList() (map[ast.Expression]ast.Expression, []ast.Expression) {
 	hashMap := map[ast.Expression]ast.Expression{}
+	keys := []ast.Expression{}
 
 	if p.currentTokenIs(token.RBRACE) {
-		return hashMap
+		return hashMap, keys
 	}
 
 	key := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.COLON) {
-		return nil
+		return nil, nil
 	}
 
 	p.NextToken() // advance to next expression
 	val := p.parseExpression(LOWEST)
 
 	hashMap[key] = val
+	keys = append(keys, key)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.NextToken() // consume the `,`
@@ -519,20 +1356,21 @@ func (p *Parser) parseHashmapExpressionList() map[ast.Expression]ast.Expression
 		key = p.parseExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return nil, nil
 		}
 
 		p.NextToken() //advance to next expression
 
 		val = p.parseExpression(LOWEST)
 		hashMap[key] = val
+		keys = append(keys, key)
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return nil, nil
 	}
 
-	return hashMap
+	return hashMap, keys
 }
 
 func (p *Parser) parseGrouping() ast.Expression {
@@ -551,9 +1389,47 @@ func (p *Parser) parseGrouping() ast.Expression {
 }
 
 func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
-	assExpr := &ast.AssignmentExpression{Token: p.CurrentToken, Ident: left.(*ast.Identifier)}
+	if index, ok := left.(*ast.IndexExpression); ok {
+		assExpr := &ast.IndexAssignmentExpression{Token: p.CurrentToken, Target: index}
+		p.NextToken() // advance to the expression
+
+		if assExpr.Value = p.parseExpression(LOWEST); assExpr.Value == nil {
+			return nil
+		}
+
+		return assExpr
+	}
+
+	if member, ok := left.(*ast.MemberExpression); ok {
+		assExpr := &ast.MemberAssignmentExpression{Token: p.CurrentToken, Target: member}
+		p.NextToken() // advance to the expression
+
+		if assExpr.Value = p.parseExpression(LOWEST); assExpr.Value == nil {
+			return nil
+		}
+
+		return assExpr
+	}
+
+	ident, ok := left.(*ast.Identifier)
+
+	if !ok {
+		// left may be missing operands after an earlier error, so it is
+		// only located, not printed
+		if left != nil {
+			p.addError(ast.StartToken(left), "invalid assignment target")
+		}
+
+		return nil
+	}
+
+	assExpr := &ast.AssignmentExpression{Token: p.CurrentToken, Ident: ident}
 	p.NextToken() // advance to the expression
 
-	assExpr.Value = p.parseExpression(LOWEST)
+	// a missing value is already reported
+	if assExpr.Value = p.parseExpression(LOWEST); assExpr.Value == nil {
+		return nil
+	}
+
 	return assExpr
 }
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"Klang/lexer"
+	"testing"
+)
+
+func TestAssignment(t *testing.T) {
+	for _, input := range []string{"x = 1", "a[0] = 2", "p.x = 3", "a[i].b = c = 4"} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%q: unexpected parse error: %s", input, p.Errors()[0])
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got=%d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the error about the target
+	}{
+		{"1 = 2", "1:1: invalid assignment target"},
+		{"f() = 1", "1:1: invalid assignment target"},
+		{"(-) = 2", "1:2: invalid assignment target"},
+		{"a[!] = 2", "1:3: invalid assignment target"},
+		{"a[0+] = 2", "1:3: invalid assignment target"},
+		{"let [a, {b}!] = [1]", "1:12: invalid assignment target"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		found := false
+
+		for _, err := range p.Errors() {
+			found = found || err.Error() == tt.expected
+		}
+
+		if !found {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+// TestTruncatedAssignments parses every prefix of valid programs followed by
+// an assignment, so that half parsed nodes end up as assignment targets
+func TestTruncatedAssignments(t *testing.T) {
+	samples := []string{
+		`let [a, {b, "c": c}] = [1, {"b": 2, "c": 3}];`,
+		`fn f(x: int, ...rest) -> int { if x > 0 { -x } else { !x } }`,
+		`match h[0+1].k(1, 2) { [x, ...t] if x => x, _ => (1 * 2) }`,
+		`struct P { x } impl P { fn get(self) { self.x } } P(1).get()`,
+	}
+
+	for _, sample := range samples {
+		for i := 0; i <= len(sample); i++ {
+			input := sample[:i] + " = 2"
+			p := New(lexer.New(input))
+
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%q: parser panicked: %v", input, r)
+					}
+				}()
+
+				p.ParseProgram()
+			}()
+		}
+	}
+}
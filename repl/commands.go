@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"Klang/ast"
+	"Klang/lexer"
+	"Klang/object"
+	"Klang/token"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	// assigned in init since :help lists the commands table itself
+	commands = map[string]command{
+		"env":    {":env", "list the bindings of the current environment", (*session).commandEnv},
+		"ast":    {":ast <expr>", "print the syntax tree of expr", (*session).commandAst},
+		"tokens": {":tokens <expr>", "print the tokens of expr", (*session).commandTokens},
+		"load":   {":load <file.mk>", "evaluate a file in the current environment", (*session).commandLoad},
+		"reset":  {":reset", "discard every binding", (*session).commandReset},
+		"time":   {":time <expr>", "evaluate expr and report how long it took", (*session).commandTime},
+		"type":   {":type <expr>", "evaluate expr and print the type of its value", (*session).commandType},
+		"help":   {":help", "show this help", (*session).commandHelp},
+		"quit":   {":quit", "leave the repl", nil},
+	}
+}
+
+// runCommand executes a `:name arg` meta command. It returns true when the repl should stop
+func (s *session) runCommand(input string) bool {
+	name, arg := input[1:], ""
+
+	if idx := strings.IndexAny(name, " \t"); idx >= 0 {
+		name, arg = name[:idx], strings.TrimSpace(name[idx+1:])
+	}
+
+	if name == "quit" || name == "q" {
+		return true
+	}
+
+	cmd, ok := commands[name]
+
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for the list of commands\n", name)
+		return false
+	}
+
+	cmd.run(s, arg)
+	return false
+}
+
+func (s *session) commandEnv(arg string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Lookup(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, format(val))
+	}
+}
+
+func (s *session) commandAst(arg string) {
+	if program, ok := s.parse(arg); ok {
+		ast.Fprint(s.out, program)
+	}
+}
+
+func (s *session) commandTokens(arg string) {
+	l := lexer.New(arg)
+
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-14s %s\n", tok.Type, tok.Literal)
+
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+func (s *session) commandLoad(arg string) {
+	content, err := os.ReadFile(arg)
+
+	if err != nil {
+		fmt.Fprintf(s.out, "load: %s\n", err)
+		return
+	}
+
+	if program, ok := s.parse(string(content)); ok {
+		s.reportError(s.evaluator.Eval(program, s.env))
+	}
+}
+
+func (s *session) commandReset(arg string) {
+	s.env = newEnvironment()
+}
+
+func (s *session) commandTime(arg string) {
+	start := time.Now()
+
+	if _, ok := s.eval(arg); ok {
+		fmt.Fprintf(s.out, "took %s\n", time.Since(start))
+	}
+}
+
+func (s *session) commandType(arg string) {
+	program, ok := s.parse(arg)
+
+	if !ok {
+		return
+	}
+
+	evaluated := s.evaluator.Eval(program, s.env)
+
+	if s.reportError(evaluated) {
+		return
+	}
+
+	fmt.Fprintln(s.out, typeName(evaluated))
+}
+
+func (s *session) commandHelp(arg string) {
+	names := []string{}
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %-18s %s\n", commands[name].usage, commands[name].help)
+	}
+}
+
+// typeName is the short lower case name of the type of obj, `integer` for OBJECT_INTEGER
+func typeName(obj object.Object) string {
+	return strings.ToLower(strings.TrimPrefix(string(obj.Type()), "OBJECT_"))
+}
@@ -0,0 +1,85 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":type 1\n:type 1.5\n:type \"a\"\n:type [1]\n:type {}\n", "integer\nfloat\nstring\narray\nhashmap\n"},
+		{":type fn() {}\n:type len\n:type true\n", "function\nbuiltin\nboolean\n"},
+		{"let x = \"s\"\n:type x\n", "string\n"},
+		{":type 1 + \"a\"\n", "error: type mismatch: OBJECT_INTEGER + OBJECT_STRING\n"},
+		{":type let\n", "parse error: 1:4: expected next token to be IDENTIFIER, got EOF instead\n"},
+		{"let b = 2\nlet a = [\"x\", {\"k\": 1}]\n:env\n", "a = [\"x\", {\"k\": 1}]\nb = 2\n"},
+		{"fn double(n) { n * 2 }\n:env\n", "double = fn double(n) {\n\tn * 2;\n}\n"},
+		{"let f = fn(a, b = 1) { if a { b } }\n:env\n", "f = fn f(a, b = 1) {\n\tif a {\n\t\tb;\n\t}\n}\n"},
+		{"struct P { x }\nimpl P { fn get(self) { self.x } }\nP(1).get\n", "fn P.get(self) {\n\tself.x;\n}\n"},
+		{"fn(x) { x }\n", "fn(x) {\n\tx;\n}\n"},
+		{"let x = 1\n2\n:reset\n:env\nx\n", "2\nundefined identifier: x\n"},
+		{"let x = 1\n:reset\nlet x = \"again\"\nx\n", "\"again\"\n"},
+		{":nope\n1\n", "unknown command :nope, type :help for the list of commands\n1\n"},
+		{":\n", "unknown command :, type :help for the list of commands\n"},
+		{":tokens 1\n", "INTEGER        1\nEOF            EOF\n"},
+		{":q\n1\n", ""},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%q: output is not matching expected.\nwant=%q\ngot=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	output := run(":help\n")
+
+	for name, cmd := range commands {
+		if !strings.Contains(output, cmd.usage) || !strings.Contains(output, cmd.help) {
+			t.Errorf(":help does not describe :%s, got=%q", name, output)
+		}
+	}
+}
+
+func TestCommandLoad(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	failing := filepath.Join(dir, "failing.mk")
+
+	files := map[string]string{
+		lib:     "let greeting = \"hello\";\nfn greet(who) { greeting + \" \" + who }\n",
+		broken:  "let = 1",
+		failing: "let before = 1; 1 + \"a\"; let after = 2",
+	}
+
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":load " + lib + "\ngreet(\"k\")\n", "\"hello k\"\n"},
+		// loading again replaces the definitions
+		{":load " + lib + "\n:load " + lib + "\ngreeting\n", "\"hello\"\n"},
+		{":load " + broken + "\n", "parse error: 1:5: expected next token to be IDENTIFIER, got ASSIGN instead\nparse error: 1:5: unexpected token ASSIGN `=`\n"},
+		{":load " + failing + "\nbefore\nafter\n", "error: type mismatch: OBJECT_INTEGER + OBJECT_STRING\n1\nundefined identifier: after\n"},
+		{":load " + filepath.Join(dir, "missing.mk") + "\n", "load: open " + filepath.Join(dir, "missing.mk") + ": no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%q: output is not matching expected.\nwant=%q\ngot=%q", tt.input, tt.expected, output)
+		}
+	}
+}
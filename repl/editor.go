@@ -0,0 +1,363 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// errInterrupt is returned by ReadLine when the user presses ctrl-c
+var errInterrupt = errors.New("interrupt")
+
+// key codes understood by the editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// newlineMark stands for the line breaks of an entry recalled from the
+// history, a single column wide
+const newlineMark = "↵"
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(line string)
+	Close() error
+}
+
+// plainReader reads lines without any editing, used when the input is not a terminal
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (pr *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(pr.out, prompt)
+
+	if !pr.scanner.Scan() {
+		if err := pr.scanner.Err(); err != nil {
+			return "", err
+		}
+
+		return "", io.EOF
+	}
+
+	return pr.scanner.Text(), nil
+}
+
+func (pr *plainReader) AddHistory(line string) {}
+
+func (pr *plainReader) Close() error {
+	return nil
+}
+
+// editor is a small readline: cursor movement, history and tab completion
+// on a terminal in raw mode
+type editor struct {
+	fd       int
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(prefix string) []string
+
+	buf       []rune
+	pos       int
+	prompt    string
+	histIndex int
+	pending   string // the line being edited while browsing the history
+}
+
+func newEditor(in *os.File, out io.Writer, hist *history, complete func(string) []string) *editor {
+	return &editor{
+		fd:       int(in.Fd()),
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  hist,
+		complete: complete,
+	}
+}
+
+func (ed *editor) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(ed.fd)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer restoreTerm(ed.fd, state)
+
+	ed.buf = ed.buf[:0]
+	ed.pos = 0
+	ed.prompt = prompt
+	ed.histIndex = len(ed.history.entries)
+	ed.pending = ""
+	ed.refresh()
+
+	for {
+		r, _, err := ed.in.ReadRune()
+
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(ed.buf), nil
+
+		case keyCtrlC:
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupt
+
+		case keyCtrlD:
+			if len(ed.buf) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+
+			ed.delete()
+
+		case keyBackspace, keyCtrlH:
+			if ed.pos > 0 {
+				ed.pos--
+				ed.delete()
+			}
+
+		case keyCtrlA:
+			ed.pos = 0
+
+		case keyCtrlE:
+			ed.pos = len(ed.buf)
+
+		case keyCtrlB:
+			ed.left()
+
+		case keyCtrlF:
+			ed.right()
+
+		case keyCtrlK:
+			ed.buf = ed.buf[:ed.pos]
+
+		case keyCtrlU:
+			ed.buf = ed.buf[ed.pos:]
+			ed.pos = 0
+
+		case keyCtrlL:
+			fmt.Fprint(ed.out, "\x1b[H\x1b[2J")
+
+		case keyCtrlP:
+			ed.historyPrev()
+
+		case keyCtrlN:
+			ed.historyNext()
+
+		case keyTab:
+			ed.completeWord()
+
+		case keyEscape:
+			ed.escapeSequence()
+
+		default:
+			if r >= ' ' {
+				ed.insert(r)
+			}
+		}
+
+		ed.refresh()
+	}
+}
+
+// escapeSequence handles the arrow, home, end and delete keys
+func (ed *editor) escapeSequence() {
+	first, _, err := ed.in.ReadRune()
+
+	if err != nil || (first != '[' && first != 'O') {
+		return
+	}
+
+	key, _, err := ed.in.ReadRune()
+
+	if err != nil {
+		return
+	}
+
+	switch key {
+	case 'A':
+		ed.historyPrev()
+
+	case 'B':
+		ed.historyNext()
+
+	case 'C':
+		ed.right()
+
+	case 'D':
+		ed.left()
+
+	case 'H':
+		ed.pos = 0
+
+	case 'F':
+		ed.pos = len(ed.buf)
+
+	case '1', '3', '4', '7', '8':
+		// `ESC [ n ~` sequences
+		if tilde, _, err := ed.in.ReadRune(); err != nil || tilde != '~' {
+			return
+		}
+
+		switch key {
+		case '1', '7':
+			ed.pos = 0
+
+		case '4', '8':
+			ed.pos = len(ed.buf)
+
+		case '3':
+			ed.delete()
+		}
+	}
+}
+
+func (ed *editor) insert(r rune) {
+	ed.buf = append(ed.buf, 0)
+	copy(ed.buf[ed.pos+1:], ed.buf[ed.pos:])
+	ed.buf[ed.pos] = r
+	ed.pos++
+}
+
+// delete removes the rune under the cursor
+func (ed *editor) delete() {
+	if ed.pos < len(ed.buf) {
+		ed.buf = append(ed.buf[:ed.pos], ed.buf[ed.pos+1:]...)
+	}
+}
+
+func (ed *editor) left() {
+	if ed.pos > 0 {
+		ed.pos--
+	}
+}
+
+func (ed *editor) right() {
+	if ed.pos < len(ed.buf) {
+		ed.pos++
+	}
+}
+
+func (ed *editor) historyPrev() {
+	if ed.histIndex == 0 {
+		return
+	}
+
+	if ed.histIndex == len(ed.history.entries) {
+		ed.pending = string(ed.buf)
+	}
+
+	ed.histIndex--
+	ed.setLine(ed.history.entries[ed.histIndex])
+}
+
+func (ed *editor) historyNext() {
+	if ed.histIndex >= len(ed.history.entries) {
+		return
+	}
+
+	ed.histIndex++
+
+	if ed.histIndex == len(ed.history.entries) {
+		ed.setLine(ed.pending)
+		return
+	}
+
+	ed.setLine(ed.history.entries[ed.histIndex])
+}
+
+func (ed *editor) setLine(line string) {
+	ed.buf = []rune(line)
+	ed.pos = len(ed.buf)
+}
+
+// completeWord completes the identifier before the cursor, or the member
+// after `name.`. A single match is inserted, several matches are listed
+// below the prompt after inserting their common prefix
+func (ed *editor) completeWord() {
+	start := ed.pos
+
+	for start > 0 && (isWordRune(ed.buf[start-1]) || ed.buf[start-1] == '.') {
+		start--
+	}
+
+	prefix := string(ed.buf[start:ed.pos])
+	candidates := ed.complete(prefix)
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	common := commonPrefix(candidates)
+
+	for _, r := range common[len(prefix):] {
+		ed.insert(r)
+	}
+
+	if len(candidates) > 1 && common == prefix {
+		fmt.Fprintf(ed.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func (ed *editor) refresh() {
+	cursor := len([]rune(ed.prompt)) + ed.pos
+
+	// an entry recalled from the history may span several lines, they are
+	// shown on one so that the cursor stays where it is counted
+	line := strings.ReplaceAll(string(ed.buf), "\n", newlineMark)
+
+	fmt.Fprintf(ed.out, "\r%s%s\x1b[K\r", ed.prompt, line)
+
+	if cursor > 0 {
+		fmt.Fprintf(ed.out, "\x1b[%dC", cursor)
+	}
+}
+
+func (ed *editor) AddHistory(line string) {
+	ed.history.add(line)
+}
+
+func (ed *editor) Close() error {
+	return ed.history.save()
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func commonPrefix(words []string) string {
+	sort.Strings(words)
+	first, last := words[0], words[len(words)-1]
+
+	i := 0
+	for i < len(first) && i < len(last) && first[i] == last[i] {
+		i++
+	}
+
+	return first[:i]
+}
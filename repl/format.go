@@ -0,0 +1,120 @@
+package repl
+
+import (
+	"Klang/ast"
+	source "Klang/format"
+	"Klang/object"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// format renders obj for the repl. Unlike Inspect, strings are quoted, also
+// when nested in arrays, hashmaps and structs, hashmap keys are sorted and
+// functions are printed as formatted source
+func format(obj object.Object) string {
+	return formatNested(obj, make(map[object.Object]bool))
+}
+
+// formatNested renders obj nested in the arrays, hashmaps and structs of
+// seen, printing a value nested in itself as `[...]`, `{...}` or `Name{...}`
+func formatNested(obj object.Object, seen map[object.Object]bool) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+
+	case *object.Array:
+		if seen[obj] {
+			return "[...]"
+		}
+
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elems := []string{}
+
+		for _, elem := range obj.Value {
+			elems = append(elems, formatNested(elem, seen))
+		}
+
+		return "[" + strings.Join(elems, ", ") + "]"
+
+	case *object.HashMap:
+		if seen[obj] {
+			return "{...}"
+		}
+
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		keys := []object.Hash{}
+
+		for key := range obj.Value {
+			keys = append(keys, key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Type != keys[j].Type {
+				return keys[i].Type < keys[j].Type
+			}
+
+			return keys[i].Value < keys[j].Value
+		})
+
+		pairs := []string{}
+
+		for _, key := range keys {
+			pairs = append(pairs, formatKey(key)+": "+formatNested(obj.Value[key], seen))
+		}
+
+		return "{" + strings.Join(pairs, ", ") + "}"
+
+	case *object.Struct:
+		if seen[obj] {
+			return obj.Def.Name + "{...}"
+		}
+
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		fields := []string{}
+
+		for i, field := range obj.Def.Fields {
+			fields = append(fields, field+": "+formatNested(obj.Fields[i], seen))
+		}
+
+		return obj.Def.Name + "{" + strings.Join(fields, ", ") + "}"
+
+	case *object.Function:
+		return formatFunction(obj)
+
+	case *object.Method:
+		return formatFunction(obj.Function)
+
+	case *object.Return:
+		return formatNested(obj.Value, seen)
+
+	default:
+		return obj.Inspect()
+	}
+}
+
+// formatFunction prints fn as `klang fmt` would print its literal, with its
+// name if it has one
+func formatFunction(fn *object.Function) string {
+	literal := source.Node(&ast.FunctionLiteralExpression{Parameters: fn.Parameters, Body: fn.Body})
+
+	if fn.Name == "" {
+		return literal
+	}
+
+	return "fn " + fn.Name + strings.TrimPrefix(literal, "fn")
+}
+
+func formatKey(key object.Hash) string {
+	if key.Type == object.OBJECT_STRING {
+		return strconv.Quote(key.Value)
+	}
+
+	return key.Value
+}
@@ -0,0 +1,124 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_FILE is created in the home directory of the user
+const HISTORY_FILE = ".klang_history"
+
+// how many entries are kept in the history file
+const historySize = 1000
+
+type history struct {
+	path    string
+	entries []string
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// loadHistory reads the history saved by a previous session. A missing
+// file simply starts an empty history
+func loadHistory(path string) *history {
+	hist := &history{path: path}
+
+	if path == "" {
+		return hist
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return hist
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		hist.add(unescapeEntry(scanner.Text()))
+	}
+
+	return hist
+}
+
+// add appends an input to the history as it was typed, its lines joined
+// with newlines. Blanks and repeats of the last entry are skipped
+func (h *history) add(input string) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == input {
+		return
+	}
+
+	h.entries = append(h.entries, input)
+
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+	}
+}
+
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	lines := []string{}
+
+	for _, entry := range h.entries {
+		lines = append(lines, escapeEntry(entry))
+	}
+
+	content := strings.Join(lines, "\n")
+
+	if content != "" {
+		content += "\n"
+	}
+
+	return os.WriteFile(h.path, []byte(content), 0600)
+}
+
+// escapeEntry writes a multi-line entry on a single line of the history
+// file, as `\n` for a newline and `\\` for a backslash
+func escapeEntry(entry string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(entry)
+}
+
+// unescapeEntry reverses escapeEntry. Any other backslash is kept as is,
+// as in the files written before entries were escaped
+func unescapeEntry(line string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			switch line[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+
+		b.WriteByte(line[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package repl
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	entries := []string{
+		`let s = "a   b"`,
+		"let arr = [1, // one\n  2]",
+		`"a\nb"`,
+		`"\\"`,
+		"let f = fn() {\n\t1\n}",
+	}
+
+	hist := loadHistory(path)
+
+	for _, entry := range append(entries, "  ", entries[len(entries)-1]) {
+		hist.add(entry)
+	}
+
+	if err := hist.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded := loadHistory(path); !reflect.DeepEqual(loaded.entries, entries) {
+		t.Fatalf("history is not matching expected.\nwant=%q\ngot=%q", entries, loaded.entries)
+	}
+}
+
+func TestUnescapeEntry(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`1\n2`, "1\n2"},
+		{`"\\n"`, `"\n"`},
+		// backslashes written before entries were escaped are kept
+		{`"a\tb"`, `"a\tb"`},
+		{`\`, `\`},
+	}
+
+	for _, tt := range tests {
+		if entry := unescapeEntry(tt.line); entry != tt.expected {
+			t.Errorf("%q: entry is not matching expected. want=%q, got=%q", tt.line, tt.expected, entry)
+		}
+	}
+}
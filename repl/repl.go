@@ -1,36 +1,301 @@
 package repl
 
 import (
+	"Klang/ast"
 	"Klang/eval"
 	"Klang/lexer"
 	"Klang/object"
 	"Klang/parser"
+	"Klang/token"
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 )
 
 const PROMPT = ">> "
 
-func Start() {
-	fmt.Println("Welcome To K Programming Language")
-	scanner := bufio.NewScanner(os.Stdin)
+// CONTINUATION_PROMPT is shown while the parser waits for the rest of an input
+const CONTINUATION_PROMPT = ".. "
+
+// session is the state of one repl run, meta commands such as `:reset` act on it
+type session struct {
+	out       io.Writer
+	reader    lineReader
+	evaluator *eval.Evaluator
+	env       *object.Environment
+	exited    bool // set once the script called `exit`
+}
+
+func Start(in io.Reader, out io.Writer) {
+	fmt.Fprintln(out, "Welcome To K Programming Language")
+	fmt.Fprintln(out, "Type :help for the list of commands")
+
+	s := &session{
+		out:       out,
+		evaluator: eval.New(out, out),
+		env:       newEnvironment(),
+	}
+
+	s.reader = newLineReader(in, out, func(prefix string) []string {
+		return complete(prefix, s.evaluator, s.env)
+	})
+	defer s.reader.Close()
+
+	for {
+		if quit := s.next(); quit {
+			return
+		}
+	}
+}
+
+// next reads and runs a single input, returning true once the session is
+// over. A panic while reading, parsing or running is reported and the
+// session goes on with the next input
+func (s *session) next() (quit bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(s.out, "internal error: %v\n", r)
+			quit = false
+		}
+	}()
+
+	input, ok := s.readInput()
+
+	if !ok {
+		return true
+	}
+
+	if input == "" {
+		return false
+	}
+
+	s.reader.AddHistory(input)
+	return s.handle(input) || s.exited
+}
+
+// handle runs a single input, returning true when it ends the session
+func (s *session) handle(input string) bool {
+	if strings.HasPrefix(input, ":") {
+		return s.runCommand(input)
+	}
+
+	s.eval(input)
+	return false
+}
+
+// newEnvironment returns the global scope of a session, where typing a
+// definition again replaces it
+func newEnvironment() *object.Environment {
 	env := object.NewEnvironment()
+	env.Redeclarable = true
+	return env
+}
+
+// eval parses and evaluates input in the session environment, reporting
+// parse errors instead of evaluating. The value of a trailing expression is
+// printed and bound to `_`, statements and nil print nothing
+func (s *session) eval(input string) (object.Object, bool) {
+	program, ok := s.parse(input)
+
+	if !ok {
+		return nil, false
+	}
+
+	evaluated := s.evaluator.Eval(program, s.env)
+
+	if s.reportError(evaluated) {
+		return evaluated, false
+	}
+
+	if ret, ok := evaluated.(*object.Return); ok {
+		evaluated = ret.Value
+	}
+
+	if !endsWithExpression(program) || evaluated.Type() == object.OBJECT_NILL {
+		return evaluated, true
+	}
+
+	fmt.Fprintln(s.out, format(evaluated))
+	s.env.Declare("_", evaluated, nil)
+	return evaluated, true
+}
+
+// reportError prints evaluated when it is an error and tells whether it
+// was. A call to `exit` prints nothing and ends the session instead
+func (s *session) reportError(evaluated object.Object) bool {
+	err, ok := evaluated.(*object.Error)
+
+	if !ok {
+		return false
+	}
+
+	if errors.Is(err.Err, eval.ErrExit) {
+		s.exited = true
+		return true
+	}
+
+	fmt.Fprintln(s.out, err.Inspect())
+	return true
+}
+
+func endsWithExpression(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	_, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func (s *session) parse(input string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(s.out, "parse error: %s\n", msg)
+		}
+
+		return nil, false
+	}
+
+	return program, true
+}
+
+// readInput keeps reading lines until they form a complete program or a
+// meta command. An empty line or the end of the input while waiting for
+// more gives up, the parse errors are then reported by eval. It returns
+// false once the input is exhausted
+func (s *session) readInput() (string, bool) {
+	lines := []string{}
+	prompt := PROMPT
 
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
+		line, err := s.reader.ReadLine(prompt)
 
-		if !scanned {
-			return
+		if err == errInterrupt {
+			return "", true
+		}
+
+		if err != nil {
+			return strings.Join(lines, "\n"), len(lines) > 0
+		}
+
+		if len(lines) == 0 {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			if strings.HasPrefix(strings.TrimSpace(line), ":") {
+				return strings.TrimSpace(line), true
+			}
 		}
 
-		input := scanner.Text()
-		l := lexer.New(input)
-		p := parser.New(l)
-		program := p.ParseProgram()
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
 
-		evaluated := eval.Eval(program, env)
-		fmt.Println(evaluated.Inspect())
+		p := parser.New(lexer.New(input))
+		p.ParseProgram()
+
+		if p.Incomplete() && strings.TrimSpace(line) != "" {
+			prompt = CONTINUATION_PROMPT
+			continue
+		}
+
+		return input, true
 	}
 }
+
+// newLineReader uses the line editor when in is a terminal, plain lines otherwise
+func newLineReader(in io.Reader, out io.Writer, complete func(string) []string) lineReader {
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		return newEditor(file, out, loadHistory(historyPath()), complete)
+	}
+
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+// complete returns the keywords, builtins and names of env starting with
+// prefix. After `name.`, it returns the members of the value of name instead
+func complete(prefix string, evaluator *eval.Evaluator, env *object.Environment) []string {
+	if prefix == "" {
+		return nil
+	}
+
+	if dot := strings.LastIndex(prefix, "."); dot >= 0 {
+		return completeMember(prefix[:dot], prefix[dot+1:], evaluator, env)
+	}
+
+	seen := make(map[string]bool)
+	candidates := []string{}
+
+	names := token.Keywords()
+	names = append(names, evaluator.BuiltinNames()...)
+	names = append(names, env.Names()...)
+
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+
+	return candidates
+}
+
+// completeMember returns `receiver.member` for the fields, methods, string
+// keys and builtin methods of the value bound to receiver whose name starts
+// with prefix
+func completeMember(receiver, prefix string, evaluator *eval.Evaluator, env *object.Environment) []string {
+	value, ok := env.Lookup(receiver)
+
+	if !ok {
+		return nil
+	}
+
+	members := evaluator.MethodNames(value.Type())
+
+	switch value := value.(type) {
+	case *object.Struct:
+		members = append(members, value.Def.Fields...)
+		members = append(members, methodNames(value.Def)...)
+
+	case *object.StructType:
+		members = append(members, methodNames(value)...)
+
+	case *object.HashMap:
+		for key := range value.Value {
+			if key.Type == object.OBJECT_STRING {
+				members = append(members, key.Value)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	candidates := []string{}
+
+	for _, member := range members {
+		if strings.HasPrefix(member, prefix) && !seen[member] {
+			seen[member] = true
+			candidates = append(candidates, receiver+"."+member)
+		}
+	}
+
+	return candidates
+}
+
+// methodNames returns the sorted names of the methods of def
+func methodNames(def *object.StructType) []string {
+	names := []string{}
+
+	for name := range def.Methods {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,146 @@
+package repl
+
+import (
+	"Klang/eval"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// run feeds input to a new session and returns what it printed after the
+// welcome message, without the prompts
+func run(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	output := strings.SplitN(out.String(), "\n", 3)[2]
+	output = strings.ReplaceAll(output, PROMPT, "")
+	return strings.ReplaceAll(output, CONTINUATION_PROMPT, "")
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2\n", "3\n"},
+		{"let x = 5\nx * 2\n", "10\n"},
+		{"fn f(a) { a }\nstruct P { x }\nwhile false { 1 }\n", ""},
+		{"print(\"hi\")\n[1].push(2)\n", "hi\n[1, 2]\n"},
+		{"\"hi\"\n", "\"hi\"\n"},
+		{"[\"a\", {\"k\": \"v\", 1: [\"b\"]}]\n", "[\"a\", {1: [\"b\"], \"k\": \"v\"}]\n"},
+		{"\"a\\tb\"\n", "\"a\\\\tb\"\n"},
+		{"struct P { name }\nP(\"k\")\n", "P{name: \"k\"}\n"},
+		{"\n   \n1\n", "1\n"},
+		{"1 + \"a\"\n2\n", "error: type mismatch: OBJECT_INTEGER + OBJECT_STRING\n2\n"},
+		{"let = 1\n3\n", "parse error: 1:5: expected next token to be IDENTIFIER, got ASSIGN instead\nparse error: 1:5: unexpected token ASSIGN `=`\n3\n"},
+		{"let x = 1\nlet x = 2\nx\n", "2\n"},
+		{"1\n:quit\n2\n", "1\n"},
+		{"1\nexit(2)\n2\n", "1\n"},
+		{"[1, 2].map(fn(x) { print(x); exit() })\n3\n", "1\n"},
+		// inputs that used to panic the parser while checking for more lines
+		{"(-) = 2\n1\n", "parse error: 1:3: unexpected token RPAREN `)`\nparse error: 1:2: invalid assignment target\nparse error: 1:7: expected next token to be RPAREN, got INTEGER instead\n1\n"},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%q: output is not matching expected.\nwant=%q\ngot=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestResultBinding(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"6 * 7\n_\n", "42\n42\n"},
+		{"6 * 7\n_ + 1\n_ + 1\n", "42\n43\n44\n"},
+		// statements, nil and errors keep the last result
+		{"6 * 7\nlet y = 1\nif false { 1 }\n1 + \"a\"\n_\n", "42\nerror: type mismatch: OBJECT_INTEGER + OBJECT_STRING\n42\n"},
+		{"\"s\"\n[_]\n", "\"s\"\n[\"s\"]\n"},
+		{"_\n", "undefined identifier: _\n"},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%q: output is not matching expected.\nwant=%q\ngot=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestRecoversFromPanics(t *testing.T) {
+	commands["panic"] = command{":panic", "panic", func(s *session, arg string) { panic("boom") }}
+	defer delete(commands, "panic")
+
+	if output := run(":panic\n1 + 1\n"); output != "internal error: boom\n2\n" {
+		t.Fatalf("output is not matching expected.\nwant=%q\ngot=%q", "internal error: boom\n2\n", output)
+	}
+}
+
+// panickingReader panics on its first read, then reports the end of the input
+type panickingReader struct {
+	panicked bool
+}
+
+func (r *panickingReader) ReadLine(prompt string) (string, error) {
+	if !r.panicked {
+		r.panicked = true
+		panic("boom")
+	}
+
+	return "", io.EOF
+}
+
+func (r *panickingReader) AddHistory(line string) {}
+
+func (r *panickingReader) Close() error { return nil }
+
+func TestRecoversFromPanicsWhileReading(t *testing.T) {
+	var out bytes.Buffer
+	s := &session{out: &out, reader: &panickingReader{}, evaluator: eval.New(&out, &out), env: newEnvironment()}
+
+	if quit := s.next(); quit || out.String() != "internal error: boom\n" {
+		t.Fatalf("a panic while reading is not recovered, quit=%t output=%q", quit, out.String())
+	}
+
+	if quit := s.next(); !quit {
+		t.Fatalf("the session goes on after the end of the input")
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a) {\n\ta + 1\n}\nf(1)\n", "2\n"},
+		{"if true {\n\tif true {\n\t\t\"deep\"\n\t}\n}\n", "\"deep\"\n"},
+		{"[1,\n2,\n3]\n", "[1, 2, 3]\n"},
+		{"{\"a\":\n1}\n", "{\"a\": 1}\n"},
+		{"[3, 1].map(\nfn(x) { x * 2 })\n", "[6, 2]\n"},
+		{"\"one\ntwo\"\n", "\"one\\ntwo\"\n"},
+		{"match 2 {\n1 => \"a\",\n_ => \"b\"\n}\n", "\"b\"\n"},
+		// an empty line gives up on the input
+		{"if true {\n\n1\n", "parse error: 2:1: expected `}` to close the block, got EOF instead\n1\n"},
+		// so does the end of the input
+		{"let f = fn() {\n1", "parse error: 2:2: expected `}` to close the block, got EOF instead\n"},
+		{"[1,\n", "parse error: 1:4: unexpected token EOF `EOF`\nparse error: 1:4: expected next token to be RBRACKET, got EOF instead\n"},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%q: output is not matching expected.\nwant=%q\ngot=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestContinuationPrompt(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let a = [\n1\n]\n"), &out)
+
+	if prompts := strings.Count(out.String(), CONTINUATION_PROMPT); prompts != 2 {
+		t.Fatalf("expected 2 continuation prompts, got=%d in %q", prompts, out.String())
+	}
+}
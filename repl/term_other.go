@@ -0,0 +1,21 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+type termState struct{}
+
+// line editing is only implemented for linux terminals, elsewhere the
+// repl falls back to reading plain lines
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func restoreTerm(fd int, state *termState) error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package resolve
+
+import (
+	"Klang/ast"
+	"Klang/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+
+	return "warning"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// codes identifying the kind of a diagnostic
+const (
+	CodeUndefined   = "undefined"
+	CodeUnused      = "unused"
+	CodeShadow      = "shadow"
+	CodeUnreachable = "unreachable"
+	CodeConstant    = "constant" // assignment to a constant or a builtin
+	CodeRedeclared  = "redeclared"
+)
+
+// Diagnostic is a mistake found while resolving, located at Line and Column
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string
+	Message  string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+func (r *resolver) report(tok token.Token, severity Severity, code, format string, args ...interface{}) {
+	r.info.Diagnostics = append(r.info.Diagnostics, &Diagnostic{
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkShadowing reports a declaration hiding a name of an enclosing scope.
+// Redeclaring a name in its own scope is reported by declare instead
+func (r *resolver) checkShadowing(ident *ast.Identifier) {
+	if _, ok := r.scope.names[ident.Value]; ok || r.scope.Parent == nil || ignored(ident.Value) {
+		return
+	}
+
+	outer := r.scope.Parent.Lookup(ident.Value)
+
+	if outer == nil {
+		return
+	}
+
+	if outer.Kind == Builtin {
+		r.report(ident.Token, Warning, CodeShadow, "%s shadows the builtin %s", ident.Value, ident.Value)
+		return
+	}
+
+	r.report(ident.Token, Warning, CodeShadow, "%s shadows the declaration at %d:%d", ident.Value, outer.Decl.Token.Line, outer.Decl.Token.Column)
+}
+
+// checkUnused reports the bindings and parameters whose value is never read.
+// Names starting with `_` are meant to be unused
+func (r *resolver) checkUnused() {
+	for _, sym := range r.declared {
+		if sym.reads > 0 || ignored(sym.Name) {
+			continue
+		}
+
+		// a method may leave out the value it is called on
+		if sym.Kind == Parameter && sym.Name == "self" {
+			continue
+		}
+
+		if sym.Kind == Parameter {
+			r.report(sym.Decl.Token, Warning, CodeUnused, "parameter %s is never used", sym.Name)
+		} else {
+			r.report(sym.Decl.Token, Warning, CodeUnused, "%s is declared but never used", sym.Name)
+		}
+	}
+}
+
+func (r *resolver) sortDiagnostics() {
+	diags := r.info.Diagnostics
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+
+		return diags[i].Column < diags[j].Column
+	})
+}
+
+func ignored(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,558 @@
+// Package resolve binds every identifier of a K program to the declaration it
+// refers to and reports the mistakes found on the way: undefined names,
+// unused bindings, shadowing and unreachable code. K scopes are function bodies: blocks of `if` and `while` share the
+// scope of the function around them, the same way the evaluator runs them.
+//
+// Function bodies are resolved once the scope around them is complete, since
+// they only run when called and may use bindings declared after them:
+//
+//	let even = fn(n) { if n == 0 { true } else { odd(n - 1) } };
+//	let odd = fn(n) { if n == 0 { false } else { even(n - 1) } };
+//
+// Functions declared by `fn name() {}` are hoisted: they are visible from
+// every statement of the program or function body declaring them.
+package resolve
+
+import (
+	"Klang/ast"
+	"Klang/token"
+)
+
+type Kind int
+
+const (
+	Builtin Kind = iota
+	Variable
+	Parameter
+	Function // declared by `fn name() {}`
+	Constant // declared by `const`
+	Struct   // declared by `struct Name {}`
+	Trait    // declared by `trait Name {}`
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Builtin:
+		return "builtin"
+	case Parameter:
+		return "parameter"
+	case Function:
+		return "function"
+	case Constant:
+		return "constant"
+	case Struct:
+		return "struct"
+	case Trait:
+		return "trait"
+	default:
+		return "variable"
+	}
+}
+
+// Symbol is a name declared by `let`, `const`, `fn`, `struct`, `trait`, a
+// function parameter or the host
+type Symbol struct {
+	Name   string
+	Kind   Kind
+	Decl   *ast.Identifier      // nil for builtins
+	Value  ast.Expression       // value of the `let` or function of the `fn` declaring it, nil otherwise
+	Struct *ast.StructStatement // declaration of a struct, nil otherwise
+	Scope  *Scope               // scope declaring the symbol
+	Refs   []*ast.Identifier    // every use of the symbol, assignments included
+	Slot   int                  // index of the symbol in Scope.Symbols
+	reads  int                  // uses reading the value, assignments left out
+}
+
+// Scope is the universe of builtins, the program, the body of a function or
+// a match arm
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node // *ast.Program, *ast.FunctionLiteralExpression or *ast.MatchArm, nil for the universe
+	Symbols  []*Symbol
+	Children []*Scope
+	Depth    int // 0 for the program, -1 for the universe
+	names    map[string]*Symbol
+}
+
+func newScope(parent *Scope, node ast.Node) *Scope {
+	scope := &Scope{Parent: parent, Node: node, Depth: -1, names: make(map[string]*Symbol)}
+
+	if parent != nil {
+		parent.Children = append(parent.Children, scope)
+		scope.Depth = parent.Depth + 1
+	}
+
+	return scope
+}
+
+// Lookup finds the symbol name refers to from the scope, walking up its parents
+func (s *Scope) Lookup(name string) *Symbol {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if sym, ok := scope.names[name]; ok {
+			return sym
+		}
+	}
+
+	return nil
+}
+
+// Visible returns every symbol that can be used from the scope, inner
+// declarations hiding outer ones
+func (s *Scope) Visible() []*Symbol {
+	seen := make(map[string]bool)
+	symbols := []*Symbol{}
+
+	for scope := s; scope != nil; scope = scope.Parent {
+		for _, sym := range scope.Symbols {
+			if !seen[sym.Name] {
+				seen[sym.Name] = true
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+
+	return symbols
+}
+
+// Contains reports whether the source position line:column lies inside the scope
+func (s *Scope) Contains(line, column int) bool {
+	switch node := s.Node.(type) {
+	case *ast.FunctionLiteralExpression:
+		if node.Body == nil {
+			return false
+		}
+
+		return !before(line, column, node.Token) && !after(line, column, node.Body.Rbrace)
+
+	case *ast.MatchArm:
+		return !before(line, column, ast.StartToken(node.Pattern)) && !after(line, column, node.End)
+
+	default:
+		return true
+	}
+}
+
+func (s *Scope) declare(sym *Symbol) *Symbol {
+	sym.Scope = s
+
+	if old, ok := s.names[sym.Name]; ok {
+		// a redeclaration is an error, the new binding still takes the slot
+		// of the old one as it does in a redeclarable Environment
+		sym.Slot = old.Slot
+		s.Symbols[sym.Slot] = sym
+	} else {
+		sym.Slot = len(s.Symbols)
+		s.Symbols = append(s.Symbols, sym)
+	}
+
+	s.names[sym.Name] = sym
+	return sym
+}
+
+// Info is the result of resolving a program
+type Info struct {
+	Universe    *Scope                      // builtins
+	Global      *Scope                      // top level of the program
+	Symbols     map[*ast.Identifier]*Symbol // declaration or use to its symbol
+	Unresolved  []*ast.Identifier           // uses of undeclared names
+	Diagnostics []*Diagnostic               // sorted by position
+	Scopes      map[ast.Node]*Scope         // program, function literals and match arms to their scope
+}
+
+// Resolve binds the identifiers of program, predeclared are the names
+// provided by the host such as the builtins of the evaluator
+func Resolve(program *ast.Program, predeclared []string) *Info {
+	r := &resolver{
+		info: &Info{
+			Universe: newScope(nil, nil),
+			Symbols:  make(map[*ast.Identifier]*Symbol),
+			Scopes:   make(map[ast.Node]*Scope),
+		},
+		hoisted: make(map[*ast.FunctionStatement]bool),
+	}
+
+	for _, name := range predeclared {
+		r.info.Universe.declare(&Symbol{Name: name, Kind: Builtin})
+	}
+
+	r.info.Global = newScope(r.info.Universe, program)
+	r.info.Scopes[program] = r.info.Global
+	r.scope = r.info.Global
+
+	r.hoist(program.Statements)
+	r.statements(program.Statements)
+	r.flush()
+	r.checkUnused()
+	r.sortDiagnostics()
+
+	return r.info
+}
+
+// Lookup returns the identifier found at line:column and its symbol, nil
+// when there is none or the name is not declared
+func (info *Info) Lookup(line, column int) (*ast.Identifier, *Symbol) {
+	for ident, sym := range info.Symbols {
+		if ident.Token.Line == line && column >= ident.Token.Column && column <= ident.Token.Column+len(ident.Value) {
+			return ident, sym
+		}
+	}
+
+	return nil, nil
+}
+
+// ScopeAt returns the innermost scope containing line:column
+func (info *Info) ScopeAt(line, column int) *Scope {
+	scope := info.Global
+
+	for {
+		inner := (*Scope)(nil)
+
+		for _, child := range scope.Children {
+			if child.Contains(line, column) {
+				inner = child
+				break
+			}
+		}
+
+		if inner == nil {
+			return scope
+		}
+
+		scope = inner
+	}
+}
+
+type resolver struct {
+	info     *Info
+	scope    *Scope
+	deferred []func()                        // function bodies waiting for their enclosing scope to be complete
+	declared []*Symbol                       // every symbol declared in the program, redeclared ones included
+	hoisted  map[*ast.FunctionStatement]bool // declarations bound before the statements of their scope
+}
+
+// flush resolves the function bodies met so far
+func (r *resolver) flush() {
+	for len(r.deferred) > 0 {
+		body := r.deferred[0]
+		r.deferred = r.deferred[1:]
+		body()
+	}
+}
+
+func (r *resolver) declare(ident *ast.Identifier, kind Kind, value ast.Expression) *Symbol {
+	if ident == nil {
+		return nil
+	}
+
+	r.checkShadowing(ident)
+
+	if old, ok := r.scope.names[ident.Value]; ok {
+		if old.Kind == Constant {
+			r.report(ident.Token, Error, CodeConstant, "cannot redeclare constant %s", ident.Value)
+		} else {
+			r.report(ident.Token, Error, CodeRedeclared, "%s is already declared at %d:%d", ident.Value, old.Decl.Token.Line, old.Decl.Token.Column)
+		}
+	}
+
+	sym := r.scope.declare(&Symbol{Name: ident.Value, Kind: kind, Decl: ident, Value: value})
+	r.declared = append(r.declared, sym)
+	r.bind(ident, sym)
+	return sym
+}
+
+// use resolves an identifier reading or, for assignments, writing a symbol
+func (r *resolver) use(ident *ast.Identifier, read bool) {
+	sym := r.scope.Lookup(ident.Value)
+
+	if sym == nil {
+		r.info.Unresolved = append(r.info.Unresolved, ident)
+		r.report(ident.Token, Error, CodeUndefined, "undefined: %s", ident.Value)
+		return
+	}
+
+	if read {
+		sym.reads++
+	} else if sym.Kind == Constant {
+		r.report(ident.Token, Error, CodeConstant, "cannot assign to constant %s", ident.Value)
+	} else if sym.Kind == Builtin {
+		r.report(ident.Token, Error, CodeConstant, "cannot assign to builtin %s", ident.Value)
+	}
+
+	sym.Refs = append(sym.Refs, ident)
+	r.bind(ident, sym)
+}
+
+// bind records sym as the symbol of ident and annotates ident with the
+// location of its slot, builtins are left to the evaluator
+func (r *resolver) bind(ident *ast.Identifier, sym *Symbol) {
+	r.info.Symbols[ident] = sym
+
+	if sym.Kind == Builtin {
+		return
+	}
+
+	ident.Resolved = true
+	ident.Depth = r.scope.Depth - sym.Scope.Depth
+	ident.Slot = sym.Slot
+}
+
+// hoist declares the functions of a program or function body before its
+// statements are resolved, as the evaluator binds them
+func (r *resolver) hoist(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		if fnStmt, ok := stmt.(*ast.FunctionStatement); ok {
+			r.declare(fnStmt.Name, Function, fnStmt.Function)
+			r.hoisted[fnStmt] = true
+		}
+	}
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	returned, reported := false, false
+
+	for _, stmt := range stmts {
+		// a hoisted declaration is bound even when placed after a `return`
+		fnStmt, _ := stmt.(*ast.FunctionStatement)
+
+		if returned && !reported && !r.hoisted[fnStmt] {
+			r.report(ast.StartToken(stmt), Warning, CodeUnreachable, "unreachable code")
+			reported = true
+		}
+
+		r.statement(stmt)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.expression(stmt.Value)
+
+		kind := Variable
+
+		if stmt.Constant() {
+			kind = Constant
+		}
+
+		if stmt.Pattern != nil {
+			r.pattern(stmt.Pattern, kind)
+		} else {
+			r.declare(stmt.Name, kind, stmt.Value)
+		}
+
+	case *ast.FunctionStatement:
+		if !r.hoisted[stmt] {
+			r.declare(stmt.Name, Function, stmt.Function)
+		}
+
+		r.function(stmt.Function)
+
+	case *ast.StructStatement:
+		if sym := r.declare(stmt.Name, Struct, nil); sym != nil {
+			sym.Struct = stmt
+		}
+
+	case *ast.TraitStatement:
+		r.declare(stmt.Name, Trait, nil)
+		r.methods(stmt.Methods)
+
+	case *ast.ImplStatement:
+		if stmt.Trait != nil {
+			r.use(stmt.Trait, true)
+		}
+
+		r.use(stmt.Type, true)
+		r.methods(stmt.Methods)
+
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+
+	case *ast.WhileStatement:
+		r.expression(stmt.Condition)
+		r.block(stmt.Body)
+
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block != nil {
+		r.statements(block.Statements)
+	}
+}
+
+func (r *resolver) expressions(list *ast.ExpressionList) {
+	if list == nil {
+		return
+	}
+
+	for _, expr := range list.List {
+		r.expression(expr)
+	}
+}
+
+func (r *resolver) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		r.use(expr, true)
+
+	case *ast.PrefixExpression:
+		r.expression(expr.Right)
+
+	case *ast.InfixExpression:
+		r.expression(expr.Left)
+		r.expression(expr.Right)
+
+	case *ast.AssignmentExpression:
+		r.expression(expr.Value)
+		r.use(expr.Ident, false)
+
+	case *ast.IndexAssignmentExpression:
+		r.expression(expr.Target)
+		r.expression(expr.Value)
+
+	case *ast.MemberAssignmentExpression:
+		r.expression(expr.Target)
+		r.expression(expr.Value)
+
+	case *ast.IfExpression:
+		r.expression(expr.Condition)
+		r.block(expr.IfArm)
+		r.block(expr.ElseArm)
+
+	case *ast.MatchExpression:
+		r.expression(expr.Value)
+
+		for _, arm := range expr.Arms {
+			r.arm(arm)
+		}
+
+	case *ast.BlockStatement:
+		r.block(expr)
+
+	case *ast.FunctionLiteralExpression:
+		r.function(expr)
+
+	case *ast.FunctionCallExpression:
+		r.expression(expr.Function)
+		r.expressions(expr.Args)
+
+	case *ast.SpreadExpression:
+		r.expression(expr.Value)
+
+	case *ast.ArrayLiteralExpression:
+		r.expressions(expr.Elements)
+
+	case *ast.IndexExpression:
+		r.expression(expr.Ident)
+		r.expression(expr.Index)
+
+	case *ast.MemberExpression:
+		r.expression(expr.Object)
+
+	case *ast.HashmapLiteralExpression:
+		for _, key := range expr.Keys {
+			r.expression(key)
+			r.expression(expr.Map[key])
+		}
+	}
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteralExpression) {
+	scope := newScope(r.scope, fn)
+	r.info.Scopes[fn] = scope
+
+	r.deferred = append(r.deferred, func() {
+		saved := r.scope
+		r.scope = scope
+
+		// a default value sees the parameters before it
+		for _, param := range fn.Parameters {
+			r.expression(param.Default)
+
+			if param.Pattern != nil {
+				r.pattern(param.Pattern, Parameter)
+			} else {
+				r.declare(param.Name, Parameter, nil)
+			}
+		}
+
+		if fn.Body != nil {
+			r.hoist(fn.Body.Statements)
+		}
+
+		r.block(fn.Body)
+		r.scope = saved
+	})
+}
+
+// methods resolves the methods of an impl or trait, those required by a
+// trait having no body and nothing to resolve
+func (r *resolver) methods(methods []*ast.FunctionStatement) {
+	for _, method := range methods {
+		if method.Function.Body != nil {
+			r.function(method.Function)
+		}
+	}
+}
+
+// arm resolves a match arm in a scope of its own, holding the names bound
+// by its pattern
+func (r *resolver) arm(arm *ast.MatchArm) {
+	scope := newScope(r.scope, arm)
+	r.info.Scopes[arm] = scope
+
+	saved := r.scope
+	r.scope = scope
+
+	r.pattern(arm.Pattern, Variable)
+	r.expression(arm.Guard)
+	r.expression(arm.Body)
+
+	r.scope = saved
+}
+
+// pattern declares the names bound by pattern as symbols of kind, `_`
+// binding nothing
+func (r *resolver) pattern(pattern ast.Pattern, kind Kind) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			r.declare(pattern, kind, nil)
+		}
+
+	case *ast.ArrayPattern:
+		for _, elem := range pattern.Elements {
+			r.pattern(elem, kind)
+		}
+
+		if pattern.Rest != nil {
+			r.pattern(pattern.Rest, kind)
+		}
+
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			r.pattern(value, kind)
+		}
+
+	case *ast.AlternativePattern:
+		for _, alt := range pattern.Alternatives {
+			r.pattern(alt, kind)
+		}
+	}
+}
+
+// before reports whether line:column comes before tok in the source
+func before(line, column int, tok token.Token) bool {
+	return line < tok.Line || (line == tok.Line && column < tok.Column)
+}
+
+// after reports whether line:column comes after tok in the source
+func after(line, column int, tok token.Token) bool {
+	return line > tok.Line || (line == tok.Line && column > tok.Column)
+}
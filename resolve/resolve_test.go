@@ -0,0 +1,150 @@
+package resolve
+
+import (
+	"Klang/ast"
+	"Klang/lexer"
+	"Klang/parser"
+	"os"
+	"testing"
+)
+
+func resolveSource(t *testing.T, input string) (*ast.Program, *Info) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse error: %s", p.Errors()[0])
+	}
+
+	return program, Resolve(program, []string{"print", "len"})
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; print(x);", []string{}},
+		{"print(y);", []string{"1:7: error: undefined: y"}},
+		{"let x = 1;", []string{"1:5: warning: x is declared but never used"}},
+		{"let _x = 1;", []string{}},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15: warning: parameter b is never used"}},
+		{"let x = 1; x = 2;", []string{"1:5: warning: x is declared but never used"}},
+		{"let x = 1; let f = fn(x) { x }; f(x);", []string{"1:23: warning: x shadows the declaration at 1:5"}},
+		{"let f = fn(len) { len }; f(1);", []string{"1:12: warning: len shadows the builtin len"}},
+		{"let x = 1; let x = x + 1; print(x);", []string{"1:16: error: x is already declared at 1:5"}},
+		{"let x = 1; let f = fn() { let x = 2; x }; print(x, f());", []string{"1:31: warning: x shadows the declaration at 1:5"}},
+		{"x = 1;", []string{"1:1: error: undefined: x"}},
+		{"let n = 0; let inc = fn() { n = n + 1 }; inc();", []string{}},
+		{"let f = fn() { return 1; print(2); print(3); }; f();", []string{"1:26: warning: unreachable code"}},
+		// function bodies see the bindings declared after them
+		{"let even = fn(n) { odd(n) }; let odd = fn(n) { even(n) }; even(1);", []string{}},
+		{"let f = fn() { g() }; f();", []string{"1:16: error: undefined: g"}},
+		// function declarations are hoisted to the top of their scope
+		{"main(); fn main() { helper() } fn helper() { 1 }", []string{}},
+		{"fn f() { return g(); fn g() { 1 } } f();", []string{}},
+		{"fn unused() {}", []string{"1:4: warning: unused is declared but never used"}},
+		{"g(); if true { fn g() {} }", []string{"1:1: error: undefined: g", "1:19: warning: g is declared but never used"}},
+		{"const x = 1; print(x); x = 2;", []string{"1:24: error: cannot assign to constant x"}},
+		{"const x = 1; print(x); let x = 2; print(x);", []string{"1:28: error: cannot redeclare constant x"}},
+		{"len = 1;", []string{"1:1: error: cannot assign to builtin len"}},
+		{"let a = [1]; a[0] = 2;", []string{}},
+		// the names bound by a pattern are only visible in their arm
+		{"let v = [1]; match v { [x] => x, _ => 0 }; print(x);", []string{"1:50: error: undefined: x"}},
+		{"match 1 { [a, ...rest] if a > 0 => a, {name} => 0, _ => 1 };", []string{"1:18: warning: rest is declared but never used", "1:40: warning: name is declared but never used"}},
+		{"let [a, ...rest] = [1]; print(a);", []string{"1:12: warning: rest is declared but never used"}},
+		{"const {name} = {}; print(name); name = 1;", []string{"1:33: error: cannot assign to constant name"}},
+		{"let f = fn([a, b], {c}) { a + c }; f([1, 2], {});", []string{"1:16: warning: parameter b is never used"}},
+		{"struct Point { x, y } let p = Point(1, 2); p.x = p.y;", []string{}},
+		{"struct Point { x, y }", []string{"1:8: warning: Point is declared but never used"}},
+		{"print(Point(1, 2)); struct Point { x, y }", []string{"1:7: error: undefined: Point", "1:28: warning: Point is declared but never used"}},
+		{"struct P { x } impl P { fn get(self, n) { 1 } fn set(self, x) { self.x = x } }", []string{"1:38: warning: parameter n is never used"}},
+		{"impl P {} trait T { fn f(self, n); }", []string{"1:6: error: undefined: P", "1:17: warning: T is declared but never used"}},
+	}
+
+	for _, tt := range tests {
+		_, info := resolveSource(t, tt.input)
+
+		if len(info.Diagnostics) != len(tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.expected, info.Diagnostics)
+			continue
+		}
+
+		for i, diag := range info.Diagnostics {
+			if diag.String() != tt.expected[i] {
+				t.Errorf("%q: expected %q, got %q", tt.input, tt.expected[i], diag.String())
+			}
+		}
+	}
+}
+
+func TestReturnExample(t *testing.T) {
+	src, err := os.ReadFile("../examples/return.mk")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, info := resolveSource(t, string(src))
+
+	if len(info.Diagnostics) != 1 || info.Diagnostics[0].Code != CodeUnreachable || info.Diagnostics[0].Line != 3 {
+		t.Fatalf("expected a single unreachable code warning on line 3, got %v", info.Diagnostics)
+	}
+}
+
+func TestSlots(t *testing.T) {
+	_, info := resolveSource(t, "let a = 1; let b = 2; let f = fn(x) { let y = x; a + b + y }; print(f(1));")
+
+	tests := []struct {
+		line, column int
+		depth, slot  int
+	}{
+		{1, 5, 0, 0},  // a declared
+		{1, 50, 1, 0}, // a used in f
+		{1, 54, 1, 1}, // b used in f
+		{1, 34, 0, 0}, // x declared in f
+		{1, 47, 0, 0}, // x used in f
+		{1, 58, 0, 1}, // y used in f
+		{1, 69, 0, 2}, // f called at the top level
+	}
+
+	for _, tt := range tests {
+		ident, sym := info.Lookup(tt.line, tt.column)
+
+		if ident == nil || sym == nil {
+			t.Fatalf("no identifier at %d:%d", tt.line, tt.column)
+		}
+
+		if !ident.Resolved || ident.Depth != tt.depth || ident.Slot != tt.slot {
+			t.Errorf("%s at %d:%d: expected depth %d slot %d, got %t %d %d", ident.Value, tt.line, tt.column, tt.depth, tt.slot, ident.Resolved, ident.Depth, ident.Slot)
+		}
+	}
+
+	if ident, _ := info.Lookup(1, 63); ident == nil || ident.Resolved {
+		t.Errorf("builtins should be left unresolved, got %+v", ident)
+	}
+}
+
+func TestScopeAt(t *testing.T) {
+	_, info := resolveSource(t, "let f = fn(a) {\n\tlet inner = a;\n\tinner\n};\nf(1);")
+
+	names := func(scope *Scope) map[string]bool {
+		seen := map[string]bool{}
+
+		for _, sym := range scope.Visible() {
+			seen[sym.Name] = true
+		}
+
+		return seen
+	}
+
+	if inside := names(info.ScopeAt(2, 2)); !inside["inner"] || !inside["a"] || !inside["f"] || !inside["print"] {
+		t.Errorf("wrong names inside f: %v", inside)
+	}
+
+	if outside := names(info.ScopeAt(5, 1)); outside["inner"] || outside["a"] || !outside["f"] {
+		t.Errorf("wrong names outside f: %v", outside)
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 const (
 	// Single character token
 	PLUS      = "PLUS"      // `+`
@@ -19,12 +21,19 @@ const (
 	COMMA     = "COMMA"     // `,`
 	LBRACKET  = "LBRACKET"  // `[`
 	RBRACKET  = "RBRACKET"  // `]`
+	PIPE      = "PIPE"      // `|`
+	DOT       = "DOT"       // `.`
 
 	// Double character token
 	EQUAL         = "EQUAL"         // `==`
 	EQUAL_NOT     = "EQUAL_NOT"     // `!=`
 	GREATER_EQUAL = "GREATER_EQUAL" // `>=`
 	LESSER_EQUAL  = "LESSER_EQUAL"  // `<=`
+	ARROW         = "ARROW"         // `->`
+	FAT_ARROW     = "FAT_ARROW"     // `=>`
+
+	// Triple character token
+	ELLIPSIS = "ELLIPSIS" // `...`
 
 	// Multiple character token
 	INTEGER    = "INTEGER"    // `[0-9]+`
@@ -35,20 +44,27 @@ const (
 	// Special token
 	EOF     = "EOF"
 	ILLEGAL = "ILLEGAL"
+	COMMENT = "COMMENT" // `// ...` up to the end of the line, never returned by NextToken
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	RETURN   = "RETURN"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	WHILE    = "WHILE"
+	MATCH    = "MATCH"
+	STRUCT   = "STRUCT"
+	IMPL     = "IMPL"
+	TRAIT    = "TRAIT"
 )
 
 var keywords = map[string]TokenType{
 	"let":    LET,
+	"const":  CONST,
 	"fn":     FUNCTION,
 	"return": RETURN,
 	"true":   TRUE,
@@ -56,6 +72,10 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"while":  WHILE,
+	"match":  MATCH,
+	"struct": STRUCT,
+	"impl":   IMPL,
+	"trait":  TRAIT,
 }
 
 type TokenType string
@@ -63,6 +83,20 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the first character
+	Column  int // 1-based column of the first character, in bytes
+}
+
+// Keywords returns every reserved word of the language
+func Keywords() []string {
+	words := []string{}
+
+	for word := range keywords {
+		words = append(words, word)
+	}
+
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(literal string) TokenType {
@@ -0,0 +1,772 @@
+// Package typecheck verifies the optional type annotations of a K program
+// before it runs. Types are inferred locally from literals, operators,
+// function literals and builtins; whatever cannot be inferred is `any`,
+// which is accepted everywhere, so unannotated code keeps working.
+//
+//	let add = fn(a: int, b: int) -> int { a + b };
+//	let s: string = add(1, 2); // cannot use int as string
+package typecheck
+
+import (
+	"Klang/ast"
+	"Klang/object"
+	"Klang/resolve"
+	"Klang/token"
+	"fmt"
+)
+
+// Error is a type mismatch found at Line and Column
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Check returns the type errors of program, predeclared are the names
+// provided by the host such as the builtins of the evaluator
+func Check(program *ast.Program, predeclared []string) []*Error {
+	c := &checker{
+		info:      resolve.Resolve(program, predeclared),
+		types:     make(map[*resolve.Symbol]Type),
+		annotated: make(map[*resolve.Symbol]bool),
+		assigned:  make(map[*resolve.Symbol]bool),
+		hoisted:   make(map[*ast.FunctionStatement]bool),
+		structs:   make(map[string]*Struct),
+	}
+
+	traits := make(map[string]*ast.TraitStatement)
+	impls := []*ast.ImplStatement{}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignmentExpression:
+			if sym, ok := c.info.Symbols[node.Ident]; ok {
+				c.assigned[sym] = true
+			}
+
+		case *ast.StructStatement:
+			// a struct can be named by annotations before its declaration
+			typ := &Struct{Name: node.Name.Value, Fields: []string{}}
+
+			for _, field := range node.Fields {
+				typ.Fields = append(typ.Fields, field.Value)
+			}
+
+			c.structs[typ.Name] = typ
+
+		case *ast.TraitStatement:
+			traits[node.Name.Value] = node
+
+		case *ast.ImplStatement:
+			impls = append(impls, node)
+		}
+
+		return true
+	})
+
+	// the methods of a struct can be called before its impl blocks
+	for _, impl := range impls {
+		typ, ok := c.structs[impl.Type.Value]
+
+		if !ok {
+			continue
+		}
+
+		for _, method := range impl.Methods {
+			typ.Methods = append(typ.Methods, method.Name.Value)
+		}
+
+		if impl.Trait == nil {
+			continue
+		}
+
+		if trait, ok := traits[impl.Trait.Value]; ok {
+			for _, method := range trait.Methods {
+				typ.Methods = append(typ.Methods, method.Name.Value)
+			}
+		}
+	}
+
+	c.hoist(program.Statements)
+	c.statements(program.Statements)
+	return c.errors
+}
+
+type checker struct {
+	info      *resolve.Info
+	types     map[*resolve.Symbol]Type
+	annotated map[*resolve.Symbol]bool        // symbols whose type was written down
+	assigned  map[*resolve.Symbol]bool        // symbols reassigned somewhere
+	hoisted   map[*ast.FunctionStatement]bool // declarations typed before the statements of their scope
+	structs   map[string]*Struct              // struct types by name
+	results   []Type                          // annotated result of the enclosing functions, nil when unchecked
+	errors    []*Error
+}
+
+func (c *checker) errorf(tok token.Token, format string, args ...interface{}) {
+	c.errors = append(c.errors, &Error{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// annotation converts a type annotation, nil being `any`
+func (c *checker) annotation(typ *ast.TypeAnnotation) Type {
+	if typ == nil {
+		return Any
+	}
+
+	switch typ.Token.Type {
+	case token.LBRACKET:
+		return &Array{Elem: c.annotation(typ.Elem)}
+
+	case token.LBRACE:
+		return &Hash{Key: c.annotation(typ.Key), Value: c.annotation(typ.Elem)}
+
+	case token.FUNCTION:
+		fn := &Func{Params: []Type{}, Return: c.annotation(typ.Return), Variadic: typ.Variadic}
+
+		for _, param := range typ.Params {
+			fn.Params = append(fn.Params, c.annotation(param))
+		}
+
+		return fn
+	}
+
+	switch basic := Basic(typ.Name); basic {
+	case Int, Float, String, Bool, Nil, Any:
+		return basic
+	}
+
+	if structType, ok := c.structs[typ.Name]; ok {
+		return structType
+	}
+
+	c.errorf(typ.Token, "unknown type %s", typ.Name)
+	return Any
+}
+
+// declare gives its type to the symbol of ident: the annotated type when
+// there is one, else the inferred type unless the symbol is reassigned later
+func (c *checker) declare(ident *ast.Identifier, annotated bool, typ Type) {
+	sym, ok := c.info.Symbols[ident]
+
+	if !ok {
+		return
+	}
+
+	switch {
+	case annotated:
+		c.types[sym] = typ
+		c.annotated[sym] = true
+
+	case c.assigned[sym]:
+		c.types[sym] = Any
+
+	default:
+		c.types[sym] = typ
+	}
+}
+
+// hoist types the functions declared by the statements of a program or a
+// function body first, since they can be called from any of them
+func (c *checker) hoist(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		if fnStmt, ok := stmt.(*ast.FunctionStatement); ok {
+			c.declare(fnStmt.Name, false, c.function(fnStmt.Function))
+			c.hoisted[fnStmt] = true
+		}
+	}
+}
+
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var last Type = Nil
+
+	for _, stmt := range stmts {
+		last = c.statement(stmt)
+	}
+
+	return last
+}
+
+// statement checks stmt and returns the type of the value it leaves, the
+// value of a block being the one of its last statement
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		value := c.expression(stmt.Value)
+
+		if stmt.Type == nil {
+			c.let(stmt, false, value)
+			return Nil
+		}
+
+		expected := c.annotation(stmt.Type)
+
+		if !assignable(expected, value) {
+			c.errorf(ast.StartToken(stmt.Value), "cannot use %s as %s in the declaration of %s", value, expected, stmt.Target())
+		}
+
+		c.let(stmt, true, expected)
+		return Nil
+
+	case *ast.FunctionStatement:
+		if !c.hoisted[stmt] {
+			c.declare(stmt.Name, false, c.function(stmt.Function))
+		}
+
+		return Nil
+
+	case *ast.StructStatement:
+		typ := c.structs[stmt.Name.Value]
+		constructor := &Func{Params: []Type{}, Return: typ}
+
+		for range typ.Fields {
+			constructor.Params = append(constructor.Params, Any)
+		}
+
+		c.declare(stmt.Name, false, constructor)
+		return Nil
+
+	case *ast.TraitStatement:
+		c.methods(stmt.Methods)
+		return Nil
+
+	case *ast.ImplStatement:
+		c.methods(stmt.Methods)
+		return Nil
+
+	case *ast.ReturnStatement:
+		value := c.expression(stmt.ReturnValue)
+		c.checkResult(stmt.ReturnValue, value)
+		return value
+
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+
+		if stmt.Body != nil {
+			c.statements(stmt.Body.Statements)
+		}
+
+		return Any
+
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	}
+
+	return Any
+}
+
+func (c *checker) methods(methods []*ast.FunctionStatement) {
+	for _, method := range methods {
+		c.function(method.Function)
+	}
+}
+
+// checkResult verifies a value returned from the enclosing function
+func (c *checker) checkResult(expr ast.Expression, value Type) {
+	if len(c.results) == 0 || expr == nil {
+		return
+	}
+
+	if expected := c.results[len(c.results)-1]; expected != nil && !assignable(expected, value) {
+		c.errorf(ast.StartToken(expr), "cannot return %s from a function returning %s", value, expected)
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) Type {
+	if block == nil {
+		return Nil
+	}
+
+	return c.statements(block.Statements)
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+
+	case *ast.FloatLiteral:
+		return Float
+
+	case *ast.StringLiteralExpression:
+		return String
+
+	case *ast.BooleanLiteral:
+		return Bool
+
+	case *ast.Identifier:
+		return c.identifier(expr)
+
+	case *ast.PrefixExpression:
+		return c.prefix(expr)
+
+	case *ast.InfixExpression:
+		return c.infix(expr)
+
+	case *ast.AssignmentExpression:
+		value := c.expression(expr.Value)
+
+		if sym, ok := c.info.Symbols[expr.Ident]; ok && c.annotated[sym] && !assignable(c.types[sym], value) {
+			c.errorf(ast.StartToken(expr.Value), "cannot assign %s to %s of type %s", value, expr.Ident.Value, c.types[sym])
+		}
+
+		return Nil
+
+	case *ast.IndexAssignmentExpression:
+		elem, value := c.index(expr.Target), c.expression(expr.Value)
+
+		// like plain assignments, only the elements of annotated bindings are held to their type
+		if ident, ok := expr.Target.Ident.(*ast.Identifier); ok {
+			if sym, ok := c.info.Symbols[ident]; ok && c.annotated[sym] && !assignable(elem, value) {
+				c.errorf(ast.StartToken(expr.Value), "cannot assign %s to element of type %s", value, elem)
+			}
+		}
+
+		return Nil
+
+	case *ast.MemberAssignmentExpression:
+		object := c.expression(expr.Target.Object)
+
+		// methods cannot be assigned, only fields
+		if typ, ok := object.(*Struct); ok && !typ.Field(expr.Target.Member.Value) {
+			c.errorf(expr.Target.Member.Token, "%s has no field %s", typ, expr.Target.Member.Value)
+		} else {
+			c.memberOf(object, expr.Target)
+		}
+
+		c.expression(expr.Value)
+		return Nil
+
+	case *ast.MemberExpression:
+		return c.member(expr)
+
+	case *ast.IfExpression:
+		c.expression(expr.Condition)
+		ifArm := c.block(expr.IfArm)
+
+		if expr.ElseArm == nil {
+			return Any
+		}
+
+		return unify(ifArm, c.block(expr.ElseArm))
+
+	case *ast.MatchExpression:
+		return c.match(expr)
+
+	case *ast.BlockStatement:
+		return c.block(expr)
+
+	case *ast.FunctionLiteralExpression:
+		return c.function(expr)
+
+	case *ast.FunctionCallExpression:
+		return c.call(expr)
+
+	case *ast.ArrayLiteralExpression:
+		var elem Type
+
+		if expr.Elements != nil {
+			for _, element := range expr.Elements.List {
+				elem = unify(elem, c.expression(element))
+			}
+		}
+
+		if elem == nil {
+			elem = Any
+		}
+
+		return &Array{Elem: elem}
+
+	case *ast.HashmapLiteralExpression:
+		var key, value Type
+
+		for _, k := range expr.Keys {
+			key = unify(key, c.expression(k))
+			value = unify(value, c.expression(expr.Map[k]))
+		}
+
+		if key == nil {
+			key, value = Any, Any
+		}
+
+		return &Hash{Key: key, Value: value}
+
+	case *ast.IndexExpression:
+		return c.index(expr)
+	}
+
+	return Any
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	sym, ok := c.info.Symbols[ident]
+
+	if !ok {
+		return Any
+	}
+
+	if sym.Kind == resolve.Builtin {
+		if fn, ok := builtinTypes[sym.Name]; ok {
+			return fn
+		}
+
+		return Any
+	}
+
+	if typ, ok := c.types[sym]; ok {
+		return typ
+	}
+
+	// declared later, used from a function body
+	return Any
+}
+
+func (c *checker) prefix(expr *ast.PrefixExpression) Type {
+	right := c.expression(expr.Right)
+
+	switch expr.Operator {
+	case "!":
+		return Bool
+
+	case "-":
+		if numericOrAny(right) {
+			return right
+		}
+
+		c.errorf(expr.Token, "invalid operation: -%s", right)
+	}
+
+	return Any
+}
+
+func (c *checker) infix(expr *ast.InfixExpression) Type {
+	left, right := c.expression(expr.Left), c.expression(expr.Right)
+
+	// a struct may overload the operator with a method of any result
+	if typ, ok := left.(*Struct); ok && typ.Method(object.OperatorMethods[expr.Operator]) {
+		return Any
+	}
+
+	switch expr.Operator {
+	case "==", "!=":
+		return Bool
+
+	case "<", "<=", ">", ">=":
+		if numericOrAny(left) && numericOrAny(right) {
+			return Bool
+		}
+
+		c.errorf(expr.Token, "type mismatch: %s %s %s", left, expr.Operator, right)
+		return Bool
+
+	case "+", "-", "*", "/":
+		if left == Any || right == Any {
+			// `+` may join strings, the other operators only take numbers
+			if expr.Operator != "+" && !(numericOrAny(left) && numericOrAny(right)) {
+				c.errorf(expr.Token, "type mismatch: %s %s %s", left, expr.Operator, right)
+			}
+
+			return Any
+		}
+
+		switch {
+		case left == Int && right == Int:
+			return Int
+
+		case isNumeric(left) && isNumeric(right):
+			return Float
+
+		case left == String && right == String && expr.Operator == "+":
+			return String
+		}
+
+		c.errorf(expr.Token, "type mismatch: %s %s %s", left, expr.Operator, right)
+	}
+
+	return Any
+}
+
+func (c *checker) function(fn *ast.FunctionLiteralExpression) Type {
+	typ := &Func{Params: []Type{}, Return: c.annotation(fn.ReturnType)}
+
+	for _, param := range fn.Parameters {
+		paramType := c.annotation(param.Type)
+		typ.Params = append(typ.Params, paramType)
+
+		if param.Default != nil {
+			typ.Optional++
+
+			if value := c.expression(param.Default); !assignable(paramType, value) {
+				c.errorf(ast.StartToken(param.Default), "cannot use %s as %s in the default value of %s", value, paramType, param.Target())
+			}
+		}
+
+		if param.Rest {
+			// the rest parameter is annotated with the type of its elements
+			typ.Variadic = true
+			c.declare(param.Name, true, &Array{Elem: paramType})
+		} else if param.Pattern != nil {
+			c.pattern(param.Pattern, paramType)
+		} else {
+			c.declare(param.Name, param.Type != nil, paramType)
+		}
+	}
+
+	var expected Type
+
+	if fn.ReturnType != nil {
+		expected = typ.Return
+	}
+
+	c.results = append(c.results, expected)
+	defer func() { c.results = c.results[:len(c.results)-1] }()
+
+	if fn.Body == nil {
+		return typ
+	}
+
+	// the last expression of the body is the result of the function
+	stmts := fn.Body.Statements
+	c.hoist(stmts)
+	result := c.statements(stmts)
+
+	if len(stmts) > 0 {
+		if last, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok {
+			c.checkResult(last.Expression, result)
+		}
+	}
+
+	return typ
+}
+
+func (c *checker) call(call *ast.FunctionCallExpression) Type {
+	callee := c.expression(call.Function)
+	args := []Type{}
+	spread := false
+
+	if call.Args != nil {
+		for _, arg := range call.Args.List {
+			if arg, ok := arg.(*ast.SpreadExpression); ok {
+				if value := c.expression(arg.Value); !assignable(&Array{Elem: Any}, value) {
+					c.errorf(ast.StartToken(arg.Value), "cannot spread %s", value)
+				}
+
+				// the number of arguments is only known at run time from here
+				spread = true
+				continue
+			}
+
+			if value := c.expression(arg); !spread {
+				args = append(args, value)
+			}
+		}
+	}
+
+	if callee == Any {
+		return Any
+	}
+
+	fn, ok := callee.(*Func)
+
+	if !ok {
+		c.errorf(ast.StartToken(call.Function), "cannot call %s", callee)
+		return Any
+	}
+
+	min, max := fn.arity()
+
+	if (!spread && len(args) < min) || (max >= 0 && len(args) > max) {
+		c.errorf(ast.StartToken(call.Function), "wrong number of arguments for %s: expected %s, got %d", fn, arity(min, max), len(args))
+		return fn.Return
+	}
+
+	for i, arg := range args {
+		param := fn.Params[len(fn.Params)-1]
+
+		if i < len(fn.Params) {
+			param = fn.Params[i]
+		}
+
+		if !assignable(param, arg) {
+			c.errorf(ast.StartToken(call.Args.List[i]), "cannot use %s as %s in argument %d", arg, param, i+1)
+		}
+	}
+
+	return fn.Return
+}
+
+// match checks the arms of expr, its type being the union of their bodies
+func (c *checker) match(expr *ast.MatchExpression) Type {
+	value := c.expression(expr.Value)
+
+	var result Type
+
+	for _, arm := range expr.Arms {
+		c.pattern(arm.Pattern, value)
+
+		if arm.Guard != nil {
+			c.expression(arm.Guard)
+		}
+
+		result = unify(result, c.expression(arm.Body))
+	}
+
+	if result == nil {
+		return Any
+	}
+
+	return result
+}
+
+// let declares the names bound by stmt, the ones of a destructuring pattern
+// taking their types from the parts of typ they stand for
+func (c *checker) let(stmt *ast.LetStatement, annotated bool, typ Type) {
+	if stmt.Pattern != nil {
+		c.pattern(stmt.Pattern, typ)
+	} else {
+		c.declare(stmt.Name, annotated, typ)
+	}
+}
+
+// pattern gives their type to the names bound by pattern, value being the
+// type of the values it is matched against
+func (c *checker) pattern(pattern ast.Pattern, value Type) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.declare(pattern, false, value)
+
+	case *ast.LiteralPattern:
+		c.expression(pattern.Value)
+
+	case *ast.ArrayPattern:
+		var elem Type = Any
+
+		if array, ok := value.(*Array); ok {
+			elem = array.Elem
+		}
+
+		for _, e := range pattern.Elements {
+			c.pattern(e, elem)
+		}
+
+		if pattern.Rest != nil {
+			c.declare(pattern.Rest, false, &Array{Elem: elem})
+		}
+
+	case *ast.HashPattern:
+		var val Type = Any
+
+		if hash, ok := value.(*Hash); ok {
+			val = hash.Value
+		}
+
+		for _, v := range pattern.Values {
+			c.pattern(v, val)
+		}
+
+	case *ast.AlternativePattern:
+		for _, alt := range pattern.Alternatives {
+			c.pattern(alt, value)
+		}
+	}
+}
+
+// member returns the type of what expr reads: a field or method of a
+// struct, of any type, a key of a hashmap or a builtin method
+func (c *checker) member(expr *ast.MemberExpression) Type {
+	if typ, ok := c.structOf(expr.Object); ok {
+		// `Point.dist` is a method of the struct itself
+		if !typ.Method(expr.Member.Value) {
+			c.errorf(expr.Member.Token, "%s has no method %s", typ, expr.Member.Value)
+		}
+
+		return Any
+	}
+
+	return c.memberOf(c.expression(expr.Object), expr)
+}
+
+// memberOf returns the type of the member of expr read from a value of
+// type object
+func (c *checker) memberOf(object Type, expr *ast.MemberExpression) Type {
+	name := expr.Member.Value
+
+	switch object := object.(type) {
+	case *Struct:
+		if !object.Field(name) && !object.Method(name) {
+			c.errorf(expr.Member.Token, "%s has no field or method %s", object, name)
+		}
+
+		return Any
+
+	case *Hash:
+		// a method is assumed, even though a key of the same name would
+		// win when the program runs
+		if method, ok := methodType(object, name); ok {
+			return method
+		}
+
+		return object.Value
+	}
+
+	if object == Any {
+		return Any
+	}
+
+	if method, ok := methodType(object, name); ok {
+		return method
+	}
+
+	c.errorf(expr.Member.Token, "%s has no method %s", object, name)
+	return Any
+}
+
+// structOf returns the struct expr names, if it is the name of one
+func (c *checker) structOf(expr ast.Expression) (*Struct, bool) {
+	ident, ok := expr.(*ast.Identifier)
+
+	if !ok {
+		return nil, false
+	}
+
+	if sym, ok := c.info.Symbols[ident]; !ok || sym.Kind != resolve.Struct {
+		return nil, false
+	}
+
+	typ, ok := c.structs[ident.Value]
+	return typ, ok
+}
+
+func (c *checker) index(expr *ast.IndexExpression) Type {
+	left, index := c.expression(expr.Ident), c.expression(expr.Index)
+
+	switch left := left.(type) {
+	case *Array:
+		if !assignable(Int, index) {
+			c.errorf(ast.StartToken(expr.Index), "array index must be int, not %s", index)
+		}
+
+		return left.Elem
+
+	case *Hash:
+		if !assignable(left.Key, index) {
+			c.errorf(ast.StartToken(expr.Index), "cannot use %s as key of %s", index, left)
+		}
+
+		return left.Value
+
+	case *Struct:
+		if left.Method("__index__") {
+			return Any
+		}
+	}
+
+	if left != Any {
+		c.errorf(expr.Token, "cannot index %s", left)
+	}
+
+	return Any
+}
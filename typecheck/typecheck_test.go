@@ -0,0 +1,83 @@
+package typecheck
+
+import (
+	"Klang/lexer"
+	"Klang/parser"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// unannotated code is never rejected
+		{"let f = fn(a, b) { a + b }; f(1, 2); f(\"a\", \"b\");", []string{}},
+		{"let x = 1; x = \"a\"; print(x);", []string{}},
+		{"let x: int = 1; let y: [string] = [\"a\"]; let h: {string: int} = {\"a\": 1};", []string{}},
+		{"let s: string = 1;", []string{"1:17: cannot use int as string in the declaration of s"}},
+		{"let xs: [int] = [\"a\"];", []string{"1:17: cannot use [string] as [int] in the declaration of xs"}},
+		{"let x: int = 1; x = \"a\";", []string{"1:21: cannot assign string to x of type int"}},
+		{"let y: foo = 1;", []string{"1:8: unknown type foo"}},
+		{"1 + \"a\";", []string{"1:3: type mismatch: int + string"}},
+		{"\"a\" * 2;", []string{"1:5: type mismatch: string * int"}},
+		{"-\"a\";", []string{"1:1: invalid operation: -string"}},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(\"1\", 2);", []string{"1:52: cannot use string as int in argument 1"}},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(1);", []string{"1:48: wrong number of arguments for fn(int, int) -> int: expected 2, got 1"}},
+		{"let f = fn(x: string) -> bool { return x; };", []string{"1:40: cannot return string from a function returning bool"}},
+		{"let f = fn() -> int { \"a\" };", []string{"1:23: cannot return string from a function returning int"}},
+		{"let g: fn(int) -> int = fn(n) { n * 2 }; g(1) + 1;", []string{}},
+		{"let n: int = len([1]); readFile(1);", []string{"1:33: cannot use int as string in argument 1"}},
+		{"len(\"abc\");", []string{"1:5: cannot use string as [any] in argument 1"}},
+		{"let h: {string: int} = {\"a\": 1}; h[1];", []string{"1:36: cannot use int as key of {string: int}"}},
+		{"let xs = [1, 2]; xs[\"a\"];", []string{"1:21: array index must be int, not string"}},
+		{"1(2);", []string{"1:1: cannot call int"}},
+		{"let n: int = twice(\"a\"); fn twice(s: string) -> string { s + s }", []string{"1:14: cannot use string as int in the declaration of n"}},
+		{"fn f(n: int) -> int { if n < 2 { return 1; } n * f(n - 1) } f(\"a\");", []string{"1:63: cannot use string as int in argument 1"}},
+		{"let f = fn(a: int, b: int = 2) -> int { a + b }; f(1); f(1, 2); f(1, 2, 3);", []string{"1:65: wrong number of arguments for fn(int, int) -> int: expected 1 to 2, got 3"}},
+		{"let f = fn(a: int = \"a\") { a };", []string{"1:21: cannot use string as int in the default value of a"}},
+		{"let f = fn(first, ...rest: int) -> [int] { rest }; f(); f(1, 2, \"a\");", []string{"1:52: wrong number of arguments for fn(any, ...int) -> [int]: expected at least 1, got 0", "1:65: cannot use string as int in argument 3"}},
+		{"let f = fn(a: int, b: int) { a + b }; f(...[1, 2]); f(1, ...[2]); f(...1);", []string{"1:72: cannot spread int"}},
+		{"let f: fn(int, ...string) = fn(a, ...b) { a }; f(1, \"a\", \"b\");", []string{}},
+		{"let xs: [int] = [1]; xs[0] = 2; xs[0] = \"a\"; const h = {\"a\": 1}; h[\"b\"] = \"b\";", []string{"1:41: cannot assign string to element of type int"}},
+		{"let xs: [int] = [1]; let n: string = match xs { [x] => x, [x, ...rest] => len(rest), _ => 0 };", []string{"1:38: cannot use int as string in the declaration of n"}},
+		{"let h = {\"a\": \"b\"}; match h { {a} => a + 1, _ => 0 };", []string{"1:40: type mismatch: string + int"}},
+		{"let xs: [string] = [\"a\"]; let [first, ...rest] = xs; first + 1;", []string{"1:60: type mismatch: string + int"}},
+		{"let f = fn([a, b]: [int]) { a + \"s\" };", []string{"1:31: type mismatch: int + string"}},
+		{"let [a]: [int] = [\"s\"];", []string{"1:18: cannot use [string] as [int] in the declaration of [a]"}},
+		{"struct Point { x, y } let p: Point = Point(1, 2); p.x + p.y;", []string{}},
+		{"fn norm(p: Point) { p.x } struct Point { x, y } norm(Point(1, 2)); norm(1);", []string{"1:73: cannot use int as Point in argument 1"}},
+		{"struct Point { x, y } let p = Point(1, 2); p.z; p.w = 1;", []string{"1:46: Point has no field or method z", "1:51: Point has no field w"}},
+		{"struct Point { x, y } impl Point { fn sum(self) { self.x + self.y } } let p = Point(1, 2); p.sum() + Point.sum(p); p.z;", []string{"1:118: Point has no field or method z"}},
+		{"struct Point { x, y } trait Show { fn show(self); fn print(self) { println(self.show()) } } impl Show for Point { fn show(self) { 1 } } Point(1, 2).print(); Point.nope;", []string{"1:164: Point has no method nope"}},
+		{"struct P { x } impl P { fn get(self) -> string { 1 } }", []string{"1:50: cannot return int from a function returning string"}},
+		{"struct V { x } impl V { fn __add__(self, o) { V(self.x + o.x) } fn __index__(self, i) { self.x } } let v = V(1) + V(2); V(1)[0]; V(1) < V(2);", []string{"1:135: type mismatch: V < V"}},
+		{"struct Point { x, y } Point(1);", []string{"1:23: wrong number of arguments for fn(any, any) -> Point: expected 2, got 1"}},
+		{"let n = 1; n.x;", []string{"1:14: int has no method x"}},
+		{"let s: string = \"a\".upper().split(\",\").join(1);", []string{"1:45: cannot use int as string in argument 1"}},
+		{"let n: int = [1].len(); let h: {string: int} = {\"a\": 1}; let v: string = h.a;", []string{"1:74: cannot use int as string in the declaration of v"}},
+		{"let xs = [1]; xs.nope();", []string{"1:18: [int] has no method nope"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%q: parse error: %s", tt.input, p.Errors()[0])
+		}
+
+		errors := Check(program, []string{"print", "len", "readFile"})
+
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.expected, errors)
+			continue
+		}
+
+		for i, err := range errors {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("%q: expected %q, got %q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
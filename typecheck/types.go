@@ -0,0 +1,251 @@
+package typecheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of an expression
+type Type interface {
+	String() string
+}
+
+// Basic is a type written by its name alone
+type Basic string
+
+const (
+	Int    Basic = "int"
+	Float  Basic = "float"
+	String Basic = "string"
+	Bool   Basic = "bool"
+	Nil    Basic = "nil"
+	Any    Basic = "any" // unknown, accepted everywhere
+)
+
+func (b Basic) String() string {
+	return string(b)
+}
+
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string {
+	return "[" + a.Elem.String() + "]"
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+// Struct is the type of the values built by the constructor of a struct
+type Struct struct {
+	Name    string
+	Fields  []string
+	Methods []string // added by impl blocks, trait defaults included
+}
+
+func (s *Struct) String() string {
+	return s.Name
+}
+
+// Field reports whether s declares the field name
+func (s *Struct) Field(name string) bool {
+	for _, field := range s.Fields {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Method reports whether an impl block adds the method name to s
+func (s *Struct) Method(name string) bool {
+	for _, method := range s.Methods {
+		if method == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Func struct {
+	Params   []Type
+	Return   Type
+	Optional int  // number of parameters before the variadic one that have a default value
+	Variadic bool // the last parameter accepts any number of arguments
+}
+
+// arity returns the bounds of the number of arguments of f, max being -1
+// when unbounded
+func (f *Func) arity() (min, max int) {
+	min, max = len(f.Params)-f.Optional, len(f.Params)
+
+	if f.Variadic {
+		min, max = min-1, -1
+	}
+
+	return min, max
+}
+
+func (f *Func) String() string {
+	params := []string{}
+
+	for i, param := range f.Params {
+		if f.Variadic && i == len(f.Params)-1 {
+			params = append(params, "..."+param.String())
+		} else {
+			params = append(params, param.String())
+		}
+	}
+
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// builtinTypes are the signatures of the builtins of the evaluator
+var builtinTypes = map[string]*Func{
+	"len":       {Params: []Type{&Array{Elem: Any}}, Return: Int},
+	"print":     {Params: []Type{Any}, Return: Nil, Variadic: true},
+	"println":   {Params: []Type{Any}, Return: Nil, Variadic: true},
+	"eprint":    {Params: []Type{Any}, Return: Nil, Variadic: true},
+	"printf":    {Params: []Type{String, Any}, Return: Nil, Variadic: true},
+	"readFile":  {Params: []Type{String}, Return: String},
+	"writeFile": {Params: []Type{String, Any}, Return: Nil},
+	"getenv":    {Params: []Type{String}, Return: Any},
+	"args":      {Params: []Type{}, Return: &Array{Elem: String}},
+	"exit":      {Params: []Type{Int}, Return: Nil, Optional: 1},
+	"freeze":    {Params: []Type{Any}, Return: Any},
+}
+
+// methodType returns the signature of the builtin method name of a value of
+// type recv, the receiver left out
+func methodType(recv Type, name string) (*Func, bool) {
+	var methods map[string]*Func
+
+	switch recv := recv.(type) {
+	case *Array:
+		methods = map[string]*Func{
+			"len":      {Params: []Type{}, Return: Int},
+			"push":     {Params: []Type{Any}, Return: recv, Variadic: true},
+			"pop":      {Params: []Type{}, Return: Any},
+			"map":      {Params: []Type{Any}, Return: &Array{Elem: Any}},
+			"filter":   {Params: []Type{Any}, Return: recv},
+			"reduce":   {Params: []Type{Any, Any}, Return: Any},
+			"contains": {Params: []Type{Any}, Return: Bool},
+			"join":     {Params: []Type{String}, Return: String},
+			"reverse":  {Params: []Type{}, Return: recv},
+		}
+
+	case *Hash:
+		methods = map[string]*Func{
+			"len":    {Params: []Type{}, Return: Int},
+			"keys":   {Params: []Type{}, Return: &Array{Elem: recv.Key}},
+			"values": {Params: []Type{}, Return: &Array{Elem: recv.Value}},
+			"has":    {Params: []Type{Any}, Return: Bool},
+		}
+
+	case Basic:
+		if recv != String {
+			return nil, false
+		}
+
+		methods = map[string]*Func{
+			"len":        {Params: []Type{}, Return: Int},
+			"upper":      {Params: []Type{}, Return: String},
+			"lower":      {Params: []Type{}, Return: String},
+			"trim":       {Params: []Type{}, Return: String},
+			"split":      {Params: []Type{String}, Return: &Array{Elem: String}},
+			"contains":   {Params: []Type{String}, Return: Bool},
+			"startsWith": {Params: []Type{String}, Return: Bool},
+			"endsWith":   {Params: []Type{String}, Return: Bool},
+			"replace":    {Params: []Type{String, String}, Return: String},
+		}
+	}
+
+	fn, ok := methods[name]
+	return fn, ok
+}
+
+// arity describes the accepted number of arguments, max being -1 when unbounded
+func arity(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+// assignable reports whether a value of type src can be stored where dst is expected
+func assignable(dst, src Type) bool {
+	if dst == Any || src == Any {
+		return true
+	}
+
+	switch dst := dst.(type) {
+	case Basic:
+		return dst == src
+
+	case *Array:
+		src, ok := src.(*Array)
+		return ok && assignable(dst.Elem, src.Elem)
+
+	case *Hash:
+		src, ok := src.(*Hash)
+		return ok && assignable(dst.Key, src.Key) && assignable(dst.Value, src.Value)
+
+	case *Struct:
+		return dst == src
+
+	case *Func:
+		src, ok := src.(*Func)
+
+		if !ok || len(dst.Params) != len(src.Params) || dst.Variadic != src.Variadic || dst.Optional > src.Optional {
+			return false
+		}
+
+		for i := range dst.Params {
+			if !assignable(src.Params[i], dst.Params[i]) {
+				return false
+			}
+		}
+
+		return assignable(dst.Return, src.Return)
+	}
+
+	return false
+}
+
+// unify returns the type common to a and b, any when they differ
+func unify(a, b Type) Type {
+	if a == nil {
+		return b
+	}
+
+	if identical(a, b) {
+		return a
+	}
+
+	return Any
+}
+
+func identical(a, b Type) bool {
+	return a.String() == b.String()
+}
+
+func isNumeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+func numericOrAny(t Type) bool {
+	return t == Any || isNumeric(t)
+}